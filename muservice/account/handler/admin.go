@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// Lockouts handler lists the most recent sign in lockouts
+func (h *Handler) Lockouts(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	events, err := h.ThrottleService.LockoutEvents(ctx)
+
+	if err != nil {
+		log.Printf("Failed to list lockout events: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lockouts": events,
+	})
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLockouts(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	adminKey := "supersecretadminkey"
+
+	now := time.Now().UTC().Truncate(time.Second)
+	mockEvents := []*models.LockoutEvent{
+		{
+			Kind:       models.ThrottleKindEmail,
+			Identifier: "bob@bob.com",
+			Attempts:   10,
+			LockedAt:   now,
+			Until:      now.Add(15 * time.Minute),
+		},
+	}
+
+	mockThrottleService := new(mocks.MockThrottleService)
+	mockThrottleService.On("LockoutEvents", mock.Anything).Return(mockEvents, nil)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:          router,
+		ThrottleService: mockThrottleService,
+		AdminKey:        adminKey,
+	})
+
+	t.Run("Missing admin key", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/admin/lockouts", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Wrong admin key", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/admin/lockouts", nil)
+		request.Header.Set("X-Admin-Key", "notthekey")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/admin/lockouts", nil)
+		request.Header.Set("X-Admin-Key", adminKey)
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"lockouts": mockEvents,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Disabled without admin key", func(t *testing.T) {
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router:          router,
+			ThrottleService: mockThrottleService,
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/admin/lockouts", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type createAPIKeyReq struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// CreateAPIKey handler creates an API key for the user
+// The key is only sent in this response
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req createAPIKeyReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := user.(*models.User).UID
+
+	apiKey := &models.APIKey{
+		UID:       uid,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	key, err := h.TokenService.CreateAPIKey(c.Request.Context(), apiKey)
+
+	if err != nil {
+		log.Printf("Failed to create api key for user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"apiKey": apiKey,
+		"key":    key,
+	})
+}
+
+// APIKeys handler lists the API keys of the user
+func (h *Handler) APIKeys(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := user.(*models.User).UID
+
+	apiKeys, err := h.TokenService.ListAPIKeys(c.Request.Context(), uid)
+
+	if err != nil {
+		log.Printf("Failed to list api keys of user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"apiKeys": apiKeys,
+	})
+}
+
+// DeleteAPIKey handler revokes an API key of the user
+func (h *Handler) DeleteAPIKey(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := user.(*models.User).UID
+	id, err := uuid.Parse(c.Param("id"))
+
+	if err != nil {
+		err := apperrors.NewBadRequest("Invalid api key id")
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	if err := h.TokenService.DeleteAPIKey(c.Request.Context(), uid, id); err != nil {
+		log.Printf("Failed to delete api key: %v of user: %v\n%v", id, uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "api key deleted successfully!",
+	})
+}
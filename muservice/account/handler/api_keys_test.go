@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAPIKeys(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	mockTokenService := new(mocks.MockTokenService)
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", &models.User{
+			UID: uid,
+		})
+	})
+
+	NewHandler(&Config{
+		Router:       router,
+		TokenService: mockTokenService,
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		keyID := uuid.New()
+
+		mockTokenService.
+			On("CreateAPIKey", mock.AnythingOfType("*context.valueCtx"), mock.MatchedBy(func(k *models.APIKey) bool {
+				return k.UID == uid && k.Name == "ci" && len(k.Scopes) == 1 && k.ExpiresAt.Equal(expiresAt)
+			})).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*models.APIKey).ID = keyID
+			}).
+			Return(models.APIKeyPrefix+"akey", nil)
+
+		reqBody, _ := json.Marshal(gin.H{
+			"name":      "ci",
+			"scopes":    []string{models.ScopeAccountRead},
+			"expiresAt": expiresAt,
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/api-keys", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"key":"muk_akey"`)
+		assert.Contains(t, rr.Body.String(), keyID.String())
+		assert.NotContains(t, rr.Body.String(), "keyHash")
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	})
+
+	t.Run("Create without scopes", func(t *testing.T) {
+		reqBody, _ := json.Marshal(gin.H{
+			"name": "noscopes",
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/api-keys", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockTokenService.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.MatchedBy(func(k *models.APIKey) bool {
+			return k.Name == "noscopes"
+		}))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		apiKeys := []*models.APIKey{
+			{ID: uuid.New(), UID: uid, Name: "ci", KeyHash: "ahash", Scopes: []string{models.ScopeAccountRead}},
+		}
+
+		mockTokenService.On("ListAPIKeys", mock.AnythingOfType("*context.valueCtx"), uid).Return(apiKeys, nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/api-keys", nil)
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"apiKeys": apiKeys,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		assert.NotContains(t, rr.Body.String(), "ahash")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		keyID := uuid.New()
+
+		mockTokenService.On("DeleteAPIKey", mock.AnythingOfType("*context.valueCtx"), uid, keyID).Return(nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/api-keys/"+keyID.String(), nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Delete unknown key", func(t *testing.T) {
+		keyID := uuid.New()
+
+		mockTokenService.On("DeleteAPIKey", mock.AnythingOfType("*context.valueCtx"), uid, keyID).Return(apperrors.NewNotFound("api_key", keyID.String()))
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/api-keys/"+keyID.String(), nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Delete with invalid id", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/api-keys/notauuid", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	mockTokenService.AssertExpectations(t)
+}
@@ -40,7 +40,7 @@ func bindData(c *gin.Context, req interface{}) bool {
 			for _, err := range errs {
 				invalidArgs = append(invalidArgs, invalidArgument{
 					err.Field(),
-					err.Value().(string),
+					fmt.Sprint(err.Value()), // fields aren't always strings, eg. lists
 					err.Tag(),
 					err.Param(),
 				})
@@ -0,0 +1,18 @@
+package handler
+
+import "github.com/gin-gonic/gin"
+
+// TrustProxies makes the router take the client IP from the
+// X-Forwarded-For header only if the request comes from one of
+// trustedProxies, which are IPs or CIDRs of the reverse proxy. Without
+// trusted proxies the address of the connection is the client IP
+// Failed sign ins and rate limits are counted per client IP, so
+// clients must not be able to choose it
+func TrustProxies(router *gin.Engine, trustedProxies []string) error {
+	if len(trustedProxies) == 0 {
+		router.ForwardedByClientIP = false
+		return router.SetTrustedProxies(nil)
+	}
+
+	return router.SetTrustedProxies(trustedProxies)
+}
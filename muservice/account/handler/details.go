@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// detailsReq is not exported
+// fields are pointers so fields which are left out of the
+// request can be told apart from fields which are cleared
+// a user can remove their name or website, but not their email
+type detailsReq struct {
+	Name    *string `json:"name" binding:"omitempty,max=50"`
+	Email   *string `json:"email" binding:"omitempty,email"`
+	Website *string `json:"website" binding:"omitempty,url|eq="`
+}
+
+// Details handler updates the details of the signed in user
+// Only fields included in the request are changed
+func (h *Handler) Details(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req detailsReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	// start from the stored user so fields left out are kept
+	u, err := h.UserService.Get(ctx, uid)
+
+	if err != nil {
+		log.Printf("Unable to find user: %v\n%v", uid, err)
+		e := apperrors.NewNotFound("user", uid.String())
+
+		c.JSON(e.Status(), gin.H{
+			"error": e,
+		})
+		return
+	}
+
+	if req.Name != nil {
+		u.Name = *req.Name
+	}
+
+	if req.Email != nil {
+		u.Email = *req.Email
+	}
+
+	if req.Website != nil {
+		u.Website = *req.Website
+	}
+
+	err = h.UserService.UpdateDetails(ctx, u)
+
+	if err != nil {
+		log.Printf("Failed to update user: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": u,
+	})
+}
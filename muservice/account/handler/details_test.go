@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDetails(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	// the stored user, returned as a fresh copy on every call to Get
+	storedUser := func() *models.User {
+		return &models.User{
+			UID:     uid,
+			Email:   "bob@bob.com",
+			Name:    "Bobby Bobson",
+			Website: "https://bob.com",
+		}
+	}
+
+	newRouter := func(mockUserService *mocks.MockUserService) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:      router,
+			UserService: mockUserService,
+		})
+
+		return router
+	}
+
+	t.Run("Data binding error", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "notanemail",
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/details", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "UpdateDetails", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Empty email", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "",
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/details", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "UpdateDetails", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Partial update", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		// only the name changes, other fields are kept
+		expectedUser := storedUser()
+		expectedUser.Name = "Bob"
+
+		mockUserService.On("Get", mock.Anything, uid).Return(storedUser(), nil)
+		mockUserService.On("UpdateDetails", mock.Anything, expectedUser).Return(nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"name": "Bob",
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/details", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"user": expectedUser,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Clear website", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		expectedUser := storedUser()
+		expectedUser.Website = ""
+		expectedUser.Email = "bobby@bob.com"
+
+		mockUserService.On("Get", mock.Anything, uid).Return(storedUser(), nil)
+		mockUserService.On("UpdateDetails", mock.Anything, expectedUser).Return(nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email":   "bobby@bob.com",
+			"website": "",
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/details", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Email already in use", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		expectedUser := storedUser()
+		expectedUser.Email = "taken@bob.com"
+
+		mockError := apperrors.NewConflict("email", expectedUser.Email)
+
+		mockUserService.On("Get", mock.Anything, uid).Return(storedUser(), nil)
+		mockUserService.On("UpdateDetails", mock.Anything, expectedUser).Return(mockError)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "taken@bob.com",
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/details", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockUserService.AssertExpectations(t)
+	})
+}
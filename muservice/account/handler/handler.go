@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"net/http"
 	"time"
 
 	"github.com/NetworkPy/muserv/muservice/account/handler/middleware"
@@ -12,81 +11,228 @@ import (
 
 // Handler struct holds required services for handler to function
 type Handler struct {
-	UserService  models.UserService
-	TokenService models.TokenService
+	UserService     models.UserService
+	TokenService    models.TokenService
+	ThrottleService models.ThrottleService
+	MFAService      models.MFAService
+	WebAuthnService models.WebAuthnService
+	OAuthService    models.OAuthService
+	OpenIDConfig    *models.OpenIDConfiguration
+	MaxBodyBytes    int64
 }
 
 // Config will hold services that will eventually be injected into this
 // handler layer on handler initialization
+// ThrottleService limits failed sign in attempts if set. RequireVerifiedEmail
+// rejects users with an unverified email address on routes changing their
+// account. AdminKey enables the admin routes, which require it in the
+// X-Admin-Key header. MFAService enables two-factor authentication and
+// WebAuthnService enables passkeys. OAuthService enables the OAuth
+// authorization server, whose clients are managed on the admin routes,
+// along with token introspection for service clients and revocation
+// Issuer is the URL BaseURL is served at, it enables OpenID Connect
+// discovery and must match the issuer of our tokens. AuthorizationURL is
+// the page of the frontend which signs the user in and passes authorization
+// requests on to /authorize, it is advertised as authorization endpoint
+// RateLimiter limits requests to the routes named in RateLimits, which
+// defaults to DefaultRateLimits
 type Config struct {
-	Router          *gin.Engine
-	UserService     models.UserService
-	TokenService    models.TokenService
-	BaseURL         string
-	TimeoutDuration time.Duration
+	Router               *gin.Engine
+	UserService          models.UserService
+	TokenService         models.TokenService
+	ThrottleService      models.ThrottleService
+	MFAService           models.MFAService
+	WebAuthnService      models.WebAuthnService
+	OAuthService         models.OAuthService
+	BaseURL              string
+	Issuer               string
+	AuthorizationURL     string
+	TimeoutDuration      time.Duration
+	MaxBodyBytes         int64
+	RequireVerifiedEmail bool
+	AdminKey             string
+	RateLimiter          models.RateLimiter
+	RateLimits           map[string]models.RateLimit
+}
+
+// DefaultRateLimits holds the rate limits of routes per user or IP
+// Routes sharing a name share their limit
+var DefaultRateLimits = map[string]models.RateLimit{
+	"signup":       {Rate: 10, Period: time.Hour, Burst: 5},
+	"signin":       {Rate: 60, Period: time.Minute, Burst: 20},
+	"tokens":       {Rate: 60, Period: time.Minute, Burst: 20},
+	"verify-email": {Rate: 10, Period: time.Hour, Burst: 3},
+	"password":     {Rate: 10, Period: time.Hour, Burst: 5},
+	"magic-link":   {Rate: 10, Period: time.Hour, Burst: 5},
+	"details":      {Rate: 30, Period: time.Minute, Burst: 10},
+	"image":        {Rate: 10, Period: time.Minute, Burst: 5},
+	"mfa":          {Rate: 30, Period: time.Minute, Burst: 10},
+	"webauthn":     {Rate: 30, Period: time.Minute, Burst: 10},
+	"api-keys":     {Rate: 10, Period: time.Hour, Burst: 5},
 }
 
 // Create an account group
 // Create a handler (which will later have injected services)
 func NewHandler(c *Config) {
 
+	maxBodyBytes := c.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+
 	h := &Handler{
-		UserService:  c.UserService,
-		TokenService: c.TokenService,
+		UserService:     c.UserService,
+		TokenService:    c.TokenService,
+		ThrottleService: c.ThrottleService,
+		MFAService:      c.MFAService,
+		WebAuthnService: c.WebAuthnService,
+		OAuthService:    c.OAuthService,
+		MaxBodyBytes:    maxBodyBytes,
+	}
+
+	if c.Issuer != "" {
+		h.OpenIDConfig = newOpenIDConfiguration(c)
+	}
+
+	rateLimits := c.RateLimits
+	if rateLimits == nil {
+		rateLimits = DefaultRateLimits
+	}
+
+	// limited adds the rate limit of the named route, if any,
+	// right before the route's handler, so it runs after AuthUser
+	limited := func(name string, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+		limit, ok := rateLimits[name]
+		if c.RateLimiter == nil || !ok {
+			return handlers
+		}
+
+		last := len(handlers) - 1
+		chain := append([]gin.HandlerFunc{}, handlers[:last]...)
+		chain = append(chain, middleware.RateLimit(c.RateLimiter, name, limit))
+		return append(chain, handlers[last])
 	}
 
 	g := c.Router.Group(c.BaseURL) // Init group
 
+	// sessions record the client they were signed in from
+	g.Use(middleware.ClientInfo())
+
 	// Wish I had thought this through better!
 	if gin.Mode() != gin.TestMode {
 		g.Use(middleware.Timeout(c.TimeoutDuration, apperrors.NewServiceUnavailable()))
-		g.GET("/me", middleware.AuthUser(h.TokenService), h.Me)
+
+		// authVerified is used on routes which may
+		// require a verified email address. Like /me, they
+		// accept API keys and access tokens with account scopes
+		authVerified := []gin.HandlerFunc{middleware.AuthUser(h.TokenService, models.ScopeAccountWrite)}
+		if c.RequireVerifiedEmail {
+			authVerified = append(authVerified, middleware.RequireVerifiedEmail(h.UserService))
+		}
+
+		g.GET("/me", middleware.AuthUser(h.TokenService, models.ScopeAccountRead), h.Me)
+		g.POST("/signout", middleware.AuthUser(h.TokenService), h.Signout)
+		g.GET("/sessions", middleware.AuthUser(h.TokenService), h.Sessions)
+		g.DELETE("/sessions/:id", middleware.AuthUser(h.TokenService), h.DeleteSession)
+		g.GET("/api-keys", middleware.AuthUser(h.TokenService), h.APIKeys)
+		g.POST("/api-keys", limited("api-keys", middleware.AuthUser(h.TokenService), h.CreateAPIKey)...)
+		g.DELETE("/api-keys/:id", middleware.AuthUser(h.TokenService), h.DeleteAPIKey)
+		g.GET("/userinfo", middleware.AuthUser(h.TokenService, models.ScopeOpenID), h.Userinfo)
+		g.POST("/userinfo", middleware.AuthUser(h.TokenService, models.ScopeOpenID), h.Userinfo)
+		g.POST("/verify-email/send", limited("verify-email", middleware.AuthUser(h.TokenService), h.SendVerificationEmail)...)
+		g.PUT("/details", limited("details", append(authVerified, h.Details)...)...)
+		g.PUT("/password", limited("password", middleware.AuthUser(h.TokenService), h.ChangePassword)...)
+		g.POST("/image", limited("image", append(authVerified, h.Image)...)...)
+		g.DELETE("/image", limited("image", append(authVerified, h.DeleteImage)...)...)
 	} else {
 		g.GET("/me", h.Me)
+		g.POST("/signout", h.Signout)
+		g.GET("/sessions", h.Sessions)
+		g.DELETE("/sessions/:id", h.DeleteSession)
+		g.GET("/api-keys", h.APIKeys)
+		g.POST("/api-keys", limited("api-keys", h.CreateAPIKey)...)
+		g.DELETE("/api-keys/:id", h.DeleteAPIKey)
+		g.GET("/userinfo", h.Userinfo)
+		g.POST("/userinfo", h.Userinfo)
+		g.POST("/verify-email/send", limited("verify-email", h.SendVerificationEmail)...)
+		g.PUT("/details", limited("details", h.Details)...)
+		g.PUT("/password", limited("password", h.ChangePassword)...)
+		g.POST("/image", limited("image", h.Image)...)
+		g.DELETE("/image", limited("image", h.DeleteImage)...)
 	}
 
 	{
-		g.POST("/signup", h.Signup)
-		g.POST("/signin", h.Signin)
-		g.POST("/signout", h.Signout)
-		g.POST("/tokens", h.Tokens)
-		g.POST("/image", h.Image)
-		g.DELETE("/image", h.DeleteImage)
-		g.PUT("/details", h.Details)
+		g.POST("/signup", limited("signup", h.Signup)...)
+		g.POST("/signin", limited("signin", h.Signin)...)
+		g.POST("/signin/magic", limited("magic-link", h.SigninMagic)...)
+		g.POST("/signin/magic/verify", limited("signin", h.SigninMagicVerify)...)
+		g.POST("/tokens", limited("tokens", h.Tokens)...)
+		g.POST("/verify-email", limited("verify-email", h.VerifyEmail)...)
+		g.POST("/password/forgot", limited("password", h.ForgotPassword)...)
+		g.POST("/password/reset", limited("password", h.ResetPassword)...)
+		g.GET("/.well-known/jwks.json", h.JWKS)
 	}
-}
 
-// Signout handler
-func (h *Handler) Signout(cnx *gin.Context) {
-	cnx.JSON(http.StatusOK, gin.H{
-		"hello": "it's signout",
-	})
-}
+	if h.OpenIDConfig != nil {
+		g.GET("/.well-known/openid-configuration", h.OpenIDConfiguration)
+	}
 
-// Tokens router
-func (h *Handler) Tokens(cnx *gin.Context) {
-	cnx.JSON(http.StatusOK, gin.H{
-		"hello": "it's tokens",
-	})
-}
+	if h.MFAService != nil {
+		mfa := g.Group("/mfa")
+		if gin.Mode() != gin.TestMode {
+			mfa.Use(middleware.AuthUser(h.TokenService))
+		}
 
-// Image router
-func (h *Handler) Image(cnx *gin.Context) {
-	cnx.JSON(http.StatusOK, gin.H{
-		"hello": "it's signin",
-	})
-}
+		mfa.POST("/enroll", limited("mfa", h.MFAEnroll)...)
+		mfa.POST("/confirm", limited("mfa", h.MFAConfirm)...)
+		mfa.POST("/disable", limited("mfa", h.MFADisable)...)
+		mfa.POST("/recovery-codes", limited("mfa", h.MFARecoveryCodes)...)
 
-// DeleteImage handler
-func (h *Handler) DeleteImage(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"hello": "it's deleteImage",
-	})
-}
+		g.POST("/signin/mfa", limited("signin", h.SigninMFA)...)
+	}
+
+	if h.WebAuthnService != nil {
+		webauthn := g.Group("/webauthn")
+		if gin.Mode() != gin.TestMode {
+			webauthn.Use(middleware.AuthUser(h.TokenService))
+		}
+
+		webauthn.POST("/register/begin", limited("webauthn", h.WebAuthnRegisterBegin)...)
+		webauthn.POST("/register/finish", limited("webauthn", h.WebAuthnRegisterFinish)...)
+		webauthn.GET("/credentials", h.WebAuthnCredentials)
+		webauthn.DELETE("/credentials/:id", h.DeleteWebAuthnCredential)
 
-// Details handler
-func (h *Handler) Details(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"hello": "it's details",
-	})
+		g.POST("/signin/passkey/begin", limited("signin", h.SigninPasskeyBegin)...)
+		g.POST("/signin/passkey/finish", limited("signin", h.SigninPasskeyFinish)...)
+	}
+
+	if h.OAuthService != nil {
+		authorize := g.Group("/authorize")
+		if gin.Mode() != gin.TestMode {
+			authorize.Use(middleware.AuthUser(h.TokenService))
+		}
+
+		authorize.GET("", h.Authorize)
+		authorize.POST("", h.AuthorizeConsent)
+
+		g.POST("/token", limited("tokens", h.OAuthToken)...)
+		g.POST("/introspect", h.Introspect)
+		g.POST("/revoke", limited("tokens", h.Revoke)...)
+	}
+
+	if c.AdminKey != "" {
+		admin := g.Group("/admin", middleware.AdminKey(c.AdminKey))
+
+		if h.ThrottleService != nil {
+			admin.GET("/lockouts", h.Lockouts)
+		}
+
+		if h.OAuthService != nil {
+			admin.GET("/oauth/clients", h.OAuthClients)
+			admin.POST("/oauth/clients", h.CreateOAuthClient)
+			admin.GET("/oauth/service-clients", h.ServiceClients)
+			admin.POST("/oauth/service-clients", h.CreateServiceClient)
+			admin.DELETE("/oauth/service-clients/:id", h.DeleteServiceClient)
+		}
+	}
 }
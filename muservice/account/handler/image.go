@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultMaxBodyBytes limits the size of uploaded images
+// when no limit is configured
+const DefaultMaxBodyBytes = 4 * 1024 * 1024
+
+// content types accepted for profile images, as detected
+// from the image data rather than the request headers
+var validImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// Image handler stores an uploaded profile image
+// of the signed in user and updates their imageURL
+func (h *Handler) Image(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+
+	// limit the size of the request body, parsing the
+	// multipart form fails once the limit is exceeded
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxBodyBytes)
+
+	imageFileHeader, err := c.FormFile("imageFile")
+
+	if err != nil {
+		log.Printf("Unable to parse multipart/form-data: %+v", err)
+
+		// the error of MaxBytesReader is only known by its message,
+		// it may also be wrapped by the multipart reader
+		if c.Request.ContentLength > h.MaxBodyBytes || strings.Contains(err.Error(), "request body too large") {
+			e := apperrors.NewPayloadTooLarge(h.MaxBodyBytes, c.Request.ContentLength)
+			c.JSON(e.Status(), gin.H{
+				"error": e,
+			})
+			return
+		}
+
+		e := apperrors.NewBadRequest("Unable to parse multipart/form-data")
+		c.JSON(e.Status(), gin.H{
+			"error": e,
+		})
+		return
+	}
+
+	imageFile, err := imageFileHeader.Open()
+
+	if err != nil {
+		log.Printf("Failed to open image file: %v\n", err)
+		e := apperrors.NewBadRequest("Unable to open image file")
+		c.JSON(e.Status(), gin.H{
+			"error": e,
+		})
+		return
+	}
+	defer imageFile.Close()
+
+	// sniff the content type, the header sent by the client can't be trusted
+	head := make([]byte, 512)
+	n, err := io.ReadFull(imageFile, head)
+
+	if err != nil && err != io.ErrUnexpectedEOF {
+		log.Printf("Failed to read image file: %v\n", err)
+		e := apperrors.NewBadRequest("Unable to read image file")
+		c.JSON(e.Status(), gin.H{
+			"error": e,
+		})
+		return
+	}
+
+	head = head[:n]
+	mimeType := http.DetectContentType(head)
+
+	if !validImageTypes[mimeType] {
+		log.Printf("Image is not an allowable mime-type: %v\n", mimeType)
+		e := apperrors.NewUnsupportedMediaType("imageFile must be 'image/jpeg' or 'image/png'")
+		c.JSON(e.Status(), gin.H{
+			"error": e,
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	u, err := h.UserService.SetProfileImage(ctx, uid, io.MultiReader(bytes.NewReader(head), imageFile))
+
+	if err != nil {
+		log.Printf("Failed to set profile image for user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": u,
+	})
+}
+
+// DeleteImage handler removes the profile image of the signed in user
+func (h *Handler) DeleteImage(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	err := h.UserService.ClearProfileImage(ctx, uid)
+
+	if err != nil {
+		log.Printf("Failed to delete profile image for user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "profile image deleted successfully!",
+	})
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestImage(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	newRouter := func(mockUserService *mocks.MockUserService, maxBodyBytes int64) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:       router,
+			UserService:  mockUserService,
+			MaxBodyBytes: maxBodyBytes,
+		})
+
+		return router
+	}
+
+	// builds a multipart request with data as the imageFile field
+	newRequest := func(field string, data []byte) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+
+		part, _ := writer.CreateFormFile(field, "avatar")
+		part.Write(data)
+		writer.Close()
+
+		request, _ := http.NewRequest(http.MethodPost, "/image", body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		return request
+	}
+
+	pngData := func() []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+		return buf.Bytes()
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService, 0)
+
+		mockUser := &models.User{
+			UID:      uid,
+			ImageURL: "http://localhost/images/avatar.png",
+		}
+
+		mockUserService.On("SetProfileImage", mock.Anything, uid, mock.Anything).Return(mockUser, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("imageFile", pngData()))
+
+		respBody, _ := json.Marshal(gin.H{
+			"user": mockUser,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Missing image file", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService, 0)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("notImageFile", pngData()))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "SetProfileImage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unsupported media type", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService, 0)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("imageFile", []byte("<html><body>not an image</body></html>")))
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		mockUserService.AssertNotCalled(t, "SetProfileImage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Payload too large", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService, 1024)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("imageFile", make([]byte, 4096)))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		mockUserService.AssertNotCalled(t, "SetProfileImage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Payload too large without content length", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService, 1024)
+
+		request := newRequest("imageFile", make([]byte, 4096))
+		request.ContentLength = -1
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		mockUserService.AssertNotCalled(t, "SetProfileImage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error from SetProfileImage", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService, 0)
+
+		mockError := apperrors.NewBadRequest("Unable to process image")
+		mockUserService.On("SetProfileImage", mock.Anything, uid, mock.Anything).Return(nil, mockError)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("imageFile", pngData()))
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, mockError.Status(), rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+}
+
+func TestDeleteImage(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	newRouter := func(mockUserService *mocks.MockUserService) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:      router,
+			UserService: mockUserService,
+		})
+
+		return router
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		mockUserService.On("ClearProfileImage", mock.Anything, uid).Return(nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/image", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		mockError := apperrors.NewInternal()
+		mockUserService.On("ClearProfileImage", mock.Anything, uid).Return(mockError)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/image", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, mockError.Status(), rr.Code)
+	})
+}
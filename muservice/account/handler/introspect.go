@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// tokenOpReq holds the parameters of introspection and revocation requests
+// The token_type_hint is accepted but not needed to find the token
+type tokenOpReq struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+	basicAuth     bool
+}
+
+// Introspect handler reports whether a token is active along with
+// its claims (RFC 7662). Only service clients granted the
+// tokens:introspect scope may introspect tokens
+func (h *Handler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	req, ok := bindTokenOp(c)
+
+	if !ok {
+		return
+	}
+
+	client, err := h.OAuthService.AuthenticateClient(c.Request.Context(), req.ClientID, req.ClientSecret)
+
+	if err != nil {
+		clientError(c, err, req.basicAuth)
+		return
+	}
+
+	if !hasScope(client.Scopes, models.ScopeTokensIntrospect) {
+		log.Printf("Service client: %s may not introspect tokens\n", client.ClientID)
+		err := apperrors.NewOAuthError(apperrors.UnauthorizedClient, "The client may not introspect tokens")
+		c.JSON(err.Status(), err)
+		return
+	}
+
+	if !requireToken(c, req) {
+		return
+	}
+
+	introspection, err := h.TokenService.IntrospectToken(c.Request.Context(), req.Token)
+
+	if err != nil {
+		log.Printf("Failed to introspect token for client: %s\n%v", req.ClientID, err)
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, introspection)
+}
+
+// Revoke handler revokes a refresh token (RFC 7009). Clients may only
+// revoke their own tokens, OAuth clients are public and only send
+// their client_id, service clients authenticate with their secret
+// Service clients with the tokens:revoke scope may revoke any refresh
+// token, including those of first-party sessions
+func (h *Handler) Revoke(c *gin.Context) {
+	req, ok := bindTokenOp(c)
+
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	var err error
+	anyClient := false
+	if req.ClientSecret != "" || req.basicAuth {
+		var client *models.ServiceClient
+		client, err = h.OAuthService.AuthenticateClient(ctx, req.ClientID, req.ClientSecret)
+		anyClient = err == nil && hasScope(client.Scopes, models.ScopeTokensRevoke)
+	} else {
+		_, err = h.OAuthService.AuthenticatePublicClient(ctx, req.ClientID)
+	}
+
+	if err != nil {
+		clientError(c, err, req.basicAuth)
+		return
+	}
+
+	if !requireToken(c, req) {
+		return
+	}
+
+	err = h.TokenService.RevokeToken(ctx, req.ClientID, req.Token, anyClient)
+
+	if oauthErr, ok := err.(*apperrors.OAuthError); ok {
+		c.JSON(oauthErr.Status(), oauthErr)
+		return
+	}
+
+	if err != nil {
+		log.Printf("Failed to revoke token for client: %s\n%v", req.ClientID, err)
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	log.Printf("Token revoked by client: %s\n", req.ClientID)
+
+	c.Status(http.StatusOK)
+}
+
+// bindTokenOp binds a form encoded introspection or revocation request
+// along with the credentials of the client. It writes the error
+// response and returns false if the request can't be bound
+func bindTokenOp(c *gin.Context) (*tokenOpReq, bool) {
+	var req tokenOpReq
+
+	if c.ContentType() != "application/x-www-form-urlencoded" || c.ShouldBind(&req) != nil {
+		err := apperrors.NewOAuthError(apperrors.InvalidRequest, "The request must be form encoded")
+		c.JSON(err.Status(), err)
+		return nil, false
+	}
+
+	clientID, clientSecret, basicAuth, ok := clientCredentials(c, req.ClientID, req.ClientSecret)
+
+	if !ok {
+		return nil, false
+	}
+
+	req.ClientID = clientID
+	req.ClientSecret = clientSecret
+	req.basicAuth = basicAuth
+
+	return &req, true
+}
+
+// requireToken writes the error response and
+// returns false if req has no token
+func requireToken(c *gin.Context, req *tokenOpReq) bool {
+	if req.Token == "" {
+		err := apperrors.NewOAuthError(apperrors.InvalidRequest, "token is required")
+		c.JSON(err.Status(), err)
+		return false
+	}
+
+	return true
+}
+
+// hasScope reports whether scope is one of scopes
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientError writes the response of a failed client authentication
+// Clients using basic auth are asked to authenticate again
+func clientError(c *gin.Context, err error, basicAuth bool) {
+	oauthErr, ok := err.(*apperrors.OAuthError)
+
+	if !ok {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	if basicAuth && oauthErr.Code == apperrors.InvalidClient {
+		c.Header("WWW-Authenticate", `Basic realm="token"`)
+	}
+
+	c.JSON(oauthErr.Status(), oauthErr)
+}
+
+// clientCredentials returns the credentials of a client, which may be
+// sent with basic auth instead of the form. Basic auth credentials are
+// form encoded first (RFC 6749 2.3.1). It writes the error response
+// and returns false if the client used both methods
+func clientCredentials(c *gin.Context, formID string, formSecret string) (string, string, bool, bool) {
+	username, password, basicAuth := c.Request.BasicAuth()
+
+	if !basicAuth {
+		return formID, formSecret, false, true
+	}
+
+	clientID, idErr := url.QueryUnescape(username)
+	clientSecret, secretErr := url.QueryUnescape(password)
+
+	if idErr != nil || secretErr != nil || formSecret != "" || (formID != "" && formID != clientID) {
+		err := apperrors.NewOAuthError(apperrors.InvalidRequest, "The client must use a single authentication method")
+		c.JSON(err.Status(), err)
+		return "", "", true, false
+	}
+
+	return clientID, clientSecret, true, true
+}
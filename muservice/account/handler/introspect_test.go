@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIntrospect(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+
+	mockTokenService := new(mocks.MockTokenService)
+	mockOAuthService := new(mocks.MockOAuthService)
+
+	NewHandler(&Config{
+		Router:       router,
+		TokenService: mockTokenService,
+		OAuthService: mockOAuthService,
+	})
+
+	client := &models.ServiceClient{ClientID: "gateway", Scopes: []string{models.ScopeTokensIntrospect}}
+
+	mockOAuthService.On("AuthenticateClient", mock.Anything, "gateway", "asecret").Return(client, nil)
+	mockOAuthService.On("AuthenticateClient", mock.Anything, "billing", "asecret").Return(&models.ServiceClient{ClientID: "billing", Scopes: []string{}}, nil)
+	mockOAuthService.On("AuthenticateClient", mock.Anything, "gateway", "wrong").Return(nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Client authentication failed"))
+	mockOAuthService.On("AuthenticateClient", mock.Anything, "", "").Return(nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Client authentication failed"))
+
+	newRequest := func(form url.Values) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request
+	}
+
+	t.Run("Active token", func(t *testing.T) {
+		introspection := &models.TokenIntrospection{
+			Active:    true,
+			TokenType: models.TokenTypeRefreshToken,
+			Subject:   "auser",
+			ExpiresAt: 1700000000,
+		}
+
+		mockTokenService.On("IntrospectToken", mock.Anything, "atoken").Return(introspection, nil)
+
+		request := newRequest(url.Values{
+			"token":           {"atoken"},
+			"token_type_hint": {"refresh_token"},
+		})
+		request.SetBasicAuth("gateway", "asecret")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(introspection)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	})
+
+	t.Run("Credentials in the form", func(t *testing.T) {
+		mockTokenService.On("IntrospectToken", mock.Anything, "aninvalidtoken").Return(&models.TokenIntrospection{Active: false}, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest(url.Values{
+			"token":         {"aninvalidtoken"},
+			"client_id":     {"gateway"},
+			"client_secret": {"asecret"},
+		}))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `{"active":false}`, rr.Body.String())
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		request := newRequest(url.Values{
+			"token": {"atoken"},
+		})
+		request.SetBasicAuth("gateway", "wrong")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"error":"invalid_client"`)
+		assert.Equal(t, `Basic realm="token"`, rr.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Unauthenticated client", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest(url.Values{
+			"token": {"atoken"},
+		}))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Client without introspection scope", func(t *testing.T) {
+		request := newRequest(url.Values{
+			"token": {"abillingtoken"},
+		})
+		request.SetBasicAuth("billing", "asecret")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"error":"unauthorized_client"`)
+		mockTokenService.AssertNotCalled(t, "IntrospectToken", mock.Anything, "abillingtoken")
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		request := newRequest(url.Values{})
+		request.SetBasicAuth("gateway", "asecret")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"error":"invalid_request"`)
+	})
+
+	mockTokenService.AssertExpectations(t)
+}
+
+func TestRevoke(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+
+	mockTokenService := new(mocks.MockTokenService)
+	mockOAuthService := new(mocks.MockOAuthService)
+
+	NewHandler(&Config{
+		Router:       router,
+		TokenService: mockTokenService,
+		OAuthService: mockOAuthService,
+	})
+
+	mockOAuthService.On("AuthenticatePublicClient", mock.Anything, "aclient").Return(&models.OAuthClient{ClientID: "aclient"}, nil)
+	mockOAuthService.On("AuthenticatePublicClient", mock.Anything, "unknown").Return(nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Unknown client_id"))
+	mockOAuthService.On("AuthenticateClient", mock.Anything, "gateway", "asecret").Return(&models.ServiceClient{ClientID: "gateway", Scopes: []string{models.ScopeTokensRevoke}}, nil)
+	mockOAuthService.On("AuthenticateClient", mock.Anything, "billing", "asecret").Return(&models.ServiceClient{ClientID: "billing", Scopes: []string{}}, nil)
+	mockOAuthService.On("AuthenticateClient", mock.Anything, "gateway", "wrong").Return(nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Client authentication failed"))
+
+	newRequest := func(clientID string, token string) *http.Request {
+		form := url.Values{"token": {token}, "client_id": {clientID}}
+		request, _ := http.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request
+	}
+
+	t.Run("Revoked by public client", func(t *testing.T) {
+		mockTokenService.On("RevokeToken", mock.Anything, "aclient", "arefreshtoken", false).Return(nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("aclient", "arefreshtoken"))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockTokenService.AssertCalled(t, "RevokeToken", mock.Anything, "aclient", "arefreshtoken", false)
+	})
+
+	t.Run("Revoked by service client with tokens:revoke", func(t *testing.T) {
+		mockTokenService.On("RevokeToken", mock.Anything, "gateway", "afirstpartytoken", true).Return(nil)
+
+		request := newRequest("", "afirstpartytoken")
+		request.SetBasicAuth("gateway", "asecret")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockTokenService.AssertCalled(t, "RevokeToken", mock.Anything, "gateway", "afirstpartytoken", true)
+	})
+
+	t.Run("Service client without tokens:revoke", func(t *testing.T) {
+		mockTokenService.On("RevokeToken", mock.Anything, "billing", "abillingtoken", false).Return(nil)
+
+		request := newRequest("", "abillingtoken")
+		request.SetBasicAuth("billing", "asecret")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		// it may only revoke its own tokens
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockTokenService.AssertCalled(t, "RevokeToken", mock.Anything, "billing", "abillingtoken", false)
+	})
+
+	t.Run("Token of another client", func(t *testing.T) {
+		mockTokenService.
+			On("RevokeToken", mock.Anything, "aclient", "atokenofanotherclient", false).
+			Return(apperrors.NewOAuthError(apperrors.UnauthorizedClient, "The token was not issued to the client"))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("aclient", "atokenofanotherclient"))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"error":"unauthorized_client"`)
+	})
+
+	t.Run("Unsupported token type", func(t *testing.T) {
+		mockTokenService.
+			On("RevokeToken", mock.Anything, "aclient", "anidtoken", false).
+			Return(apperrors.NewOAuthError(apperrors.UnsupportedTokenType, "Only refresh tokens can be revoked"))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("aclient", "anidtoken"))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"error":"unsupported_token_type"`)
+	})
+
+	t.Run("Unknown client", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest("unknown", "anothertoken"))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockTokenService.AssertNotCalled(t, "RevokeToken", mock.Anything, mock.Anything, "anothertoken", mock.Anything)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		request := newRequest("", "anothertoken")
+		request.SetBasicAuth("gateway", "wrong")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, `Basic realm="token"`, rr.Header().Get("WWW-Authenticate"))
+		mockOAuthService.AssertNotCalled(t, "AuthenticatePublicClient", mock.Anything, "gateway")
+		mockTokenService.AssertNotCalled(t, "RevokeToken", mock.Anything, mock.Anything, "anothertoken", mock.Anything)
+	})
+
+	t.Run("JSON body", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/revoke", strings.NewReader(`{"token":"arefreshtoken","client_id":"aclient"}`))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
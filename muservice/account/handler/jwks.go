@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS handler publishes the public keys other
+// services can use to verify our id tokens
+func (h *Handler) JWKS(c *gin.Context) {
+	// allow verifiers to cache keys for a while, they should
+	// only fetch the set again when they see an unknown kid
+	c.Header("Cache-Control", "public, max-age=300")
+
+	c.JSON(http.StatusOK, h.TokenService.JWKS())
+}
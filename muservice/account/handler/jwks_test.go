@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWKS(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	mockJWKS := &models.JWKS{
+		Keys: []models.JWK{
+			{
+				Kty: "RSA",
+				Use: "sig",
+				Alg: "RS256",
+				Kid: "active",
+				N:   "modulus",
+				E:   "AQAB",
+			},
+		},
+	}
+
+	mockTokenService := new(mocks.MockTokenService)
+	mockTokenService.On("JWKS").Return(mockJWKS)
+
+	rr := httptest.NewRecorder()
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:       router,
+		TokenService: mockTokenService,
+	})
+
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(rr, request)
+
+	respBody, err := json.Marshal(mockJWKS)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, respBody, rr.Body.Bytes())
+	mockTokenService.AssertExpectations(t)
+}
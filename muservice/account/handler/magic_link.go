@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+type signinMagicReq struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// SigninMagic handler sends a sign in link to an email address
+// The response is the same whether or not the email address
+// is registered, errors are only logged
+func (h *Handler) SigninMagic(c *gin.Context) {
+	var req signinMagicReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.UserService.SendMagicLink(ctx, req.Email); err != nil {
+		log.Printf("Failed to send magic link: %v\n", err.Error())
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if the email address can sign in, a sign in link was sent",
+	})
+}
+
+type signinMagicVerifyReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// SigninMagicVerify handler signs a user in with the token of a magic link
+func (h *Handler) SigninMagicVerify(c *gin.Context) {
+	var req signinMagicVerifyReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	u, err := h.UserService.SigninWithMagicLink(ctx, req.Token)
+
+	if err != nil {
+		log.Printf("Failed to sign in with magic link: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	// a magic link only proves access to the email address
+	if h.requireMFA(c, u) {
+		return
+	}
+
+	tokens, err := h.TokenService.NewPairFromUser(ctx, u, "")
+
+	if err != nil {
+		log.Printf("Failed to create tokens for user: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+	})
+}
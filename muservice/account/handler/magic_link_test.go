@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSigninMagic(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	mockUserService := new(mocks.MockUserService)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:      router,
+		UserService: mockUserService,
+	})
+
+	t.Run("Invalid email", func(t *testing.T) {
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "notanemail",
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/signin/magic", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "SendMagicLink", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Errors are not reported", func(t *testing.T) {
+		mockUserService.On("SendMagicLink", mock.Anything, "bob@bob.com").Return(apperrors.NewInternal())
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "bob@bob.com",
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/signin/magic", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		mockUserService.AssertExpectations(t)
+	})
+}
+
+func TestSigninMagicVerify(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+	mockUser := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+	}
+
+	newRequest := func() *http.Request {
+		reqBody, _ := json.Marshal(gin.H{
+			"token": "amagiclinktoken",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signin/magic/verify", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		return request
+	}
+
+	t.Run("Invalid token", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+
+		router := gin.Default()
+		NewHandler(&Config{
+			Router:       router,
+			UserService:  mockUserService,
+			TokenService: mockTokenService,
+		})
+
+		mockError := apperrors.NewAuthorization("Invalid magic link")
+		mockUserService.On("SigninWithMagicLink", mock.Anything, "amagiclinktoken").Return(nil, mockError)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockTokenService.AssertNotCalled(t, "NewPairFromUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+
+		router := gin.Default()
+		NewHandler(&Config{
+			Router:       router,
+			UserService:  mockUserService,
+			TokenService: mockTokenService,
+		})
+
+		mockTokenPair := &models.TokenPair{
+			IDToken:      models.IDToken{SS: "idToken"},
+			RefreshToken: models.RefreshToken{SS: "refreshToken"},
+		}
+
+		mockUserService.On("SigninWithMagicLink", mock.Anything, "amagiclinktoken").Return(mockUser, nil)
+		mockTokenService.On("NewPairFromUser", mock.Anything, mockUser, "").Return(mockTokenPair, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		respBody, _ := json.Marshal(gin.H{
+			"tokens": mockTokenPair,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Second factor required", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockMFAService := new(mocks.MockMFAService)
+
+		router := gin.Default()
+		NewHandler(&Config{
+			Router:       router,
+			UserService:  mockUserService,
+			TokenService: mockTokenService,
+			MFAService:   mockMFAService,
+		})
+
+		mockUserService.On("SigninWithMagicLink", mock.Anything, "amagiclinktoken").Return(mockUser, nil)
+		mockMFAService.On("IsEnabled", mock.Anything, uid).Return(true, nil)
+		mockMFAService.On("NewPendingToken", mock.Anything, mockUser).Return("anmfatoken", nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		respBody, _ := json.Marshal(gin.H{
+			"mfaRequired": true,
+			"mfaToken":    "anmfatoken",
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockTokenService.AssertNotCalled(t, "NewPairFromUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
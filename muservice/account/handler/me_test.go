@@ -32,7 +32,7 @@ func TestMe(t *testing.T) {
 		}
 
 		mockUserService := new(mocks.MockUserService)
-		mockUserService.On("Get", mock.AnythingOfType("*context.emptyCtx"), uid).Return(mockUserResp, nil)
+		mockUserService.On("Get", mock.AnythingOfType("*context.valueCtx"), uid).Return(mockUserResp, nil)
 
 		// a response recorder for getting written http response
 		rr := httptest.NewRecorder()
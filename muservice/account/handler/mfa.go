@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// mfaCodeReq holds a TOTP code or a recovery code
+type mfaCodeReq struct {
+	Code string `json:"code" binding:"required,lte=32"`
+}
+
+// MFAEnroll handler creates a new TOTP secret for the signed in user
+// It has to be confirmed with a code before it is used
+func (h *Handler) MFAEnroll(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	u := authUser.(*models.User)
+	ctx := c.Request.Context()
+
+	enrollment, err := h.MFAService.Enroll(ctx, u)
+
+	if err != nil {
+		log.Printf("Failed to enroll mfa for user: %v\n%v", u.UID, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enrollment": enrollment,
+	})
+}
+
+// MFAConfirm handler enables the second factor of the signed in user
+// The recovery codes are only returned by this response
+func (h *Handler) MFAConfirm(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req mfaCodeReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	codes, err := h.MFAService.ConfirmEnrollment(ctx, uid, req.Code)
+
+	if err != nil {
+		log.Printf("Failed to confirm mfa enrollment for user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recoveryCodes": codes,
+	})
+}
+
+// MFADisable handler removes the second factor of the signed in user
+func (h *Handler) MFADisable(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req mfaCodeReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	if err := h.MFAService.Disable(ctx, uid, req.Code); err != nil {
+		log.Printf("Failed to disable mfa for user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "two-factor authentication disabled successfully!",
+	})
+}
+
+// MFARecoveryCodes handler replaces the recovery codes of the signed in user
+func (h *Handler) MFARecoveryCodes(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req mfaCodeReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	codes, err := h.MFAService.RegenerateRecoveryCodes(ctx, uid, req.Code)
+
+	if err != nil {
+		log.Printf("Failed to regenerate recovery codes for user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recoveryCodes": codes,
+	})
+}
+
+type signinMFAReq struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"required,lte=32"`
+}
+
+// SigninMFA handler completes the sign in of a user with a second factor
+// It exchanges the mfa token returned by Signin and a code for tokens
+func (h *Handler) SigninMFA(c *gin.Context) {
+	var req signinMFAReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	pending, err := h.MFAService.ValidatePendingToken(req.MFAToken)
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	// codes are throttled like passwords, the counters
+	// were not reset when the password was accepted
+	if h.ThrottleService != nil {
+		if err := h.ThrottleService.Check(ctx, pending.Email, ip); err != nil {
+			log.Printf("Sign in attempt blocked for email: %v from ip: %v\n", pending.Email, ip)
+			setRetryAfter(c, err)
+			c.JSON(apperrors.Status(err), gin.H{
+				"error": err,
+			})
+			return
+		}
+	}
+
+	if err := h.MFAService.Verify(ctx, pending.UID, req.Code); err != nil {
+		log.Printf("Failed to verify second factor of user: %v\n%v", pending.UID, err)
+
+		if h.ThrottleService != nil && apperrors.Status(err) == http.StatusUnauthorized {
+			if err := h.ThrottleService.RecordFailure(ctx, pending.Email, ip); err != nil {
+				log.Printf("Failed to record failed sign in attempt: %v\n", err.Error())
+			}
+		}
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	if h.ThrottleService != nil {
+		if err := h.ThrottleService.RecordSuccess(ctx, pending.Email); err != nil {
+			log.Printf("Failed to reset failed sign in attempts: %v\n", err.Error())
+		}
+	}
+
+	u, err := h.UserService.Get(ctx, pending.UID)
+
+	if err != nil {
+		log.Printf("Unable to find user: %v\n%v", pending.UID, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	tokens, err := h.TokenService.NewPairFromUser(ctx, u, "")
+
+	if err != nil {
+		log.Printf("Failed to create tokens for user: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+	})
+}
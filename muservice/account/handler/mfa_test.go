@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMFAEnroll(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+	mockUser := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+	}
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", mockUser)
+	})
+
+	mockMFAService := new(mocks.MockMFAService)
+
+	NewHandler(&Config{
+		Router:     router,
+		MFAService: mockMFAService,
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		enrollment := &models.MFAEnrollment{
+			Secret: "JBSWY3DPEHPK3PXP",
+			URI:    "otpauth://totp/Account:bob@bob.com?secret=JBSWY3DPEHPK3PXP",
+		}
+		mockMFAService.On("Enroll", mock.Anything, mockUser).Return(enrollment, nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/mfa/enroll", nil)
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"enrollment": enrollment,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockMFAService.AssertExpectations(t)
+	})
+}
+
+func TestMFAConfirm(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	newRouter := func(mockMFAService *mocks.MockMFAService) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:     router,
+			MFAService: mockMFAService,
+		})
+
+		return router
+	}
+
+	newRequest := func(body gin.H) *http.Request {
+		reqBody, _ := json.Marshal(body)
+
+		request, _ := http.NewRequest(http.MethodPost, "/mfa/confirm", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		return request
+	}
+
+	t.Run("Missing code", func(t *testing.T) {
+		mockMFAService := new(mocks.MockMFAService)
+		router := newRouter(mockMFAService)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest(gin.H{}))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockMFAService.AssertNotCalled(t, "ConfirmEnrollment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid code", func(t *testing.T) {
+		mockMFAService := new(mocks.MockMFAService)
+		router := newRouter(mockMFAService)
+
+		mockError := apperrors.NewAuthorization("Invalid two-factor authentication code")
+		mockMFAService.On("ConfirmEnrollment", mock.Anything, uid, "123456").Return(nil, mockError)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest(gin.H{"code": "123456"}))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockMFAService.AssertExpectations(t)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockMFAService := new(mocks.MockMFAService)
+		router := newRouter(mockMFAService)
+
+		codes := []string{"aaaa-bbbb-cccc-dddd", "eeee-ffff-gggg-hhhh"}
+		mockMFAService.On("ConfirmEnrollment", mock.Anything, uid, "123456").Return(codes, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest(gin.H{"code": "123456"}))
+
+		respBody, _ := json.Marshal(gin.H{
+			"recoveryCodes": codes,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockMFAService.AssertExpectations(t)
+	})
+}
+
+func TestMFADisable(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", &models.User{
+			UID: uid,
+		})
+	})
+
+	mockMFAService := new(mocks.MockMFAService)
+
+	NewHandler(&Config{
+		Router:     router,
+		MFAService: mockMFAService,
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockMFAService.On("Disable", mock.Anything, uid, "aaaa-bbbb-cccc-dddd").Return(nil)
+
+		reqBody, _ := json.Marshal(gin.H{
+			"code": "aaaa-bbbb-cccc-dddd",
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/mfa/disable", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockMFAService.AssertExpectations(t)
+	})
+}
+
+func TestSigninWithMFA(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	email := "bob@bob.com"
+	password := "howdyhoneighbor!"
+
+	mockUserService := new(mocks.MockUserService)
+	mockTokenService := new(mocks.MockTokenService)
+	mockMFAService := new(mocks.MockMFAService)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:       router,
+		UserService:  mockUserService,
+		TokenService: mockTokenService,
+		MFAService:   mockMFAService,
+	})
+
+	mockUserService.On("Signin", mock.Anything, &models.User{Email: email, Passowrd: password}).Return(nil)
+	mockMFAService.On("IsEnabled", mock.Anything, mock.AnythingOfType("uuid.UUID")).Return(true, nil)
+	mockMFAService.On("NewPendingToken", mock.Anything, mock.AnythingOfType("*models.User")).Return("anmfatoken", nil)
+
+	reqBody, _ := json.Marshal(gin.H{
+		"email":    email,
+		"password": password,
+	})
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodPost, "/signin", bytes.NewBuffer(reqBody))
+	request.Header.Set("Content-Type", "application/json")
+
+	router.ServeHTTP(rr, request)
+
+	respBody, _ := json.Marshal(gin.H{
+		"mfaRequired": true,
+		"mfaToken":    "anmfatoken",
+	})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, respBody, rr.Body.Bytes())
+	mockMFAService.AssertExpectations(t)
+	mockTokenService.AssertNotCalled(t, "NewPairFromUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSigninMFA(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+	pending := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+	}
+
+	newRouter := func(mockUserService *mocks.MockUserService, mockTokenService *mocks.MockTokenService, mockMFAService *mocks.MockMFAService, mockThrottleService *mocks.MockThrottleService) *gin.Engine {
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router:          router,
+			UserService:     mockUserService,
+			TokenService:    mockTokenService,
+			MFAService:      mockMFAService,
+			ThrottleService: mockThrottleService,
+		})
+
+		return router
+	}
+
+	newRequest := func() *http.Request {
+		reqBody, _ := json.Marshal(gin.H{
+			"mfaToken": "anmfatoken",
+			"code":     "123456",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signin/mfa", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		return request
+	}
+
+	t.Run("Invalid mfa token", func(t *testing.T) {
+		mockMFAService := new(mocks.MockMFAService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(nil, nil, mockMFAService, mockThrottleService)
+
+		mockError := apperrors.NewAuthorization("Unable to verify mfa token")
+		mockMFAService.On("ValidatePendingToken", "anmfatoken").Return(nil, mockError)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockMFAService.AssertNotCalled(t, "Verify", mock.Anything, mock.Anything, mock.Anything)
+		mockThrottleService.AssertNotCalled(t, "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid code is recorded", func(t *testing.T) {
+		mockMFAService := new(mocks.MockMFAService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(nil, nil, mockMFAService, mockThrottleService)
+
+		mockError := apperrors.NewAuthorization("Invalid two-factor authentication code")
+		mockMFAService.On("ValidatePendingToken", "anmfatoken").Return(pending, nil)
+		mockMFAService.On("Verify", mock.Anything, uid, "123456").Return(mockError)
+		mockThrottleService.On("Check", mock.Anything, pending.Email, mock.AnythingOfType("string")).Return(nil)
+		mockThrottleService.On("RecordFailure", mock.Anything, pending.Email, mock.AnythingOfType("string")).Return(nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockMFAService.AssertExpectations(t)
+		mockThrottleService.AssertExpectations(t)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockMFAService := new(mocks.MockMFAService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(mockUserService, mockTokenService, mockMFAService, mockThrottleService)
+
+		mockUser := &models.User{
+			UID:   uid,
+			Email: pending.Email,
+			Name:  "Bob",
+		}
+		mockTokenPair := &models.TokenPair{
+			IDToken:      models.IDToken{SS: "idToken"},
+			RefreshToken: models.RefreshToken{SS: "refreshToken"},
+		}
+
+		mockMFAService.On("ValidatePendingToken", "anmfatoken").Return(pending, nil)
+		mockMFAService.On("Verify", mock.Anything, uid, "123456").Return(nil)
+		mockThrottleService.On("Check", mock.Anything, pending.Email, mock.AnythingOfType("string")).Return(nil)
+		mockThrottleService.On("RecordSuccess", mock.Anything, pending.Email).Return(nil)
+		mockUserService.On("Get", mock.Anything, uid).Return(mockUser, nil)
+		mockTokenService.On("NewPairFromUser", mock.Anything, mockUser, "").Return(mockTokenPair, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		respBody, _ := json.Marshal(gin.H{
+			"tokens": mockTokenPair,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockMFAService.AssertExpectations(t)
+		mockThrottleService.AssertExpectations(t)
+		mockUserService.AssertExpectations(t)
+		mockTokenService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKey only lets requests pass which provide key
+// in the X-Admin-Key header
+func AdminKey(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Admin-Key")
+
+		if key == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+			err := apperrors.NewAuthorization("Must provide a valid X-Admin-Key header")
+			c.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthService extracts a service client from the Authorization header,
+// which holds an access token of the client_credentials grant in the
+// form "Bearer token". It is the counterpart of AuthUser for requests
+// of other services. The token must be granted all of scopes, and is
+// set to the context as "client"
+func AuthService(s models.TokenService, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenHeader := strings.Split(c.GetHeader("Authorization"), "Bearer ")
+
+		if len(tokenHeader) < 2 {
+			err := apperrors.NewAuthorization("Must provide Authorization header with format `Bearer {token}`")
+
+			c.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		accessToken, err := s.ValidateServiceToken(tokenHeader[1])
+
+		if err != nil {
+			err := apperrors.NewAuthorization("Provided token is invalid")
+			c.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		if !grantsScopes(accessToken.Scopes, scopes) {
+			err := apperrors.NewForbidden("Provided token was not granted the required scope")
+			c.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("client", accessToken)
+
+		c.Next()
+	}
+}
@@ -27,7 +27,11 @@ type invalidArgument struct {
 // AuthUser extracts a user from the Authorization header
 // which is of the form "Bearer token"
 // It sets the user to the context if the user exists
-func AuthUser(s models.TokenService) gin.HandlerFunc {
+// If scopes are given, OAuth access tokens and API keys granted all
+// of them are accepted too, and the granted scopes are set to the
+// context as "scopes". Only the uid of the user is known for access
+// tokens, API keys are resolved to the stored user
+func AuthUser(s models.TokenService, scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h := authHeader{}
 		// bind Authorization Header to h and check for validation errors
@@ -77,9 +81,67 @@ func AuthUser(s models.TokenService) gin.HandlerFunc {
 			return
 		}
 
+		// API keys are sent as Bearer tokens too
+		// they're only accepted by routes which require scopes
+		if strings.HasPrefix(idTokenHeader[1], models.APIKeyPrefix) {
+			if len(scopes) == 0 {
+				err := apperrors.NewAuthorization("API keys are not accepted for this request")
+				c.JSON(err.Status(), gin.H{
+					"error": err,
+				})
+				c.Abort()
+				return
+			}
+
+			user, apiKey, err := s.ValidateAPIKey(c.Request.Context(), idTokenHeader[1])
+
+			if err != nil {
+				err := apperrors.NewAuthorization("Provided token is invalid")
+				c.JSON(err.Status(), gin.H{
+					"error": err,
+				})
+				c.Abort()
+				return
+			}
+
+			if !grantsScopes(apiKey.Scopes, scopes) {
+				err := apperrors.NewForbidden("Provided token was not granted the required scope")
+				c.JSON(err.Status(), gin.H{
+					"error": err,
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("user", user)
+			c.Set("scopes", []string(apiKey.Scopes))
+
+			c.Next()
+			return
+		}
+
 		// validate ID token here
 		user, err := s.ValidateIDToken(idTokenHeader[1])
 
+		if err != nil && len(scopes) > 0 {
+			if accessToken, atErr := s.ValidateAccessToken(idTokenHeader[1]); atErr == nil {
+				if !grantsScopes(accessToken.Scopes, scopes) {
+					err := apperrors.NewForbidden("Provided token was not granted the required scope")
+					c.JSON(err.Status(), gin.H{
+						"error": err,
+					})
+					c.Abort()
+					return
+				}
+
+				c.Set("user", &models.User{UID: accessToken.UID})
+				c.Set("scopes", accessToken.Scopes)
+
+				c.Next()
+				return
+			}
+		}
+
 		if err != nil {
 			err := apperrors.NewAuthorization("Provided token is invalid")
 			c.JSON(err.Status(), gin.H{
@@ -94,3 +156,23 @@ func AuthUser(s models.TokenService) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// grantsScopes reports whether every scope of required is in granted
+func grantsScopes(granted []string, required []string) bool {
+	for _, r := range required {
+		found := false
+
+		for _, g := range granted {
+			if g == r {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUserAgentLength limits the user agent stored with sessions
+const maxUserAgentLength = 256
+
+// ClientInfo adds the user agent and IP of the client to
+// the request context, so services can record them
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAgent := c.Request.UserAgent()
+		if len(userAgent) > maxUserAgentLength {
+			userAgent = userAgent[:maxUserAgentLength]
+		}
+
+		ctx := models.WithClientInfo(c.Request.Context(), models.ClientInfo{
+			UserAgent: userAgent,
+			IP:        c.ClientIP(),
+		})
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits the requests made to a route per identity
+// The identity is the user set by AuthUser if present, otherwise the
+// client IP, which must only be taken from X-Forwarded-For if the request
+// comes from a trusted proxy. name separates the limits of different routes
+// If the limiter fails requests are let through
+func RateLimit(limiter models.RateLimiter, name string, limit models.RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := name + ":ip:" + c.ClientIP()
+
+		if user, exists := c.Get("user"); exists {
+			if u, ok := user.(*models.User); ok {
+				key = name + ":user:" + u.UID.String()
+			}
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), key, limit)
+
+		if err != nil {
+			log.Printf("Unable to check rate limit for %s: %v\n", key, err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+		c.Header("RateLimit-Reset", seconds(res.ResetAfter))
+
+		if !res.Allowed {
+			c.Header("Retry-After", seconds(res.RetryAfter))
+
+			err := apperrors.NewTooManyRequests(res.RetryAfter)
+			c.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// seconds formats d in whole seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail rejects users whose email address is not verified
+// It must be used after AuthUser, which sets the user to the context
+// The user is loaded from the service, as access tokens only carry the
+// uid and id tokens may have been minted before the email was verified
+func RequireVerifiedEmail(s models.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+
+		if !exists {
+			err := apperrors.NewInternal()
+			c.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		uid := user.(*models.User).UID
+		u, err := s.Get(c.Request.Context(), uid)
+
+		if err != nil {
+			log.Printf("Unable to find user: %v\n%v", uid, err)
+			c.JSON(apperrors.Status(err), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		if !u.EmailVerified {
+			err := apperrors.NewForbidden("Email address must be verified")
+			c.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
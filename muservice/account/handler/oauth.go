@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeReq holds the parameters of an authorization request
+// which are sent in the query, or in the body when consenting
+type authorizeReq struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`
+}
+
+func (r *authorizeReq) toModel() *models.AuthorizeRequest {
+	return &models.AuthorizeRequest{
+		ResponseType:        r.ResponseType,
+		ClientID:            r.ClientID,
+		RedirectURI:         r.RedirectURI,
+		Scope:               r.Scope,
+		State:               r.State,
+		CodeChallenge:       r.CodeChallenge,
+		CodeChallengeMethod: r.CodeChallengeMethod,
+		Nonce:               r.Nonce,
+	}
+}
+
+// Authorize handler starts an OAuth authorization for the signed in
+// user. The response either asks for the user's consent or holds the
+// uri to redirect the user to. It is called by the authorization page
+// of the frontend, which is what clients send the user to
+func (h *Handler) Authorize(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req authorizeReq
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Error binding authorization request: %v\n", err)
+		err := apperrors.NewBadRequest("client_id is required")
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	result, err := h.OAuthService.Authorize(c.Request.Context(), user.(*models.User).UID, req.toModel())
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// consentReq is an authorization request along
+// with the decision of the user
+type consentReq struct {
+	authorizeReq
+	Approved bool `json:"approved"`
+}
+
+// AuthorizeConsent handler records whether the signed in user
+// approved an authorization request. The response holds the
+// uri to redirect the user to
+func (h *Handler) AuthorizeConsent(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req consentReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	result, err := h.OAuthService.Consent(c.Request.Context(), user.(*models.User).UID, req.toModel(), req.Approved)
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// tokenReq holds the form parameters of the token endpoint
+type tokenReq struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// OAuthToken handler is the OAuth token endpoint. Requests are
+// form encoded, responses and errors are in the OAuth format
+func (h *Handler) OAuthToken(c *gin.Context) {
+	// token responses must not be cached
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req tokenReq
+
+	if c.ContentType() != "application/x-www-form-urlencoded" || c.ShouldBind(&req) != nil {
+		err := apperrors.NewOAuthError(apperrors.InvalidRequest, "The request must be form encoded")
+		c.JSON(err.Status(), err)
+		return
+	}
+
+	clientID, clientSecret, basicAuth, ok := clientCredentials(c, req.ClientID, req.ClientSecret)
+
+	if !ok {
+		return
+	}
+
+	req.ClientID = clientID
+	req.ClientSecret = clientSecret
+
+	resp, err := h.OAuthService.Token(c.Request.Context(), &models.TokenRequest{
+		GrantType:    req.GrantType,
+		Code:         req.Code,
+		RedirectURI:  req.RedirectURI,
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		CodeVerifier: req.CodeVerifier,
+		RefreshToken: req.RefreshToken,
+		Scope:        req.Scope,
+	})
+
+	if oauthErr, ok := err.(*apperrors.OAuthError); ok {
+		log.Printf("Token request of client: %s failed: %v\n", req.ClientID, oauthErr)
+
+		if oauthErr.Code == apperrors.InvalidClient && basicAuth {
+			c.Header("WWW-Authenticate", `Basic realm="token"`)
+		}
+
+		c.JSON(oauthErr.Status(), oauthErr)
+		return
+	}
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+type createClientReq struct {
+	ClientID     string   `json:"clientId"`
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirectUris" binding:"required,min=1"`
+	Scopes       []string `json:"scopes"`
+}
+
+// CreateOAuthClient handler registers an OAuth client
+func (h *Handler) CreateOAuthClient(c *gin.Context) {
+	var req createClientReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     req.ClientID,
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       req.Scopes,
+	}
+
+	if err := h.OAuthService.CreateClient(c.Request.Context(), client); err != nil {
+		log.Printf("Failed to create oauth client: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"client": client,
+	})
+}
+
+// OAuthClients handler lists the registered OAuth clients
+func (h *Handler) OAuthClients(c *gin.Context) {
+	clients, err := h.OAuthService.ListClients(c.Request.Context())
+
+	if err != nil {
+		log.Printf("Failed to list oauth clients: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clients": clients,
+	})
+}
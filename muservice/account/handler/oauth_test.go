@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuthorize(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", &models.User{
+			UID: uid,
+		})
+	})
+
+	mockOAuthService := new(mocks.MockOAuthService)
+
+	NewHandler(&Config{
+		Router:       router,
+		OAuthService: mockOAuthService,
+	})
+
+	authorizeReq := &models.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "aclient",
+		RedirectURI:         "https://client.example.com/callback",
+		Scope:               "read",
+		State:               "astate",
+		CodeChallenge:       "achallenge",
+		CodeChallengeMethod: "S256",
+	}
+
+	t.Run("Consent required", func(t *testing.T) {
+		result := &models.AuthorizeResult{
+			ConsentRequired: true,
+			Client:          &models.OAuthClient{ClientID: "aclient", Name: "A Client"},
+			Scopes:          []string{"read"},
+		}
+		mockOAuthService.On("Authorize", mock.Anything, uid, authorizeReq).Return(result, nil)
+
+		params := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {"aclient"},
+			"redirect_uri":          {"https://client.example.com/callback"},
+			"scope":                 {"read"},
+			"state":                 {"astate"},
+			"code_challenge":        {"achallenge"},
+			"code_challenge_method": {"S256"},
+		}
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(result)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Missing client id", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/authorize?response_type=code", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Approved", func(t *testing.T) {
+		result := &models.AuthorizeResult{
+			RedirectTo: "https://client.example.com/callback?code=acode&state=astate",
+		}
+		mockOAuthService.On("Consent", mock.Anything, uid, authorizeReq, true).Return(result, nil)
+
+		reqBody, _ := json.Marshal(gin.H{
+			"response_type":         "code",
+			"client_id":             "aclient",
+			"redirect_uri":          "https://client.example.com/callback",
+			"scope":                 "read",
+			"state":                 "astate",
+			"code_challenge":        "achallenge",
+			"code_challenge_method": "S256",
+			"approved":              true,
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/authorize", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(result)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+}
+
+func TestOAuthToken(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+
+	mockOAuthService := new(mocks.MockOAuthService)
+
+	NewHandler(&Config{
+		Router:       router,
+		OAuthService: mockOAuthService,
+	})
+
+	newRequest := func(form url.Values) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		resp := &models.OAuthTokenResponse{
+			AccessToken:  "anaccesstoken",
+			TokenType:    "Bearer",
+			ExpiresIn:    900,
+			RefreshToken: "arefreshtoken",
+			Scope:        "read",
+		}
+
+		mockOAuthService.On("Token", mock.Anything, &models.TokenRequest{
+			GrantType:    "authorization_code",
+			Code:         "acode",
+			RedirectURI:  "https://client.example.com/callback",
+			ClientID:     "aclient",
+			CodeVerifier: "averifier",
+		}).Return(resp, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {"acode"},
+			"redirect_uri":  {"https://client.example.com/callback"},
+			"client_id":     {"aclient"},
+			"code_verifier": {"averifier"},
+		}))
+
+		respBody, _ := json.Marshal(resp)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	})
+
+	t.Run("OAuth error", func(t *testing.T) {
+		mockOAuthService.On("Token", mock.Anything, &models.TokenRequest{
+			GrantType: "refresh_token",
+			ClientID:  "anotherclient",
+		}).Return(nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Unknown client_id"))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest(url.Values{
+			"grant_type": {"refresh_token"},
+			"client_id":  {"anotherclient"},
+		}))
+
+		respBody, _ := json.Marshal(gin.H{
+			"error":             "invalid_client",
+			"error_description": "Unknown client_id",
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Client credentials with basic auth", func(t *testing.T) {
+		resp := &models.OAuthTokenResponse{
+			AccessToken: "aservicetoken",
+			TokenType:   "Bearer",
+			ExpiresIn:   900,
+			Scope:       "users:read",
+		}
+
+		mockOAuthService.On("Token", mock.Anything, &models.TokenRequest{
+			GrantType:    "client_credentials",
+			ClientID:     "billing",
+			ClientSecret: "a secret",
+			Scope:        "users:read",
+		}).Return(resp, nil)
+
+		request := newRequest(url.Values{
+			"grant_type": {"client_credentials"},
+			"scope":      {"users:read"},
+		})
+		// credentials are form encoded before they're sent with basic auth
+		request.SetBasicAuth("billing", "a+secret")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(resp)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Failed basic auth", func(t *testing.T) {
+		mockOAuthService.On("Token", mock.Anything, &models.TokenRequest{
+			GrantType:    "client_credentials",
+			ClientID:     "billing",
+			ClientSecret: "wrong",
+		}).Return(nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Client authentication failed"))
+
+		request := newRequest(url.Values{
+			"grant_type": {"client_credentials"},
+		})
+		request.SetBasicAuth("billing", "wrong")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, `Basic realm="token"`, rr.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Two authentication methods", func(t *testing.T) {
+		request := newRequest(url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_secret": {"asecret"},
+		})
+		request.SetBasicAuth("billing", "asecret")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"error":"invalid_request"`)
+	})
+
+	t.Run("JSON body", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/token", strings.NewReader(`{"grant_type":"authorization_code"}`))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"error":"invalid_request"`)
+	})
+}
+
+func TestCreateOAuthClient(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+
+	mockOAuthService := new(mocks.MockOAuthService)
+
+	NewHandler(&Config{
+		Router:       router,
+		OAuthService: mockOAuthService,
+		AdminKey:     "anadminkey",
+	})
+
+	mockOAuthService.
+		On("CreateClient", mock.Anything, mock.MatchedBy(func(c *models.OAuthClient) bool {
+			return c.Name == "A Client" && len(c.RedirectURIs) == 1
+		})).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*models.OAuthClient).ClientID = "aclient"
+		}).
+		Return(nil)
+
+	reqBody, _ := json.Marshal(gin.H{
+		"name":         "A Client",
+		"redirectUris": []string{"https://client.example.com/callback"},
+		"scopes":       []string{"read"},
+	})
+
+	t.Run("Without admin key", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/admin/oauth/clients", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockOAuthService.AssertNotCalled(t, "CreateClient", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Created", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/admin/oauth/clients", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Admin-Key", "anadminkey")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"clientId":"aclient"`)
+	})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenIDConfiguration handler publishes the OpenID Connect discovery
+// document, so OIDC client libraries can find our endpoints and keys
+func (h *Handler) OpenIDConfiguration(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+
+	c.JSON(http.StatusOK, h.OpenIDConfig)
+}
+
+// Userinfo handler returns the standard claims of a user
+// OAuth clients only receive the claims of their granted scopes
+func (h *Handler) Userinfo(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	// users signed in with an id token see all of their claims
+	scopes := []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail}
+	if granted, ok := c.Get("scopes"); ok {
+		scopes = granted.([]string)
+	}
+
+	uid := user.(*models.User).UID
+
+	ctx := c.Request.Context()
+	u, err := h.UserService.Get(ctx, uid)
+
+	if err != nil {
+		log.Printf("Unable to find user: %v\n%v", uid, err)
+		e := apperrors.NewNotFound("user", uid.String())
+
+		c.JSON(e.Status(), gin.H{
+			"error": e,
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	c.JSON(http.StatusOK, models.NewUserInfo(u, scopes))
+}
+
+// newOpenIDConfiguration creates the discovery document of the routes
+// enabled by c. Endpoints are relative to the issuer, which is the
+// URL BaseURL is served at. The issuer is advertised as given, as it has to
+// match the iss of our tokens exactly. The authorization code flow is only
+// advertised if the frontend has a page handling authorization requests,
+// as /authorize itself can't sign the user in
+func newOpenIDConfiguration(c *Config) *models.OpenIDConfiguration {
+	issuer := strings.TrimSuffix(c.Issuer, "/")
+
+	config := &models.OpenIDConfiguration{
+		Issuer:                           c.Issuer,
+		UserinfoEndpoint:                 issuer + "/userinfo",
+		JWKSURI:                          issuer + "/.well-known/jwks.json",
+		ScopesSupported:                  []string{},
+		ResponseTypesSupported:           []string{},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat",
+			"name", "picture", "website", "email", "email_verified",
+		},
+	}
+
+	if c.OAuthService != nil {
+		config.TokenEndpoint = issuer + "/token"
+		config.GrantTypesSupported = []string{models.GrantTypeClientCredentials}
+		config.TokenEndpointAuthMethodsSupported = []string{"client_secret_basic", "client_secret_post"}
+
+		// id tokens are issued with the code when openid is granted
+		if c.AuthorizationURL != "" {
+			config.AuthorizationEndpoint = c.AuthorizationURL
+			config.ScopesSupported = []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail}
+			config.ResponseTypesSupported = []string{"code"}
+			config.GrantTypesSupported = []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials}
+			config.TokenEndpointAuthMethodsSupported = []string{"none", "client_secret_basic", "client_secret_post"}
+			config.CodeChallengeMethodsSupported = []string{"S256"}
+		}
+
+		// only service clients may introspect tokens, public
+		// clients revoke their tokens with their client_id
+		config.IntrospectionEndpoint = issuer + "/introspect"
+		config.RevocationEndpoint = issuer + "/revoke"
+		config.IntrospectionEndpointAuthMethods = []string{"client_secret_basic", "client_secret_post"}
+		config.RevocationEndpointAuthMethods = []string{"none", "client_secret_basic", "client_secret_post"}
+	}
+
+	return config
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOpenIDConfiguration(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Describes enabled endpoints", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router:           router,
+			OAuthService:     new(mocks.MockOAuthService),
+			BaseURL:          "/api/account",
+			Issuer:           "https://example.com/api/account",
+			AuthorizationURL: "https://example.com/authorize",
+		})
+
+		request, err := http.NewRequest(http.MethodGet, "/api/account/.well-known/openid-configuration", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		config := &models.OpenIDConfiguration{}
+		err = json.Unmarshal(rr.Body.Bytes(), config)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "https://example.com/api/account", config.Issuer)
+		assert.Equal(t, "https://example.com/authorize", config.AuthorizationEndpoint)
+		assert.Equal(t, "https://example.com/api/account/token", config.TokenEndpoint)
+		assert.Equal(t, "https://example.com/api/account/introspect", config.IntrospectionEndpoint)
+		assert.Equal(t, "https://example.com/api/account/revoke", config.RevocationEndpoint)
+		assert.Equal(t, "https://example.com/api/account/userinfo", config.UserinfoEndpoint)
+		assert.Equal(t, "https://example.com/api/account/.well-known/jwks.json", config.JWKSURI)
+		assert.Equal(t, []string{"RS256"}, config.IDTokenSigningAlgValuesSupported)
+		assert.Equal(t, []string{"S256"}, config.CodeChallengeMethodsSupported)
+		assert.Contains(t, config.ScopesSupported, models.ScopeOpenID)
+		assert.Equal(t, []string{"code"}, config.ResponseTypesSupported)
+	})
+
+	t.Run("Without authorization page", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router:       router,
+			OAuthService: new(mocks.MockOAuthService),
+			Issuer:       "https://example.com",
+		})
+
+		request, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		config := &models.OpenIDConfiguration{}
+		err = json.Unmarshal(rr.Body.Bytes(), config)
+		assert.NoError(t, err)
+
+		// only service clients can get tokens
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, config.AuthorizationEndpoint)
+		assert.Equal(t, "https://example.com/token", config.TokenEndpoint)
+		assert.Equal(t, []string{models.GrantTypeClientCredentials}, config.GrantTypesSupported)
+		assert.NotContains(t, config.ScopesSupported, models.ScopeOpenID)
+		assert.Empty(t, config.ResponseTypesSupported)
+	})
+
+	t.Run("Without OAuth", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router: router,
+			Issuer: "https://example.com",
+		})
+
+		request, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		config := &models.OpenIDConfiguration{}
+		err = json.Unmarshal(rr.Body.Bytes(), config)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "https://example.com/userinfo", config.UserinfoEndpoint)
+		assert.Empty(t, config.AuthorizationEndpoint)
+		assert.Empty(t, config.TokenEndpoint)
+	})
+
+	t.Run("Issuer advertised as given", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router: router,
+			Issuer: "https://example.com/",
+		})
+
+		request, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		config := &models.OpenIDConfiguration{}
+		err = json.Unmarshal(rr.Body.Bytes(), config)
+		assert.NoError(t, err)
+
+		// it must match the iss of our tokens
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "https://example.com/", config.Issuer)
+		assert.Equal(t, "https://example.com/userinfo", config.UserinfoEndpoint)
+	})
+
+	t.Run("Disabled without issuer", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router: router,
+		})
+
+		request, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestUserinfo(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	mockUser := &models.User{
+		UID:           uid,
+		Email:         "bob@bob.com",
+		EmailVerified: true,
+		Name:          "Bobby Bobson",
+		ImageURL:      "https://example.com/bob.png",
+	}
+
+	// setup creates a router with the user and, if not nil, the scopes
+	// of an OAuth access token set to the context
+	setup := func(mockUserService *mocks.MockUserService, scopes []string) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{UID: uid})
+
+			if scopes != nil {
+				c.Set("scopes", scopes)
+			}
+		})
+
+		NewHandler(&Config{
+			Router:      router,
+			UserService: mockUserService,
+		})
+
+		return router
+	}
+
+	t.Run("Signed in user receives all claims", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockUserService.On("Get", mock.AnythingOfType("*context.valueCtx"), uid).Return(mockUser, nil)
+
+		rr := httptest.NewRecorder()
+		router := setup(mockUserService, nil)
+
+		request, err := http.NewRequest(http.MethodGet, "/userinfo", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		emailVerified := true
+		respBody, err := json.Marshal(&models.UserInfo{
+			Subject:       uid.String(),
+			Name:          mockUser.Name,
+			Picture:       mockUser.ImageURL,
+			Email:         mockUser.Email,
+			EmailVerified: &emailVerified,
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Claims of granted scopes", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockUserService.On("Get", mock.AnythingOfType("*context.valueCtx"), uid).Return(mockUser, nil)
+
+		rr := httptest.NewRecorder()
+		router := setup(mockUserService, []string{models.ScopeOpenID, models.ScopeEmail})
+
+		request, err := http.NewRequest(http.MethodPost, "/userinfo", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		emailVerified := true
+		respBody, err := json.Marshal(&models.UserInfo{
+			Subject:       uid.String(),
+			Email:         mockUser.Email,
+			EmailVerified: &emailVerified,
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Only openid scope", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockUserService.On("Get", mock.AnythingOfType("*context.valueCtx"), uid).Return(mockUser, nil)
+
+		rr := httptest.NewRecorder()
+		router := setup(mockUserService, []string{models.ScopeOpenID})
+
+		request, err := http.NewRequest(http.MethodGet, "/userinfo", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		respBody, err := json.Marshal(gin.H{
+			"sub": uid.String(),
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockUserService.On("Get", mock.AnythingOfType("*context.valueCtx"), uid).Return(nil, apperrors.NewNotFound("uid", uid.String()))
+
+		rr := httptest.NewRecorder()
+		router := setup(mockUserService, nil)
+
+		request, err := http.NewRequest(http.MethodGet, "/userinfo", nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		mockUserService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+type forgotPasswordReq struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword handler sends a password reset email
+// The response is the same whether or not the email address
+// is registered, errors are only logged
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req forgotPasswordReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.UserService.ForgotPassword(ctx, req.Email); err != nil {
+		log.Printf("Failed to send password reset email: %v\n", err.Error())
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "if the email address is registered, a password reset email was sent",
+	})
+}
+
+type resetPasswordReq struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,gte=6,lte=30"`
+}
+
+// ResetPassword handler sets a new password using a password
+// reset token. The user is signed out everywhere
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req resetPasswordReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.UserService.ResetPassword(ctx, req.Token, req.Password); err != nil {
+		log.Printf("Failed to reset password: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password reset successfully!",
+	})
+}
+
+// changePasswordReq holds the refresh token of the session
+// changing the password, which stays signed in
+type changePasswordReq struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,gte=6,lte=30"`
+	RefreshToken    string `json:"refreshToken" binding:"required"`
+}
+
+// ChangePassword handler sets a new password for the signed in user
+// Every other session of the user is signed out
+func (h *Handler) ChangePassword(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req changePasswordReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	refreshToken, err := h.TokenService.ValidateRefreshToken(req.RefreshToken)
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	// the session to keep must be one of the user's own
+	if refreshToken.UID != uid {
+		log.Printf("Refresh token uid: %v does not match signed in user: %v\n", refreshToken.UID, uid)
+		err := apperrors.NewAuthorization("Invalid refresh token")
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	err = h.UserService.ChangePassword(ctx, uid, req.CurrentPassword, req.NewPassword, refreshToken.ID.String())
+
+	if err != nil {
+		log.Printf("Failed to change password of user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password changed successfully!",
+	})
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestForgotPassword(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	mockUserService := new(mocks.MockUserService)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:      router,
+		UserService: mockUserService,
+	})
+
+	t.Run("Invalid email", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "notanemail",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/password/forgot", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "ForgotPassword", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Accepted", func(t *testing.T) {
+		mockUserService.On("ForgotPassword", mock.Anything, "bob@bob.com").Return(nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "bob@bob.com",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/password/forgot", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		mockUserService.AssertCalled(t, "ForgotPassword", mock.Anything, "bob@bob.com")
+	})
+
+	t.Run("Accepted on error", func(t *testing.T) {
+		mockUserService.On("ForgotPassword", mock.Anything, "alice@alice.com").Return(fmt.Errorf("Some error down the call chain"))
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email": "alice@alice.com",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/password/forgot", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	mockUserService := new(mocks.MockUserService)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:      router,
+		UserService: mockUserService,
+	})
+
+	t.Run("Password too short", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"token":    "valid",
+			"password": "short",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/password/reset", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		mockError := apperrors.NewAuthorization("Invalid password reset token")
+		mockUserService.On("ResetPassword", mock.Anything, "invalid", "avalidpassword").Return(mockError)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"token":    "invalid",
+			"password": "avalidpassword",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/password/reset", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService.On("ResetPassword", mock.Anything, "valid", "avalidpassword").Return(nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"token":    "valid",
+			"password": "avalidpassword",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/password/reset", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockUserService.AssertCalled(t, "ResetPassword", mock.Anything, "valid", "avalidpassword")
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	newRouter := func(mockUserService *mocks.MockUserService, mockTokenService *mocks.MockTokenService) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:       router,
+			UserService:  mockUserService,
+			TokenService: mockTokenService,
+		})
+
+		return router
+	}
+
+	t.Run("New password too short", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockUserService, mockTokenService)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"currentPassword": "howdyhoneighbor!",
+			"newPassword":     "short",
+			"refreshToken":    "current",
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/password", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Refresh token of another user", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockUserService, mockTokenService)
+
+		refreshToken := &models.RefreshToken{
+			SS:  "someoneelses",
+			ID:  uuid.New(),
+			UID: uuid.New(),
+		}
+
+		mockTokenService.On("ValidateRefreshToken", refreshToken.SS).Return(refreshToken, nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"currentPassword": "howdyhoneighbor!",
+			"newPassword":     "anewpassword",
+			"refreshToken":    refreshToken.SS,
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/password", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockUserService.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Wrong current password", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockUserService, mockTokenService)
+
+		refreshToken := &models.RefreshToken{
+			SS:  "current",
+			ID:  uuid.New(),
+			UID: uid,
+		}
+
+		mockError := apperrors.NewAuthorization("Invalid current password")
+
+		mockTokenService.On("ValidateRefreshToken", refreshToken.SS).Return(refreshToken, nil)
+		mockUserService.On("ChangePassword", mock.Anything, uid, "wrongpassword", "anewpassword", refreshToken.ID.String()).Return(mockError)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"currentPassword": "wrongpassword",
+			"newPassword":     "anewpassword",
+			"refreshToken":    refreshToken.SS,
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/password", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockUserService, mockTokenService)
+
+		refreshToken := &models.RefreshToken{
+			SS:  "current",
+			ID:  uuid.New(),
+			UID: uid,
+		}
+
+		mockTokenService.On("ValidateRefreshToken", refreshToken.SS).Return(refreshToken, nil)
+		mockUserService.On("ChangePassword", mock.Anything, uid, "howdyhoneighbor!", "anewpassword", refreshToken.ID.String()).Return(nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"currentPassword": "howdyhoneighbor!",
+			"newPassword":     "anewpassword",
+			"refreshToken":    refreshToken.SS,
+		})
+
+		request, _ := http.NewRequest(http.MethodPut, "/password", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockUserService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/NetworkPy/muserv/muservice/account/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRateLimit(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	t.Run("Limited by IP on public route", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockRateLimiter := new(mocks.MockRateLimiter)
+
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router:      router,
+			UserService: mockUserService,
+			RateLimiter: mockRateLimiter,
+		})
+
+		mockRateLimiter.
+			On("Allow", mock.Anything, "signup:ip:192.0.2.1", DefaultRateLimits["signup"]).
+			Return(&models.RateLimitResult{
+				Allowed:    false,
+				Limit:      5,
+				Remaining:  0,
+				RetryAfter: 1500 * time.Millisecond,
+				ResetAfter: 30 * time.Minute,
+			}, nil)
+
+		reqBody, _ := json.Marshal(gin.H{
+			"email":    "bob@bob.com",
+			"password": "avalidpassword",
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+		request.RemoteAddr = "192.0.2.1:1234"
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "5", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1800", rr.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+		mockRateLimiter.AssertExpectations(t)
+		mockUserService.AssertNotCalled(t, "Signup", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Allowed by user on authenticated route", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockRateLimiter := new(mocks.MockRateLimiter)
+
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:      router,
+			UserService: mockUserService,
+			RateLimiter: mockRateLimiter,
+		})
+
+		mockRateLimiter.
+			On("Allow", mock.Anything, fmt.Sprintf("verify-email:user:%s", uid), DefaultRateLimits["verify-email"]).
+			Return(&models.RateLimitResult{
+				Allowed:    true,
+				Limit:      3,
+				Remaining:  2,
+				ResetAfter: 20 * time.Minute,
+			}, nil)
+		mockUserService.On("SendVerificationEmail", mock.Anything, uid).Return(nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/verify-email/send", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		assert.Equal(t, "3", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1200", rr.Header().Get("RateLimit-Reset"))
+		mockRateLimiter.AssertExpectations(t)
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Limiter error lets request through", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockRateLimiter := new(mocks.MockRateLimiter)
+
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:      router,
+			UserService: mockUserService,
+			RateLimiter: mockRateLimiter,
+		})
+
+		mockRateLimiter.
+			On("Allow", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+			Return(nil, apperrors.NewInternal())
+		mockUserService.On("SendVerificationEmail", mock.Anything, uid).Return(nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/verify-email/send", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Custom limits leave other routes unlimited", func(t *testing.T) {
+		mockRateLimiter := new(mocks.MockRateLimiter)
+
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router:      router,
+			RateLimiter: mockRateLimiter,
+			RateLimits: map[string]models.RateLimit{
+				"tokens": {Rate: 1, Period: time.Minute, Burst: 1},
+			},
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer([]byte("{}")))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockRateLimiter.AssertNotCalled(t, "Allow", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Forged X-Forwarded-For does not get a fresh bucket", func(t *testing.T) {
+		router := gin.Default()
+		assert.NoError(t, TrustProxies(router, nil))
+
+		NewHandler(&Config{
+			Router:      router,
+			RateLimiter: repository.NewMemoryRateLimiter(),
+			RateLimits: map[string]models.RateLimit{
+				"signup": {Rate: 1, Period: time.Hour, Burst: 1},
+			},
+		})
+
+		signup := func(forwardedFor string) int {
+			rr := httptest.NewRecorder()
+			request, _ := http.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer([]byte("{}")))
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set("X-Forwarded-For", forwardedFor)
+			request.RemoteAddr = "192.0.2.1:1234"
+
+			router.ServeHTTP(rr, request)
+
+			return rr.Code
+		}
+
+		assert.Equal(t, http.StatusBadRequest, signup("203.0.113.1"))
+		assert.Equal(t, http.StatusTooManyRequests, signup("203.0.113.2"))
+	})
+}
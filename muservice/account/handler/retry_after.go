@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// setRetryAfter sets the Retry-After header, in whole
+// seconds, for errors which carry a retry time
+func setRetryAfter(c *gin.Context, err error) {
+	var e *apperrors.Error
+
+	if errors.As(err, &e) && e.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(e.RetryAfter.Seconds())), 10))
+	}
+}
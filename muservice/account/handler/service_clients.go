@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+type createServiceClientReq struct {
+	ClientID string   `json:"clientId"`
+	Name     string   `json:"name" binding:"required"`
+	Scopes   []string `json:"scopes"`
+}
+
+// CreateServiceClient handler registers a service client
+// The secret is only sent in this response
+func (h *Handler) CreateServiceClient(c *gin.Context) {
+	var req createServiceClientReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	client := &models.ServiceClient{
+		ClientID: req.ClientID,
+		Name:     req.Name,
+		Scopes:   req.Scopes,
+	}
+
+	secret, err := h.OAuthService.CreateServiceClient(c.Request.Context(), client)
+
+	if err != nil {
+		log.Printf("Failed to create service client: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"client":       client,
+		"clientSecret": secret,
+	})
+}
+
+// ServiceClients handler lists the registered service clients
+func (h *Handler) ServiceClients(c *gin.Context) {
+	clients, err := h.OAuthService.ListServiceClients(c.Request.Context())
+
+	if err != nil {
+		log.Printf("Failed to list service clients: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clients": clients,
+	})
+}
+
+// DeleteServiceClient handler removes a service client
+func (h *Handler) DeleteServiceClient(c *gin.Context) {
+	clientID := c.Param("id")
+
+	if err := h.OAuthService.DeleteServiceClient(c.Request.Context(), clientID); err != nil {
+		log.Printf("Failed to delete service client: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "service client deleted successfully!",
+	})
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServiceClients(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	router := gin.Default()
+
+	mockOAuthService := new(mocks.MockOAuthService)
+
+	NewHandler(&Config{
+		Router:       router,
+		OAuthService: mockOAuthService,
+		AdminKey:     "anadminkey",
+	})
+
+	t.Run("Create without admin key", func(t *testing.T) {
+		reqBody, _ := json.Marshal(gin.H{
+			"name": "billing",
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/admin/oauth/service-clients", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockOAuthService.AssertNotCalled(t, "CreateServiceClient", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Created with secret", func(t *testing.T) {
+		mockOAuthService.
+			On("CreateServiceClient", mock.Anything, mock.MatchedBy(func(c *models.ServiceClient) bool {
+				return c.Name == "billing" && len(c.Scopes) == 1
+			})).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*models.ServiceClient).ClientID = "billing"
+			}).
+			Return("asecret", nil)
+
+		reqBody, _ := json.Marshal(gin.H{
+			"name":   "billing",
+			"scopes": []string{"users:read"},
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/admin/oauth/service-clients", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Admin-Key", "anadminkey")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"clientId":"billing"`)
+		assert.Contains(t, rr.Body.String(), `"clientSecret":"asecret"`)
+		assert.NotContains(t, rr.Body.String(), "secretHash")
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	})
+
+	t.Run("Create without name", func(t *testing.T) {
+		reqBody, _ := json.Marshal(gin.H{
+			"scopes": []string{"users:read"},
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/admin/oauth/service-clients", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Admin-Key", "anadminkey")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		clients := []*models.ServiceClient{
+			{ClientID: "billing", Name: "billing", SecretHash: "ahash", Scopes: []string{"users:read"}},
+		}
+
+		mockOAuthService.On("ListServiceClients", mock.Anything).Return(clients, nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/admin/oauth/service-clients", nil)
+		request.Header.Set("X-Admin-Key", "anadminkey")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"clients": clients,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		assert.NotContains(t, rr.Body.String(), "ahash")
+	})
+
+	t.Run("Delete unknown client", func(t *testing.T) {
+		mockOAuthService.On("DeleteServiceClient", mock.Anything, "unknown").Return(apperrors.NewNotFound("client_id", "unknown"))
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/admin/oauth/service-clients/unknown", nil)
+		request.Header.Set("X-Admin-Key", "anadminkey")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		mockOAuthService.On("DeleteServiceClient", mock.Anything, "billing").Return(nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/admin/oauth/service-clients/billing", nil)
+		request.Header.Set("X-Admin-Key", "anadminkey")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockOAuthService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Sessions handler lists the devices the user is signed in on
+func (h *Handler) Sessions(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := user.(*models.User).UID
+
+	sessions, err := h.TokenService.ListSessions(c.Request.Context(), uid)
+
+	if err != nil {
+		log.Printf("Failed to list sessions of user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+	})
+}
+
+// DeleteSession handler signs the user out of a single session
+func (h *Handler) DeleteSession(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := user.(*models.User).UID
+	sessionID := c.Param("id")
+
+	if _, err := uuid.Parse(sessionID); err != nil {
+		err := apperrors.NewBadRequest("Invalid session id")
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	if err := h.TokenService.RevokeSession(c.Request.Context(), uid, sessionID); err != nil {
+		log.Printf("Failed to revoke session: %v of user: %v\n%v", sessionID, uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "session signed out successfully!",
+	})
+}
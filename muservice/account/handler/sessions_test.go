@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSessions(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	newRouter := func(mockTokenService *mocks.MockTokenService) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:       router,
+			TokenService: mockTokenService,
+		})
+
+		return router
+	}
+
+	t.Run("List", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		now := time.Now().UTC()
+		mockSessions := []*models.Session{{
+			ID:              uuid.New().String(),
+			UserAgent:       "Mozilla/5.0",
+			IP:              "10.0.0.1",
+			CreatedAt:       now,
+			LastRefreshedAt: now,
+		}}
+
+		mockTokenService.On("ListSessions", mock.AnythingOfType("*context.valueCtx"), uid).Return(mockSessions, nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/sessions", nil)
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"sessions": mockSessions,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		sessionID := uuid.New().String()
+		mockTokenService.On("RevokeSession", mock.AnythingOfType("*context.valueCtx"), uid, sessionID).Return(nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/sessions/"+sessionID, nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockTokenService.AssertExpectations(t)
+	})
+
+	t.Run("Revoke unknown session", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		sessionID := uuid.New().String()
+		mockTokenService.On("RevokeSession", mock.AnythingOfType("*context.valueCtx"), uid, sessionID).Return(apperrors.NewNotFound("session", sessionID))
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/sessions/"+sessionID, nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Invalid session id", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodDelete, "/sessions/notauuid", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockTokenService.AssertNotCalled(t, "RevokeSession", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSessionsRecordClient(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	mockUserService := new(mocks.MockUserService)
+	mockTokenService := new(mocks.MockTokenService)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:       router,
+		UserService:  mockUserService,
+		TokenService: mockTokenService,
+	})
+
+	mockUser := &models.User{UID: uuid.New(), Email: "bob@bob.com"}
+	mockTokenPair := &models.TokenPair{
+		IDToken:      models.IDToken{SS: "idToken"},
+		RefreshToken: models.RefreshToken{SS: "refreshToken"},
+	}
+
+	var client models.ClientInfo
+
+	mockUserService.On("SigninWithMagicLink", mock.Anything, "amagiclinktoken").Return(mockUser, nil)
+	mockTokenService.
+		On("NewPairFromUser", mock.Anything, mockUser, "").
+		Run(func(args mock.Arguments) {
+			client = models.ClientInfoFromContext(args.Get(0).(context.Context))
+		}).
+		Return(mockTokenPair, nil)
+
+	reqBody, _ := json.Marshal(gin.H{
+		"token": "amagiclinktoken",
+	})
+
+	rr := httptest.NewRecorder()
+	request, _ := http.NewRequest(http.MethodPost, "/signin/magic/verify", bytes.NewBuffer(reqBody))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Mozilla/5.0")
+	request.RemoteAddr = "10.0.0.1:1234"
+
+	router.ServeHTTP(rr, request)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.ClientInfo{UserAgent: "Mozilla/5.0", IP: "10.0.0.1"}, client)
+}
@@ -31,16 +31,48 @@ func (h *Handler) Signin(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	// attempts are blocked before the password is checked
+	if h.ThrottleService != nil {
+		if err := h.ThrottleService.Check(ctx, req.Email, ip); err != nil {
+			log.Printf("Sign in attempt blocked for email: %v from ip: %v\n", req.Email, ip)
+			setRetryAfter(c, err)
+			c.JSON(apperrors.Status(err), gin.H{
+				"error": err,
+			})
+			return
+		}
+	}
+
 	err := h.UserService.Signin(ctx, u)
 
 	if err != nil {
 		log.Printf("Failed to sign in user: %v\n", err.Error())
+
+		if h.ThrottleService != nil && apperrors.Status(err) == http.StatusUnauthorized {
+			if err := h.ThrottleService.RecordFailure(ctx, req.Email, ip); err != nil {
+				log.Printf("Failed to record failed sign in attempt: %v\n", err.Error())
+			}
+		}
+
 		c.JSON(apperrors.Status(err), gin.H{
 			"error": err,
 		})
 		return
 	}
 
+	// failed attempts are only reset once the second factor is verified
+	if h.requireMFA(c, u) {
+		return
+	}
+
+	if h.ThrottleService != nil {
+		if err := h.ThrottleService.RecordSuccess(ctx, req.Email); err != nil {
+			log.Printf("Failed to reset failed sign in attempts: %v\n", err.Error())
+		}
+	}
+
 	tokens, err := h.TokenService.NewPairFromUser(ctx, u, "")
 
 	if err != nil {
@@ -56,3 +88,44 @@ func (h *Handler) Signin(c *gin.Context) {
 		"tokens": tokens,
 	})
 }
+
+// requireMFA responds with a short lived mfa token instead of tokens if u has
+// a second factor, which is exchanged for tokens at /signin/mfa
+// It reports whether a response was written
+func (h *Handler) requireMFA(c *gin.Context, u *models.User) bool {
+	if h.MFAService == nil {
+		return false
+	}
+
+	ctx := c.Request.Context()
+
+	enabled, err := h.MFAService.IsEnabled(ctx, u.UID)
+
+	if err != nil {
+		log.Printf("Failed to check mfa of user: %v\n%v", u.UID, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return true
+	}
+
+	if !enabled {
+		return false
+	}
+
+	mfaToken, err := h.MFAService.NewPendingToken(ctx, u)
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return true
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mfaRequired": true,
+		"mfaToken":    mfaToken,
+	})
+	return true
+}
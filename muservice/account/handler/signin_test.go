@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
 	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
@@ -58,7 +59,7 @@ func TestSignin(t *testing.T) {
 		password := "pwdoesnotmatch123"
 
 		mockUSArgs := mock.Arguments{
-			mock.AnythingOfType("*context.emptyCtx"),
+			mock.AnythingOfType("*context.valueCtx"),
 			&models.User{
 				Email:    email,
 				Passowrd: password,
@@ -96,7 +97,7 @@ func TestSignin(t *testing.T) {
 		password := "cannotproducetoken"
 
 		mockUSArgs := mock.Arguments{
-			mock.AnythingOfType("*context.emptyCtx"),
+			mock.AnythingOfType("*context.valueCtx"),
 			&models.User{
 				Email:    email,
 				Passowrd: password,
@@ -104,7 +105,7 @@ func TestSignin(t *testing.T) {
 		}
 
 		mockTSArgs := mock.Arguments{
-			mock.AnythingOfType("*context.emptyCtx"),
+			mock.AnythingOfType("*context.valueCtx"),
 			&models.User{
 				Email:    email,
 				Passowrd: password,
@@ -145,7 +146,7 @@ func TestSignin(t *testing.T) {
 		password := "Motherlode1"
 
 		mockUSArgs := mock.Arguments{
-			mock.AnythingOfType("*context.emptyCtx"),
+			mock.AnythingOfType("*context.valueCtx"),
 			&models.User{
 				Email:    email,
 				Passowrd: password,
@@ -153,7 +154,7 @@ func TestSignin(t *testing.T) {
 		}
 
 		mockTSArgs := mock.Arguments{
-			mock.AnythingOfType("*context.emptyCtx"),
+			mock.AnythingOfType("*context.valueCtx"),
 			&models.User{
 				Email:    email,
 				Passowrd: password,
@@ -196,3 +197,159 @@ func TestSignin(t *testing.T) {
 		mockTokenService.AssertCalled(t, "NewPairFromUser", mockTSArgs...)
 	})
 }
+
+func TestSigninThrottle(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	email := "bob@bob.com"
+	password := "howdyhoneighbor!"
+
+	newRouter := func(mockUserService *mocks.MockUserService, mockTokenService *mocks.MockTokenService, mockThrottleService *mocks.MockThrottleService) *gin.Engine {
+		router := gin.Default()
+
+		NewHandler(&Config{
+			Router:          router,
+			UserService:     mockUserService,
+			TokenService:    mockTokenService,
+			ThrottleService: mockThrottleService,
+		})
+
+		return router
+	}
+
+	newRequest := func() *http.Request {
+		reqBody, _ := json.Marshal(gin.H{
+			"email":    email,
+			"password": password,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signin", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		return request
+	}
+
+	t.Run("Blocked", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(mockUserService, mockTokenService, mockThrottleService)
+
+		mockError := apperrors.NewTooManyRequests(1500 * time.Millisecond)
+		mockThrottleService.On("Check", mock.Anything, email, mock.AnythingOfType("string")).Return(mockError)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockUserService.AssertNotCalled(t, "Signin", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Failure is recorded", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(mockUserService, mockTokenService, mockThrottleService)
+
+		mockThrottleService.On("Check", mock.Anything, email, mock.AnythingOfType("string")).Return(nil)
+		mockThrottleService.On("RecordFailure", mock.Anything, email, mock.AnythingOfType("string")).Return(nil)
+		mockUserService.On("Signin", mock.Anything, mock.AnythingOfType("*models.User")).
+			Return(apperrors.NewAuthorization("Invalid email and password combination"))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockThrottleService.AssertExpectations(t)
+		mockThrottleService.AssertNotCalled(t, "RecordSuccess", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Internal errors are not recorded", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(mockUserService, mockTokenService, mockThrottleService)
+
+		mockThrottleService.On("Check", mock.Anything, email, mock.AnythingOfType("string")).Return(nil)
+		mockUserService.On("Signin", mock.Anything, mock.AnythingOfType("*models.User")).Return(apperrors.NewInternal())
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		mockThrottleService.AssertNotCalled(t, "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success resets attempts", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(mockUserService, mockTokenService, mockThrottleService)
+
+		mockTokenPair := &models.TokenPair{
+			IDToken:      models.IDToken{SS: "idToken"},
+			RefreshToken: models.RefreshToken{SS: "refreshToken"},
+		}
+
+		mockThrottleService.On("Check", mock.Anything, email, mock.AnythingOfType("string")).Return(nil)
+		mockThrottleService.On("RecordSuccess", mock.Anything, email).Return(nil)
+		mockUserService.On("Signin", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil)
+		mockTokenService.On("NewPairFromUser", mock.Anything, mock.AnythingOfType("*models.User"), "").Return(mockTokenPair, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockThrottleService.AssertExpectations(t)
+	})
+	t.Run("Forged X-Forwarded-For is ignored", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(mockUserService, mockTokenService, mockThrottleService)
+		assert.NoError(t, TrustProxies(router, nil))
+
+		mockError := apperrors.NewTooManyRequests(time.Minute)
+		mockThrottleService.On("Check", mock.Anything, email, "192.0.2.1").Return(mockError)
+
+		request := newRequest()
+		request.RemoteAddr = "192.0.2.1:1234"
+		request.Header.Set("X-Forwarded-For", "203.0.113.9")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		mockThrottleService.AssertExpectations(t)
+	})
+
+	t.Run("Client IP forwarded by trusted proxy", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		mockTokenService := new(mocks.MockTokenService)
+		mockThrottleService := new(mocks.MockThrottleService)
+		router := newRouter(mockUserService, mockTokenService, mockThrottleService)
+		assert.NoError(t, TrustProxies(router, []string{"10.0.0.0/8"}))
+
+		mockError := apperrors.NewTooManyRequests(time.Minute)
+		mockThrottleService.On("Check", mock.Anything, email, "192.0.2.1").Return(mockError)
+
+		// the proxy appends the address it was connected from
+		// to what the client sent
+		request := newRequest()
+		request.RemoteAddr = "10.0.0.2:1234"
+		request.Header.Set("X-Forwarded-For", "203.0.113.9, 192.0.2.1")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		mockThrottleService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// signoutReq is not exported
+// refreshToken identifies the session to sign out of and
+// is only optional when signing out of every session
+type signoutReq struct {
+	RefreshToken string `json:"refreshToken" binding:"required_without=Everywhere"`
+	Everywhere   bool   `json:"everywhere"`
+}
+
+// Signout handler
+func (h *Handler) Signout(c *gin.Context) {
+	user, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req signoutReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := user.(*models.User).UID
+	ctx := c.Request.Context()
+
+	if req.Everywhere {
+		if err := h.TokenService.SignoutAll(ctx, uid); err != nil {
+			c.JSON(apperrors.Status(err), gin.H{
+				"error": err,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "user signed out of all sessions successfully!",
+		})
+		return
+	}
+
+	refreshToken, err := h.TokenService.ValidateRefreshToken(req.RefreshToken)
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	// users may only sign out of their own sessions
+	if refreshToken.UID != uid {
+		log.Printf("Refresh token uid: %v does not match signed in user: %v\n", refreshToken.UID, uid)
+		err := apperrors.NewAuthorization("Invalid refresh token")
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	if err := h.TokenService.Signout(ctx, uid, refreshToken.ID.String()); err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user signed out successfully!",
+	})
+}
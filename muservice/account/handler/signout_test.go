@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSignout(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	// use a middleware to set context for test
+	// the only claims we care about in this test
+	// is the UID
+	newRouter := func(mockTokenService *mocks.MockTokenService) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:       router,
+			TokenService: mockTokenService,
+		})
+
+		return router
+	}
+
+	t.Run("Refresh token required", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signout", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockTokenService.AssertNotCalled(t, "Signout")
+		mockTokenService.AssertNotCalled(t, "SignoutAll")
+	})
+
+	t.Run("Refresh token of another user", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		refreshToken := &models.RefreshToken{
+			SS:  "someoneelses",
+			ID:  uuid.New(),
+			UID: uuid.New(),
+		}
+
+		mockTokenService.On("ValidateRefreshToken", refreshToken.SS).Return(refreshToken, nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"refreshToken": refreshToken.SS,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signout", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockTokenService.AssertNotCalled(t, "Signout", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Signout current session", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		refreshToken := &models.RefreshToken{
+			SS:  "current",
+			ID:  uuid.New(),
+			UID: uid,
+		}
+
+		mockTokenService.On("ValidateRefreshToken", refreshToken.SS).Return(refreshToken, nil)
+		mockTokenService.On("Signout", mock.Anything, uid, refreshToken.ID.String()).Return(nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"refreshToken": refreshToken.SS,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signout", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockTokenService.AssertExpectations(t)
+		mockTokenService.AssertNotCalled(t, "SignoutAll", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Signout everywhere", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		mockTokenService.On("SignoutAll", mock.Anything, uid).Return(nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"everywhere": true,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signout", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockTokenService.AssertExpectations(t)
+		mockTokenService.AssertNotCalled(t, "ValidateRefreshToken", mock.Anything)
+	})
+
+	t.Run("Error from SignoutAll", func(t *testing.T) {
+		mockTokenService := new(mocks.MockTokenService)
+		router := newRouter(mockTokenService)
+
+		mockError := apperrors.NewInternal()
+		mockTokenService.On("SignoutAll", mock.Anything, uid).Return(mockError)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"everywhere": true,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signout", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, mockError.Status(), rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+}
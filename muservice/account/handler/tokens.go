@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// tokensReq is not exported
+type tokensReq struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// Tokens handler exchanges a valid refresh token for a new
+// id and refresh token pair. The supplied refresh token is
+// removed from the valid list, so it can only be used once
+func (h *Handler) Tokens(c *gin.Context) {
+	var req tokensReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// verify refresh JWT
+	refreshToken, err := h.TokenService.ValidateRefreshToken(req.RefreshToken)
+
+	if err != nil {
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	// get up-to-date user
+	u, err := h.UserService.Get(ctx, refreshToken.UID)
+
+	if err != nil {
+		log.Printf("Unable to find user for refresh token uid: %v\n%v", refreshToken.UID, err)
+		e := apperrors.NewAuthorization("Unable to verify user from refresh token")
+
+		c.JSON(e.Status(), gin.H{
+			"error": e,
+		})
+		return
+	}
+
+	// create fresh pair of tokens, previous refresh token is removed
+	// from the valid list and rejected if it has already been removed
+	tokens, err := h.TokenService.NewPairFromUser(ctx, u, refreshToken.ID.String())
+
+	if err != nil {
+		log.Printf("Failed to create tokens for user: %+v. Error: %v\n", u, err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+	})
+}
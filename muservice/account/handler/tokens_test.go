@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTokens(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	mockTokenService := new(mocks.MockTokenService)
+	mockUserService := new(mocks.MockUserService)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:       router,
+		TokenService: mockTokenService,
+		UserService:  mockUserService,
+	})
+
+	// setup mock services for all cases
+	validTokenString := "valid"
+	invalidTokenString := "invalid"
+	usedTokenString := "used"
+	noUserTokenString := "nouser"
+
+	validToken := &models.RefreshToken{
+		SS:  validTokenString,
+		ID:  uuid.New(),
+		UID: uuid.New(),
+	}
+
+	usedToken := &models.RefreshToken{
+		SS:  usedTokenString,
+		ID:  uuid.New(),
+		UID: uuid.New(),
+	}
+
+	noUserToken := &models.RefreshToken{
+		SS:  noUserTokenString,
+		ID:  uuid.New(),
+		UID: uuid.New(),
+	}
+
+	mockTokenService.On("ValidateRefreshToken", validTokenString).Return(validToken, nil)
+	mockTokenService.On("ValidateRefreshToken", usedTokenString).Return(usedToken, nil)
+	mockTokenService.On("ValidateRefreshToken", noUserTokenString).Return(noUserToken, nil)
+	mockTokenService.On("ValidateRefreshToken", invalidTokenString).Return(nil, apperrors.NewAuthorization("Invalid refresh token"))
+
+	t.Run("Invalid request", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		// create a request body with invalid fields
+		reqBody, _ := json.Marshal(gin.H{
+			"notRefreshToken": validTokenString,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/tokens", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockTokenService.AssertNotCalled(t, "ValidateRefreshToken")
+		mockUserService.AssertNotCalled(t, "Get")
+		mockTokenService.AssertNotCalled(t, "NewPairFromUser")
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"refreshToken": invalidTokenString,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/tokens", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockTokenService.AssertCalled(t, "ValidateRefreshToken", invalidTokenString)
+		mockUserService.AssertNotCalled(t, "Get")
+		mockTokenService.AssertNotCalled(t, "NewPairFromUser")
+	})
+
+	t.Run("Failure to get user", func(t *testing.T) {
+		mockUserService.On("Get", mock.Anything, noUserToken.UID).Return(nil, fmt.Errorf("Some error down the call chain"))
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"refreshToken": noUserTokenString,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/tokens", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockUserService.AssertCalled(t, "Get", mock.Anything, noUserToken.UID)
+		mockTokenService.AssertNotCalled(t, "NewPairFromUser")
+	})
+
+	t.Run("Refresh token already used", func(t *testing.T) {
+		usedTokenUser := &models.User{
+			UID: usedToken.UID,
+		}
+
+		mockError := apperrors.NewAuthorization("Invalid refresh token")
+
+		mockUserService.On("Get", mock.Anything, usedToken.UID).Return(usedTokenUser, nil)
+		mockTokenService.On("NewPairFromUser", mock.Anything, usedTokenUser, usedToken.ID.String()).Return(nil, mockError)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"refreshToken": usedTokenString,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/tokens", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockTokenService.AssertCalled(t, "NewPairFromUser", mock.Anything, usedTokenUser, usedToken.ID.String())
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		validTokenUser := &models.User{
+			UID: validToken.UID,
+		}
+
+		mockTokenPair := &models.TokenPair{
+			IDToken:      models.IDToken{SS: "aNewIDToken"},
+			RefreshToken: models.RefreshToken{SS: "aNewRefreshToken"},
+		}
+
+		mockUserService.On("Get", mock.Anything, validToken.UID).Return(validTokenUser, nil)
+		mockTokenService.On("NewPairFromUser", mock.Anything, validTokenUser, validToken.ID.String()).Return(mockTokenPair, nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"refreshToken": validTokenString,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/tokens", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"tokens": mockTokenPair,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+		mockTokenService.AssertCalled(t, "NewPairFromUser", mock.Anything, validTokenUser, validToken.ID.String())
+	})
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+type verifyEmailReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail handler marks the email address a
+// verification token was sent to as verified
+// Tokens issued afterwards carry the verified email
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var req verifyEmailReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	u, err := h.UserService.VerifyEmail(ctx, req.Token)
+
+	if err != nil {
+		log.Printf("Failed to verify email: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": u,
+	})
+}
+
+// SendVerificationEmail handler sends a new verification
+// email to the signed in user
+func (h *Handler) SendVerificationEmail(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	if err := h.UserService.SendVerificationEmail(ctx, uid); err != nil {
+		log.Printf("Failed to send verification email to user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "verification email sent!",
+	})
+}
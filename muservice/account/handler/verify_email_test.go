@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	mockUserService := new(mocks.MockUserService)
+
+	router := gin.Default()
+
+	NewHandler(&Config{
+		Router:      router,
+		UserService: mockUserService,
+	})
+
+	t.Run("Token required", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{})
+
+		request, _ := http.NewRequest(http.MethodPost, "/verify-email", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockUserService.AssertNotCalled(t, "VerifyEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		mockError := apperrors.NewAuthorization("Invalid verification token")
+		mockUserService.On("VerifyEmail", mock.Anything, "invalid").Return(nil, mockError)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"token": "invalid",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/verify-email", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"error": mockError,
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockUser := &models.User{
+			UID:           uuid.New(),
+			Email:         "bob@bob.com",
+			EmailVerified: true,
+		}
+
+		mockUserService.On("VerifyEmail", mock.Anything, "valid").Return(mockUser, nil)
+
+		rr := httptest.NewRecorder()
+
+		reqBody, _ := json.Marshal(gin.H{
+			"token": "valid",
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/verify-email", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"user": mockUser,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+}
+
+func TestSendVerificationEmail(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	newRouter := func(mockUserService *mocks.MockUserService) *gin.Engine {
+		router := gin.Default()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &models.User{
+				UID: uid,
+			})
+		})
+
+		NewHandler(&Config{
+			Router:      router,
+			UserService: mockUserService,
+		})
+
+		return router
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		mockUserService.On("SendVerificationEmail", mock.Anything, uid).Return(nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/verify-email/send", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		mockUserService.AssertExpectations(t)
+	})
+
+	t.Run("Already verified", func(t *testing.T) {
+		mockUserService := new(mocks.MockUserService)
+		router := newRouter(mockUserService)
+
+		mockError := apperrors.NewBadRequest("Email address is already verified")
+		mockUserService.On("SendVerificationEmail", mock.Anything, uid).Return(mockError)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/verify-email/send", nil)
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
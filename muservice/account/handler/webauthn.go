@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/gin-gonic/gin"
+)
+
+// WebAuthnRegisterBegin handler returns the options to create
+// a passkey for the signed in user
+func (h *Handler) WebAuthnRegisterBegin(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	u := authUser.(*models.User)
+	ctx := c.Request.Context()
+
+	options, err := h.WebAuthnService.BeginRegistration(ctx, u)
+
+	if err != nil {
+		log.Printf("Failed to begin passkey registration for user: %v\n%v", u.UID, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"publicKey": options,
+	})
+}
+
+type webAuthnRegisterReq struct {
+	Name       string                            `json:"name" binding:"omitempty,lte=64"`
+	Credential models.CredentialCreationResponse `json:"credential" binding:"required"`
+}
+
+// WebAuthnRegisterFinish handler stores a passkey created
+// with the options of WebAuthnRegisterBegin
+func (h *Handler) WebAuthnRegisterFinish(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	var req webAuthnRegisterReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	credential, err := h.WebAuthnService.FinishRegistration(ctx, uid, req.Name, &req.Credential)
+
+	if err != nil {
+		log.Printf("Failed to register passkey for user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"credential": credential,
+	})
+}
+
+// WebAuthnCredentials handler lists the passkeys of the signed in user
+func (h *Handler) WebAuthnCredentials(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	credentials, err := h.WebAuthnService.ListCredentials(ctx, uid)
+
+	if err != nil {
+		log.Printf("Failed to list passkeys of user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentials,
+	})
+}
+
+// DeleteWebAuthnCredential handler removes a passkey of the signed in user
+func (h *Handler) DeleteWebAuthnCredential(c *gin.Context) {
+	authUser, exists := c.Get("user")
+
+	if !exists {
+		log.Printf("Unable to extract user from request context for unknown reason: %v\n", c)
+		err := apperrors.NewInternal()
+		c.JSON(err.Status(), gin.H{
+			"error": err,
+		})
+
+		return
+	}
+
+	uid := authUser.(*models.User).UID
+	ctx := c.Request.Context()
+
+	if err := h.WebAuthnService.DeleteCredential(ctx, uid, c.Param("id")); err != nil {
+		log.Printf("Failed to delete passkey of user: %v\n%v", uid, err)
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "passkey deleted successfully!",
+	})
+}
+
+// SigninPasskeyBegin handler returns the options to sign in with a passkey
+func (h *Handler) SigninPasskeyBegin(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	options, err := h.WebAuthnService.BeginLogin(ctx)
+
+	if err != nil {
+		log.Printf("Failed to begin passkey sign in: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"publicKey": options,
+	})
+}
+
+type signinPasskeyReq struct {
+	Credential models.CredentialAssertionResponse `json:"credential" binding:"required"`
+}
+
+// SigninPasskeyFinish handler signs a user in with a passkey
+// assertion created with the options of SigninPasskeyBegin
+func (h *Handler) SigninPasskeyFinish(c *gin.Context) {
+	var req signinPasskeyReq
+
+	if ok := bindData(c, &req); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	u, err := h.WebAuthnService.FinishLogin(ctx, &req.Credential)
+
+	if err != nil {
+		log.Printf("Failed to sign in with passkey: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	tokens, err := h.TokenService.NewPairFromUser(ctx, u, "")
+
+	if err != nil {
+		log.Printf("Failed to create tokens for user: %v\n", err.Error())
+
+		c.JSON(apperrors.Status(err), gin.H{
+			"error": err,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+	})
+}
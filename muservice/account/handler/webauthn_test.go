@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWebAuthnRegister(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+	mockUser := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+	}
+
+	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", mockUser)
+	})
+
+	mockWebAuthnService := new(mocks.MockWebAuthnService)
+
+	NewHandler(&Config{
+		Router:          router,
+		WebAuthnService: mockWebAuthnService,
+	})
+
+	t.Run("Begin", func(t *testing.T) {
+		options := &models.PublicKeyCredentialCreationOptions{
+			Challenge: "achallenge",
+			RP:        models.RelyingParty{ID: "example.com", Name: "Example"},
+		}
+		mockWebAuthnService.On("BeginRegistration", mock.Anything, mockUser).Return(options, nil)
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/webauthn/register/begin", nil)
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"publicKey": options,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+
+	t.Run("Finish with invalid body", func(t *testing.T) {
+		reqBody, _ := json.Marshal(gin.H{
+			"credential": gin.H{
+				"id":   "acredential",
+				"type": "password",
+			},
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/webauthn/register/finish", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockWebAuthnService.AssertNotCalled(t, "FinishRegistration", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Finish", func(t *testing.T) {
+		credentialResponse := &models.CredentialCreationResponse{
+			ID:    "acredential",
+			RawID: "acredential",
+			Type:  "public-key",
+			Response: models.AttestationResponse{
+				ClientDataJSON:    "clientdata",
+				AttestationObject: "attestation",
+			},
+		}
+		credential := &models.Credential{
+			ID:        "acredential",
+			UID:       uid,
+			Name:      "Laptop",
+			CreatedAt: time.Now().UTC().Truncate(time.Second),
+		}
+		mockWebAuthnService.On("FinishRegistration", mock.Anything, uid, "Laptop", credentialResponse).Return(credential, nil)
+
+		reqBody, _ := json.Marshal(gin.H{
+			"name":       "Laptop",
+			"credential": credentialResponse,
+		})
+
+		rr := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/webauthn/register/finish", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(rr, request)
+
+		respBody, _ := json.Marshal(gin.H{
+			"credential": credential,
+		})
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+}
+
+func TestSigninPasskey(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	uid, _ := uuid.NewRandom()
+
+	assertion := &models.CredentialAssertionResponse{
+		ID:    "acredential",
+		RawID: "acredential",
+		Type:  "public-key",
+		Response: models.AssertionResponse{
+			ClientDataJSON:    "clientdata",
+			AuthenticatorData: "authdata",
+			Signature:         "signature",
+		},
+	}
+
+	newRequest := func() *http.Request {
+		reqBody, _ := json.Marshal(gin.H{
+			"credential": assertion,
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/signin/passkey/finish", bytes.NewBuffer(reqBody))
+		request.Header.Set("Content-Type", "application/json")
+
+		return request
+	}
+
+	t.Run("Invalid assertion", func(t *testing.T) {
+		mockWebAuthnService := new(mocks.MockWebAuthnService)
+		mockTokenService := new(mocks.MockTokenService)
+
+		router := gin.Default()
+		NewHandler(&Config{
+			Router:          router,
+			TokenService:    mockTokenService,
+			WebAuthnService: mockWebAuthnService,
+		})
+
+		mockError := apperrors.NewAuthorization("Invalid credential")
+		mockWebAuthnService.On("FinishLogin", mock.Anything, assertion).Return(nil, mockError)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockTokenService.AssertNotCalled(t, "NewPairFromUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockWebAuthnService := new(mocks.MockWebAuthnService)
+		mockTokenService := new(mocks.MockTokenService)
+
+		router := gin.Default()
+		NewHandler(&Config{
+			Router:          router,
+			TokenService:    mockTokenService,
+			WebAuthnService: mockWebAuthnService,
+		})
+
+		mockUser := &models.User{UID: uid, Email: "bob@bob.com"}
+		mockTokenPair := &models.TokenPair{
+			IDToken:      models.IDToken{SS: "idToken"},
+			RefreshToken: models.RefreshToken{SS: "refreshToken"},
+		}
+
+		mockWebAuthnService.On("FinishLogin", mock.Anything, assertion).Return(mockUser, nil)
+		mockTokenService.On("NewPairFromUser", mock.Anything, mockUser, "").Return(mockTokenPair, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, newRequest())
+
+		respBody, _ := json.Marshal(gin.H{
+			"tokens": mockTokenPair,
+		})
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, respBody, rr.Body.Bytes())
+	})
+}
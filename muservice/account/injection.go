@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/NetworkPy/muserv/muservice/account/handler"
+	"github.com/NetworkPy/muserv/muservice/account/mailer"
+	"github.com/NetworkPy/muserv/muservice/account/models"
 	"github.com/NetworkPy/muserv/muservice/account/repository"
+	"github.com/NetworkPy/muserv/muservice/account/security"
 	"github.com/NetworkPy/muserv/muservice/account/service"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
@@ -27,11 +32,117 @@ func inject(d *dataSources) (*gin.Engine, error) {
 	 */
 	userRepository := repository.NewUserRepository(d.DB)
 	tokenRepository := repository.NewTokenRepository(d.RedisClient)
+	throttleRepository := repository.NewThrottleRepository(d.RedisClient)
+	mfaRepository := repository.NewMFARepository(d.DB)
+	credentialRepository := repository.NewCredentialRepository(d.DB)
+	oauthRepository := repository.NewOAuthRepository(d.DB)
+	apiKeyRepository := repository.NewAPIKeyRepository(d.DB)
+
+	// rate limits are shared through redis unless RATE_LIMIT_STORE
+	// is memory, which only suits a single instance
+	var rateLimiter models.RateLimiter
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "redis":
+		rateLimiter = repository.NewRedisRateLimiter(d.RedisClient)
+	case "memory":
+		rateLimiter = repository.NewMemoryRateLimiter()
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE: %s", store)
+	}
+
+	// profile images are stored on the local filesystem
+	// and served by this service below IMAGE_BASE_URL
+	imageDir := os.Getenv("IMAGE_DIR")
+	if imageDir == "" {
+		imageDir = "images"
+	}
+	imageBaseURL := os.Getenv("IMAGE_BASE_URL")
+
+	imageRepository, err := repository.NewLocalImageRepository(imageDir, imageBaseURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize image repository: %w", err)
+	}
+
 	/*
 	 * repository layer
 	 */
+	// new password hashes use PASSWORD_HASHER, existing
+	// hashes are migrated when their users sign in
+	if passwordHasher := os.Getenv("PASSWORD_HASHER"); passwordHasher != "" {
+		if err := security.SetDefaultHasher(passwordHasher); err != nil {
+			return nil, fmt.Errorf("could not set PASSWORD_HASHER: %w", err)
+		}
+	}
+
+	// emails are sent through SMTP_HOST, without one they are
+	// only kept in memory, which is useful for local development
+	var userMailer models.Mailer
+
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		smtpPort, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SMTP_PORT as int: %w", err)
+		}
+
+		userMailer = mailer.NewSMTPMailer(&mailer.SMTPConfig{
+			Host:     smtpHost,
+			Port:     smtpPort,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	} else {
+		log.Println("SMTP_HOST is not set, emails will not be delivered")
+		userMailer = mailer.NewMemoryMailer()
+	}
+
+	// verification tokens are signed with their own secret, so they
+	// can be invalidated without signing out every user. Deployments
+	// upgrading from before email verification have to add it
+	emailTokenSecret := os.Getenv("EMAIL_TOKEN_SECRET")
+	if emailTokenSecret == "" {
+		return nil, fmt.Errorf("EMAIL_TOKEN_SECRET must be set, see README.md")
+	}
+
+	var verifyEmailExp int64
+	if exp := os.Getenv("VERIFY_EMAIL_TOKEN_EXP"); exp != "" {
+		verifyEmailExp, err = strconv.ParseInt(exp, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse VERIFY_EMAIL_TOKEN_EXP as int: %w", err)
+		}
+	}
+
+	var passwordResetExp int64
+	if exp := os.Getenv("PASSWORD_RESET_TOKEN_EXP"); exp != "" {
+		passwordResetExp, err = strconv.ParseInt(exp, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse PASSWORD_RESET_TOKEN_EXP as int: %w", err)
+		}
+	}
+
+	var magicLinkExp int64
+	if exp := os.Getenv("MAGIC_LINK_TOKEN_EXP"); exp != "" {
+		magicLinkExp, err = strconv.ParseInt(exp, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse MAGIC_LINK_TOKEN_EXP as int: %w", err)
+		}
+	}
+
 	userService := service.NewUserService(&service.USConfig{
-		UserRepository: userRepository,
+		UserRepository:              userRepository,
+		ImageRepository:             imageRepository,
+		TokenRepository:             tokenRepository,
+		Mailer:                      userMailer,
+		EmailTokenSecret:            emailTokenSecret,
+		VerifyEmailExpirationSecs:   verifyEmailExp,
+		VerifyEmailURL:              os.Getenv("VERIFY_EMAIL_URL"),
+		PasswordResetExpirationSecs: passwordResetExp,
+		PasswordResetURL:            os.Getenv("PASSWORD_RESET_URL"),
+		MagicLinkExpirationSecs:     magicLinkExp,
+		MagicLinkURL:                os.Getenv("MAGIC_LINK_URL"),
+		MagicLinkSignup:             os.Getenv("MAGIC_LINK_SIGNUP") == "true",
 	})
 
 	// load rsa keys
@@ -61,6 +172,38 @@ func inject(d *dataSources) (*gin.Engine, error) {
 		return nil, fmt.Errorf("could not parse public key: %w", err)
 	}
 
+	if !privKey.PublicKey.Equal(pubKey) {
+		return nil, fmt.Errorf("public key does not match private key")
+	}
+
+	// load public keys of previous signing keys, which are only used
+	// to verify tokens issued before the signing key was rotated
+	var verifyKeys []*security.SigningKey
+
+	if verifyKeyFiles := os.Getenv("VERIFY_KEY_FILES"); verifyKeyFiles != "" {
+		for _, verifyKeyFile := range strings.Split(verifyKeyFiles, ",") {
+			verify, err := ioutil.ReadFile(strings.TrimSpace(verifyKeyFile))
+
+			if err != nil {
+				return nil, fmt.Errorf("could not read verify key pem file: %w", err)
+			}
+
+			verifyKey, err := jwt.ParseRSAPublicKeyFromPEM(verify)
+
+			if err != nil {
+				return nil, fmt.Errorf("could not parse verify key: %w", err)
+			}
+
+			verifyKeys = append(verifyKeys, security.NewVerifyingKey(verifyKey))
+		}
+	}
+
+	keySet, err := security.NewKeySet(security.NewSigningKey(privKey), verifyKeys...)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create key set: %w", err)
+	}
+
 	// load refresh token secret from env variable
 	refreshSecret := os.Getenv("REFRESH_SECRET")
 
@@ -77,18 +220,219 @@ func inject(d *dataSources) (*gin.Engine, error) {
 		return nil, fmt.Errorf("could not parse REFRESH_TOKEN_EXP as int: %w", err)
 	}
 
+	// issuer and audience are written into tokens, other services
+	// expecting a different audience will reject our tokens. The issuer is
+	// normalised here as the same value is signed, verified and advertised
+	// by discovery, where clients compare it to the iss of our tokens
+	tokenIssuer := strings.TrimSuffix(os.Getenv("TOKEN_ISSUER"), "/")
+	tokenAudience := os.Getenv("TOKEN_AUDIENCE")
+
+	// id tokens minted for these audiences are accepted as well,
+	// for example when tokens are shared between services
+	acceptedAudiences := []string{}
+	if tokenAudience != "" {
+		acceptedAudiences = append(acceptedAudiences, tokenAudience)
+	}
+
+	if audiences := os.Getenv("TOKEN_ACCEPTED_AUDIENCES"); audiences != "" {
+		for _, aud := range strings.Split(audiences, ",") {
+			acceptedAudiences = append(acceptedAudiences, strings.TrimSpace(aud))
+		}
+	}
+
+	idTokenAlgs := []string{"RS256"}
+	if algs := os.Getenv("ID_TOKEN_ALGS"); algs != "" {
+		idTokenAlgs = strings.Split(algs, ",")
+
+		for i := range idTokenAlgs {
+			idTokenAlgs[i] = strings.TrimSpace(idTokenAlgs[i])
+
+			// unsigned tokens must never be accepted
+			if idTokenAlgs[i] == "none" || jwt.GetSigningMethod(idTokenAlgs[i]) == nil {
+				return nil, fmt.Errorf("unsupported ID_TOKEN_ALGS algorithm: %q", idTokenAlgs[i])
+			}
+		}
+	}
+
+	var leeway int64
+	if tokenLeeway := os.Getenv("TOKEN_LEEWAY"); tokenLeeway != "" {
+		leeway, err = strconv.ParseInt(tokenLeeway, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse TOKEN_LEEWAY as int: %w", err)
+		}
+	}
+
+	idTokenVerifier := &security.Verifier{
+		Algorithms: idTokenAlgs,
+		Issuer:     tokenIssuer,
+		Audiences:  acceptedAudiences,
+		Leeway:     time.Duration(leeway) * time.Second,
+	}
+
+	// refresh tokens are only ever used by this service
+	refreshTokenVerifier := &security.Verifier{
+		Algorithms: []string{"HS256"},
+		Issuer:     tokenIssuer,
+		Leeway:     time.Duration(leeway) * time.Second,
+	}
+
+	if tokenAudience != "" {
+		refreshTokenVerifier.Audiences = []string{tokenAudience}
+	}
+
+	// id tokens embed the whole user unless OIDC style claims are configured
+	idTokenClaims := security.IDTokenClaims{
+		Mode: security.ClaimsModeUser,
+	}
+
+	if mode := os.Getenv("ID_TOKEN_CLAIMS_MODE"); mode != "" {
+		idTokenClaims.Mode = security.ClaimsMode(mode)
+	}
+
+	if idTokenClaims.Mode != security.ClaimsModeUser && idTokenClaims.Mode != security.ClaimsModeOIDC {
+		return nil, fmt.Errorf("unsupported ID_TOKEN_CLAIMS_MODE: %s", idTokenClaims.Mode)
+	}
+
+	if claims := os.Getenv("ID_TOKEN_CLAIMS"); claims != "" {
+		idTokenClaims.Claims = strings.Split(claims, ",")
+	}
+
+	// sessions are unlimited unless MAX_SESSIONS is set
+	var maxSessions int64
+	if limit := os.Getenv("MAX_SESSIONS"); limit != "" {
+		maxSessions, err = strconv.ParseInt(limit, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse MAX_SESSIONS as int: %w", err)
+		}
+	}
+
+	sessionLimitPolicy := service.SessionLimitPolicy(os.Getenv("SESSION_LIMIT_POLICY"))
+
+	if sessionLimitPolicy != "" && sessionLimitPolicy != service.SessionLimitEvict && sessionLimitPolicy != service.SessionLimitReject {
+		return nil, fmt.Errorf("unsupported SESSION_LIMIT_POLICY: %s", sessionLimitPolicy)
+	}
+
 	tokenService := service.NewTokenService(&service.TSConfig{
 		TokenRepository:       tokenRepository,
-		PrivKey:               privKey,
-		PubKey:                pubKey,
+		APIKeyRepository:      apiKeyRepository,
+		UserRepository:        userRepository,
+		KeySet:                keySet,
 		RefreshSecret:         refreshSecret,
 		IDExpirationSecs:      idExp,
 		RefreshExpirationSecs: refreshExp,
+		Issuer:                tokenIssuer,
+		Audience:              tokenAudience,
+		IDTokenClaims:         idTokenClaims,
+		IDTokenVerifier:       idTokenVerifier,
+		RefreshTokenVerifier:  refreshTokenVerifier,
+		MaxSessions:           maxSessions,
+		SessionLimitPolicy:    sessionLimitPolicy,
+	})
+
+	throttleService := service.NewThrottleService(&service.ThrottleConfig{
+		ThrottleRepository: throttleRepository,
+	})
+
+	// mfa pending tokens only prove the password was checked,
+	// so they must not be signed with any other token secret
+	// Deployments upgrading from before MFA have to add it
+	mfaTokenSecret := os.Getenv("MFA_TOKEN_SECRET")
+	if mfaTokenSecret == "" {
+		return nil, fmt.Errorf("MFA_TOKEN_SECRET must be set, see README.md")
+	}
+
+	var mfaTokenExp int64
+	if exp := os.Getenv("MFA_TOKEN_EXP"); exp != "" {
+		mfaTokenExp, err = strconv.ParseInt(exp, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse MFA_TOKEN_EXP as int: %w", err)
+		}
+	}
+
+	mfaService := service.NewMFAService(&service.MFAConfig{
+		MFARepository:              mfaRepository,
+		Issuer:                     os.Getenv("MFA_ISSUER"),
+		PendingTokenSecret:         mfaTokenSecret,
+		PendingTokenExpirationSecs: mfaTokenExp,
 	})
 
+	// passkeys are enabled by WEBAUTHN_RP_ID, the domain they are scoped to
+	// WEBAUTHN_ORIGINS lists the origins of pages allowed to use them
+	var webAuthnService models.WebAuthnService
+
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		origins := []string{"https://" + rpID}
+
+		if webAuthnOrigins := os.Getenv("WEBAUTHN_ORIGINS"); webAuthnOrigins != "" {
+			origins = strings.Split(webAuthnOrigins, ",")
+
+			for i := range origins {
+				origins[i] = strings.TrimSpace(origins[i])
+			}
+		}
+
+		webAuthnService = service.NewWebAuthnService(&service.WebAuthnConfig{
+			UserRepository:       userRepository,
+			CredentialRepository: credentialRepository,
+			TokenRepository:      tokenRepository,
+			RPID:                 rpID,
+			RPName:               os.Getenv("WEBAUTHN_RP_NAME"),
+			Origins:              origins,
+		})
+	}
+
+	// the OAuth authorization server is enabled by OAUTH_ENABLED, it
+	// also issues access tokens to service clients
+	// access tokens are signed with the keys of id tokens
+	var oauthService models.OAuthService
+
+	if os.Getenv("OAUTH_ENABLED") == "true" {
+		var accessTokenExp int64
+		if exp := os.Getenv("OAUTH_ACCESS_TOKEN_EXP"); exp != "" {
+			accessTokenExp, err = strconv.ParseInt(exp, 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse OAUTH_ACCESS_TOKEN_EXP as int: %w", err)
+			}
+		}
+
+		var codeExp int64
+		if exp := os.Getenv("OAUTH_CODE_EXP"); exp != "" {
+			codeExp, err = strconv.ParseInt(exp, 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse OAUTH_CODE_EXP as int: %w", err)
+			}
+		}
+
+		oauthService = service.NewOAuthService(&service.OAuthConfig{
+			OAuthRepository:       oauthRepository,
+			TokenRepository:       tokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         refreshSecret,
+			AccessExpirationSecs:  accessTokenExp,
+			RefreshExpirationSecs: refreshExp,
+			CodeExpirationSecs:    codeExp,
+			Issuer:                tokenIssuer,
+			Audience:              tokenAudience,
+			RefreshTokenVerifier:  refreshTokenVerifier,
+		})
+	}
+
 	// initialize gin.Engine
 	router := gin.Default()
 
+	// X-Forwarded-For is only trusted if set by TRUSTED_PROXIES,
+	// the network of the reverse proxy in front of the service
+	var trustedProxies []string
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		for _, proxy := range strings.Split(proxies, ",") {
+			trustedProxies = append(trustedProxies, strings.TrimSpace(proxy))
+		}
+	}
+
+	if err := handler.TrustProxies(router, trustedProxies); err != nil {
+		return nil, fmt.Errorf("could not parse TRUSTED_PROXIES: %w", err)
+	}
+
 	baseURL := os.Getenv("ACCOUNT_API_URL")
 	handlerTimeout := os.Getenv("HANDLER_TIMEOUT")
 	ht, err := strconv.ParseInt(handlerTimeout, 0, 64)
@@ -96,12 +440,54 @@ func inject(d *dataSources) (*gin.Engine, error) {
 		return nil, fmt.Errorf("could not parse HANDLER_TIMEOUT as int: %w", err)
 	}
 
+	var maxBodyBytes int64
+	if maxBody := os.Getenv("MAX_BODY_BYTES"); maxBody != "" {
+		maxBodyBytes, err = strconv.ParseInt(maxBody, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse MAX_BODY_BYTES as int: %w", err)
+		}
+	}
+
+	requireVerifiedEmail := os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	if imageURL, err := url.Parse(imageBaseURL); err == nil && imageURL.Path != "" {
+		router.Static(imageURL.Path, imageDir)
+	}
+
+	// OpenID Connect discovery is enabled by OIDC_ENABLED, it requires
+	// TOKEN_ISSUER to be the URL the service is reachable at
+	// OAUTH_AUTHORIZE_URL is the page of the frontend handling
+	// authorization requests, without it no login flow is advertised
+	oidcIssuer := ""
+	if os.Getenv("OIDC_ENABLED") == "true" {
+		if !strings.HasPrefix(tokenIssuer, "https://") && !strings.HasPrefix(tokenIssuer, "http://") {
+			return nil, fmt.Errorf("TOKEN_ISSUER must be a URL to enable OpenID Connect, got: %q", tokenIssuer)
+		}
+
+		oidcIssuer = tokenIssuer
+	}
+
+	authorizationURL := os.Getenv("OAUTH_AUTHORIZE_URL")
+	if authorizationURL != "" && !strings.HasPrefix(authorizationURL, "https://") && !strings.HasPrefix(authorizationURL, "http://") {
+		return nil, fmt.Errorf("OAUTH_AUTHORIZE_URL must be a URL, got: %q", authorizationURL)
+	}
+
 	handler.NewHandler(&handler.Config{
-		Router:          router,
-		UserService:     userService,
-		TokenService:    tokenService,
-		BaseURL:         baseURL,
-		TimeoutDuration: time.Duration(time.Duration(ht) * time.Second),
+		Router:               router,
+		UserService:          userService,
+		TokenService:         tokenService,
+		ThrottleService:      throttleService,
+		MFAService:           mfaService,
+		WebAuthnService:      webAuthnService,
+		OAuthService:         oauthService,
+		BaseURL:              baseURL,
+		Issuer:               oidcIssuer,
+		AuthorizationURL:     authorizationURL,
+		TimeoutDuration:      time.Duration(time.Duration(ht) * time.Second),
+		MaxBodyBytes:         maxBodyBytes,
+		RequireVerifiedEmail: requireVerifiedEmail,
+		AdminKey:             os.Getenv("ADMIN_KEY"),
+		RateLimiter:          rateLimiter,
 	})
 
 	return router, nil
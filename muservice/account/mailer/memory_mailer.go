@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+)
+
+// MemoryMailer is an in-memory implementation of service layer Mailer
+// Emails are never delivered, but kept so they can be inspected,
+// which is useful in tests and local development
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []models.Mail
+}
+
+// NewMemoryMailer is a factory for initializing MemoryMailers
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send stores a copy of m
+func (s *MemoryMailer) Send(ctx context.Context, m *models.Mail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, *m)
+
+	return nil
+}
+
+// Sent returns the emails sent so far, oldest first
+func (s *MemoryMailer) Sent() []models.Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := make([]models.Mail, len(s.sent))
+	copy(sent, s.sent)
+
+	return sent
+}
@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+)
+
+// SMTPConfig holds the server and credentials used to send emails
+// Authentication is skipped when no Username is set
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// smtpMailer is an SMTP implementation of service layer Mailer
+type smtpMailer struct {
+	Config SMTPConfig
+}
+
+// NewSMTPMailer is a factory for initializing Mailers
+// sending emails through an SMTP server
+func NewSMTPMailer(c *SMTPConfig) models.Mailer {
+	return &smtpMailer{
+		Config: *c,
+	}
+}
+
+// Send delivers m to the SMTP server
+func (s *smtpMailer) Send(ctx context.Context, m *models.Mail) error {
+	msg, err := buildMessage(s.Config.From, m)
+
+	if err != nil {
+		log.Printf("Unable to build email to: %v. Reason: %v\n", m.To, err)
+		return apperrors.NewInternal()
+	}
+
+	var auth smtp.Auth
+
+	if s.Config.Username != "" {
+		auth = smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)
+	}
+
+	addr := net.JoinHostPort(s.Config.Host, strconv.Itoa(s.Config.Port))
+
+	if err := smtp.SendMail(addr, auth, s.Config.From, []string{m.To}, msg); err != nil {
+		log.Printf("Unable to send email to: %v. Reason: %v\n", m.To, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// buildMessage formats m as a plain text message
+// Header values are checked for line breaks, which
+// could otherwise be used to inject headers
+func buildMessage(from string, m *models.Mail) ([]byte, error) {
+	for _, v := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("header value contains line break: %q", v)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(m.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
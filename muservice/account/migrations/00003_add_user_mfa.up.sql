@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_mfa (
+  uid uuid PRIMARY KEY REFERENCES users (uid) ON DELETE CASCADE,
+  secret VARCHAR NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_used_step BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  uid uuid NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+  code_hash VARCHAR NOT NULL,
+  used_at TIMESTAMPTZ,
+  PRIMARY KEY (uid, code_hash)
+);
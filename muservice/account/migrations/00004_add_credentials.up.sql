@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS credentials (
+  id VARCHAR PRIMARY KEY,
+  uid uuid NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+  name VARCHAR NOT NULL DEFAULT '',
+  public_key BYTEA NOT NULL,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS credentials_uid_idx ON credentials (uid);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS oauth_clients (
+  client_id VARCHAR PRIMARY KEY,
+  name VARCHAR NOT NULL,
+  redirect_uris TEXT[] NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS oauth_consents (
+  uid uuid NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+  client_id VARCHAR NOT NULL REFERENCES oauth_clients (client_id) ON DELETE CASCADE,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (uid, client_id)
+);
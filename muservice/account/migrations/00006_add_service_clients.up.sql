@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS service_clients (
+  client_id VARCHAR PRIMARY KEY,
+  name VARCHAR NOT NULL,
+  secret_hash VARCHAR NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  uid uuid NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+  name VARCHAR NOT NULL,
+  key_hash VARCHAR NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  expires_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_uid_idx ON api_keys (uid);
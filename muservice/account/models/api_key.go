@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix starts every API key, which tells
+// them apart from the JWTs sent as Bearer tokens
+const APIKeyPrefix = "muk_"
+
+// Scopes of the account which can be granted to OAuth clients
+// and API keys, next to the OpenID Connect scopes
+const (
+	ScopeAccountRead  = "account:read"
+	ScopeAccountWrite = "account:write"
+)
+
+// APIKeyScopes are the scopes API keys may be granted
+var APIKeyScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeAccountRead, ScopeAccountWrite}
+
+// APIKey is a long-lived credential of a user for scripts
+// Only a hash of the key is stored, the key can't be recovered
+type APIKey struct {
+	ID         uuid.UUID      `db:"id" json:"id"`
+	UID        uuid.UUID      `db:"uid" json:"-"`
+	Name       string         `db:"name" json:"name"`
+	KeyHash    string         `db:"key_hash" json:"-"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	ExpiresAt  *time.Time     `db:"expires_at" json:"expiresAt"`
+	CreatedAt  time.Time      `db:"created_at" json:"createdAt"`
+	LastUsedAt *time.Time     `db:"last_used_at" json:"lastUsedAt"`
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // Type holds a type string and integer code for the error
@@ -14,19 +15,23 @@ const (
 	Authorization        Type = "AUTHORIZATION"        // Authentication Failures -
 	BadRequest           Type = "BADREQUEST"           // Validation errors / BadInput
 	Conflict             Type = "CONFLICT"             // Already exists (eg, create account with existent email) - 409
+	Forbidden            Type = "FORBIDDEN"            // Authenticated, but not allowed to access a resource - 403
 	Internal             Type = "INTERNAL"             // Server (500) and fallback errors
 	NotFound             Type = "NOTFOUND"             // For not finding resource
 	PayloadTooLarge      Type = "PAYLOADTOOLARGE"      // for uploading tons of JSON, or an image over the limit - 413
 	UnsupportedMediaType Type = "UNSUPPORTEDMEDIATYPE" // for http 415
 	ServiceUnavailable   Type = "SERVICE_UNAVAILABLE"  // For long running handlers
+	TooManyRequests      Type = "TOOMANYREQUESTS"      // Rate limited or locked out - 429
 )
 
 // Error holds a custom error for the application
 // which is helpful in returning a consistent
 // error type/message from API endpoints
+// RetryAfter is only set for TooManyRequests and is sent as Retry-After header
 type Error struct {
-	Type    Type   `json:"type"`
-	Message string `json:"message"`
+	Type       Type          `json:"type"`
+	Message    string        `json:"message"`
+	RetryAfter time.Duration `json:"-"`
 }
 
 // Error satisfies standard error interface
@@ -49,6 +54,8 @@ func (e *Error) Status() int {
 		return http.StatusBadRequest
 	case Conflict:
 		return http.StatusConflict
+	case Forbidden:
+		return http.StatusForbidden
 	case Internal:
 		return http.StatusInternalServerError
 	case NotFound:
@@ -57,6 +64,8 @@ func (e *Error) Status() int {
 		return http.StatusRequestEntityTooLarge
 	case UnsupportedMediaType:
 		return http.StatusUnsupportedMediaType
+	case TooManyRequests:
+		return http.StatusTooManyRequests
 	default:
 		return http.StatusInternalServerError
 	}
@@ -109,6 +118,14 @@ func NewConflict(name string, value string) *Error {
 	}
 }
 
+// NewForbidden to create an error for 403
+func NewForbidden(reason string) *Error {
+	return &Error{
+		Type:    Forbidden,
+		Message: reason,
+	}
+}
+
 // NewInternal for 500 errors and unknown errors
 func NewInternal() *Error {
 	return &Error{
@@ -141,3 +158,13 @@ func NewServiceUnavailable() *Error {
 		Message: fmt.Sprintln("Service unavailable or timed out"),
 	}
 }
+
+// NewTooManyRequests to create an error for 429
+// retryAfter is the time until the request may be retried
+func NewTooManyRequests(retryAfter time.Duration) *Error {
+	return &Error{
+		Type:       TooManyRequests,
+		Message:    fmt.Sprintf("Too many requests. Retry after %v", retryAfter),
+		RetryAfter: retryAfter,
+	}
+}
@@ -0,0 +1,49 @@
+package apperrors
+
+import "net/http"
+
+// OAuthErrorCode is an error code of the OAuth 2.0 token endpoint (RFC 6749)
+type OAuthErrorCode string
+
+// "Set" of OAuth error codes
+const (
+	InvalidRequest          OAuthErrorCode = "invalid_request"
+	InvalidClient           OAuthErrorCode = "invalid_client"
+	InvalidGrant            OAuthErrorCode = "invalid_grant"
+	InvalidScope            OAuthErrorCode = "invalid_scope"
+	UnauthorizedClient      OAuthErrorCode = "unauthorized_client"
+	UnsupportedGrantType    OAuthErrorCode = "unsupported_grant_type"
+	AccessDenied            OAuthErrorCode = "access_denied"
+	UnsupportedResponseType OAuthErrorCode = "unsupported_response_type"
+	UnsupportedTokenType    OAuthErrorCode = "unsupported_token_type"
+)
+
+// OAuthError is an error of the OAuth endpoints, which is
+// sent in the format defined by the OAuth 2.0 specification
+// rather than as Error
+type OAuthError struct {
+	Code        OAuthErrorCode `json:"error"`
+	Description string         `json:"error_description,omitempty"`
+}
+
+// Error satisfies standard error interface
+func (e *OAuthError) Error() string {
+	return string(e.Code) + ": " + e.Description
+}
+
+// Status maps OAuth error codes to status codes
+func (e *OAuthError) Status() int {
+	if e.Code == InvalidClient {
+		return http.StatusUnauthorized
+	}
+
+	return http.StatusBadRequest
+}
+
+// NewOAuthError to create an OAuth error with a description
+func NewOAuthError(code OAuthErrorCode, description string) *OAuthError {
+	return &OAuthError{
+		Code:        code,
+		Description: description,
+	}
+}
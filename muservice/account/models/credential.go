@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Credential is a WebAuthn public key credential (passkey) of a user
+// ID is the base64url encoded credential id, PublicKey is COSE encoded
+type Credential struct {
+	ID         string     `db:"id" json:"id"`
+	UID        uuid.UUID  `db:"uid" json:"-"`
+	Name       string     `db:"name" json:"name"`
+	PublicKey  []byte     `db:"public_key" json:"-"`
+	SignCount  int64      `db:"sign_count" json:"-"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"lastUsedAt"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,16 @@ type UserService interface {
 	Get(ctx context.Context, uid uuid.UUID) (*User, error)
 	Signup(ctx context.Context, u *User) error
 	Signin(ctx context.Context, u *User) error
+	UpdateDetails(ctx context.Context, u *User) error
+	SetProfileImage(ctx context.Context, uid uuid.UUID, img io.Reader) (*User, error)
+	ClearProfileImage(ctx context.Context, uid uuid.UUID) error
+	SendVerificationEmail(ctx context.Context, uid uuid.UUID) error
+	VerifyEmail(ctx context.Context, token string) (*User, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+	ChangePassword(ctx context.Context, uid uuid.UUID, currentPassword string, newPassword string, keepTokenID string) error
+	SendMagicLink(ctx context.Context, email string) error
+	SigninWithMagicLink(ctx context.Context, token string) (*User, error)
 }
 
 // UserService defines methods the service layer expects
@@ -21,19 +32,176 @@ type UserRepository interface {
 	FindByEmail(ctx context.Context, email string) (*User, error)
 	FindByID(ctx context.Context, uid uuid.UUID) (*User, error)
 	Create(ctx context.Context, u *User) error
+	Update(ctx context.Context, u *User) error
+	UpdateImage(ctx context.Context, uid uuid.UUID, imageURL string) (*User, error)
+	SetEmailVerified(ctx context.Context, uid uuid.UUID, email string) (*User, error)
+	UpdatePassword(ctx context.Context, uid uuid.UUID, password string) error
+}
+
+// ImageRepository defines methods the service layer expects
+// any repository storing images to implement
+type ImageRepository interface {
+	UpdateProfile(ctx context.Context, objName string, img io.Reader) (string, error)
+	DeleteProfile(ctx context.Context, objName string) error
+}
+
+// Mailer defines methods the service layer expects
+// any implementation sending emails to implement
+type Mailer interface {
+	Send(ctx context.Context, m *Mail) error
 }
 
 // TokenService defines methods the handler layer expects to interact
 // with in regards to producing JWTs as string
 type TokenService interface {
 	NewPairFromUser(ctx context.Context, u *User, prevTokenID string) (*TokenPair, error)
+	Signout(ctx context.Context, uid uuid.UUID, tokenID string) error
+	SignoutAll(ctx context.Context, uid uuid.UUID) error
+	ListSessions(ctx context.Context, uid uuid.UUID) ([]*Session, error)
+	RevokeSession(ctx context.Context, uid uuid.UUID, sessionID string) error
+	CreateAPIKey(ctx context.Context, k *APIKey) (string, error)
+	ListAPIKeys(ctx context.Context, uid uuid.UUID) ([]*APIKey, error)
+	DeleteAPIKey(ctx context.Context, uid uuid.UUID, id uuid.UUID) error
+	ValidateAPIKey(ctx context.Context, key string) (*User, *APIKey, error)
+	IntrospectToken(ctx context.Context, tokenString string) (*TokenIntrospection, error)
+	RevokeToken(ctx context.Context, clientID string, tokenString string, anyClient bool) error
 	ValidateIDToken(tokenString string) (*User, error)
+	ValidateAccessToken(tokenString string) (*AccessToken, error)
+	ValidateServiceToken(tokenString string) (*AccessToken, error)
 	ValidateRefreshToken(RefreshTokenString string) (*RefreshToken, error)
+	JWKS() *JWKS
+}
+
+// APIKeyRepository defines methods it expects a repository
+// it interacts with to implement
+type APIKeyRepository interface {
+	Create(ctx context.Context, k *APIKey) error
+	FindByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	FindByUID(ctx context.Context, uid uuid.UUID) ([]*APIKey, error)
+	UpdateLastUsed(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error
 }
 
 // TokenRepository defines methods it expects a repository
 // it interacts with to implement
 type TokenRepository interface {
-	SetRefreshToken(ctx context.Context, userID string, tokenID string, expiresIn time.Duration) error
+	SetRefreshToken(ctx context.Context, userID string, tokenID string, session *Session, expiresIn time.Duration) error
 	DeleteRefreshToken(ctx context.Context, userID string, prevTokenID string) error
+	GetRefreshToken(ctx context.Context, userID string, tokenID string) (*Session, error)
+	RotateRefreshToken(ctx context.Context, userID string, tokenID string, expiresIn time.Duration) (*Session, error)
+	GetRotatedRefreshToken(ctx context.Context, userID string, tokenID string) (string, error)
+	DeleteRefreshTokenFamily(ctx context.Context, userID string, familyID string) error
+	DeleteUserRefreshTokens(ctx context.Context, userID string) error
+	DeleteUserRefreshTokensExcept(ctx context.Context, userID string, tokenID string) error
+	ListRefreshTokens(ctx context.Context, userID string) ([]*Session, error)
+	SetRefreshTokenWithinLimit(ctx context.Context, userID string, tokenID string, session *Session, expiresIn time.Duration, max int64, evict bool) error
+	SetPasswordResetToken(ctx context.Context, tokenHash string, userID string, expiresIn time.Duration) error
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error)
+	SetWebAuthnChallenge(ctx context.Context, challenge string, value string, expiresIn time.Duration) error
+	ConsumeWebAuthnChallenge(ctx context.Context, challenge string) (string, error)
+	SetMagicLinkToken(ctx context.Context, tokenHash string, email string, expiresIn time.Duration) error
+	ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (string, error)
+	SetAuthorizationCode(ctx context.Context, codeHash string, code *AuthorizationCode, expiresIn time.Duration) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*AuthorizationCode, error)
+}
+
+// ThrottleService defines methods the handler layer expects to
+// interact with to limit failed sign in attempts
+type ThrottleService interface {
+	Check(ctx context.Context, email string, ip string) error
+	RecordFailure(ctx context.Context, email string, ip string) error
+	RecordSuccess(ctx context.Context, email string) error
+	LockoutEvents(ctx context.Context) ([]*LockoutEvent, error)
+}
+
+// ThrottleRepository defines methods the service layer expects
+// any repository counting sign in attempts to implement
+type ThrottleRepository interface {
+	AddFailedAttempt(ctx context.Context, kind string, identifier string, window time.Duration) (int64, error)
+	ResetFailedAttempts(ctx context.Context, kind string, identifier string) error
+	SetLockout(ctx context.Context, kind string, identifier string, duration time.Duration) error
+	GetLockout(ctx context.Context, kind string, identifier string) (time.Duration, error)
+	AddLockoutEvent(ctx context.Context, e *LockoutEvent) error
+	ListLockoutEvents(ctx context.Context, limit int64) ([]*LockoutEvent, error)
+}
+
+// RateLimiter defines methods the handler layer expects
+// any implementation limiting request rates to implement
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// MFAService defines methods the handler layer expects to
+// interact with to manage and check second factors
+type MFAService interface {
+	Enroll(ctx context.Context, u *User) (*MFAEnrollment, error)
+	ConfirmEnrollment(ctx context.Context, uid uuid.UUID, code string) ([]string, error)
+	Disable(ctx context.Context, uid uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, uid uuid.UUID, code string) ([]string, error)
+	IsEnabled(ctx context.Context, uid uuid.UUID) (bool, error)
+	Verify(ctx context.Context, uid uuid.UUID, code string) error
+	NewPendingToken(ctx context.Context, u *User) (string, error)
+	ValidatePendingToken(tokenString string) (*User, error)
+}
+
+// MFARepository defines methods the service layer expects
+// any repository storing second factors to implement
+type MFARepository interface {
+	FindByUID(ctx context.Context, uid uuid.UUID) (*MFA, error)
+	SetSecret(ctx context.Context, uid uuid.UUID, secret string) error
+	Enable(ctx context.Context, uid uuid.UUID, step int64, recoveryCodeHashes []string) error
+	UseStep(ctx context.Context, uid uuid.UUID, step int64) error
+	UseRecoveryCode(ctx context.Context, uid uuid.UUID, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, uid uuid.UUID, recoveryCodeHashes []string) error
+	Delete(ctx context.Context, uid uuid.UUID) error
+}
+
+// WebAuthnService defines methods the handler layer expects to
+// interact with to register and sign in with passkeys
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, u *User) (*PublicKeyCredentialCreationOptions, error)
+	FinishRegistration(ctx context.Context, uid uuid.UUID, name string, c *CredentialCreationResponse) (*Credential, error)
+	BeginLogin(ctx context.Context) (*PublicKeyCredentialRequestOptions, error)
+	FinishLogin(ctx context.Context, c *CredentialAssertionResponse) (*User, error)
+	ListCredentials(ctx context.Context, uid uuid.UUID) ([]*Credential, error)
+	DeleteCredential(ctx context.Context, uid uuid.UUID, id string) error
+}
+
+// CredentialRepository defines methods the service layer expects
+// any repository storing WebAuthn credentials to implement
+type CredentialRepository interface {
+	Create(ctx context.Context, c *Credential) error
+	FindByID(ctx context.Context, id string) (*Credential, error)
+	FindByUID(ctx context.Context, uid uuid.UUID) ([]*Credential, error)
+	UpdateSignCount(ctx context.Context, id string, signCount int64) error
+	Delete(ctx context.Context, uid uuid.UUID, id string) error
+}
+
+// OAuthService defines methods the handler layer expects
+// any service acting as OAuth authorization server to implement
+type OAuthService interface {
+	CreateClient(ctx context.Context, c *OAuthClient) error
+	ListClients(ctx context.Context) ([]*OAuthClient, error)
+	CreateServiceClient(ctx context.Context, c *ServiceClient) (string, error)
+	ListServiceClients(ctx context.Context) ([]*ServiceClient, error)
+	DeleteServiceClient(ctx context.Context, clientID string) error
+	AuthenticateClient(ctx context.Context, clientID string, secret string) (*ServiceClient, error)
+	AuthenticatePublicClient(ctx context.Context, clientID string) (*OAuthClient, error)
+	Authorize(ctx context.Context, uid uuid.UUID, req *AuthorizeRequest) (*AuthorizeResult, error)
+	Consent(ctx context.Context, uid uuid.UUID, req *AuthorizeRequest, approved bool) (*AuthorizeResult, error)
+	Token(ctx context.Context, req *TokenRequest) (*OAuthTokenResponse, error)
+}
+
+// OAuthRepository defines methods the service layer expects
+// any repository storing OAuth clients and consents to implement
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, c *OAuthClient) error
+	FindClient(ctx context.Context, clientID string) (*OAuthClient, error)
+	ListClients(ctx context.Context) ([]*OAuthClient, error)
+	FindConsent(ctx context.Context, uid uuid.UUID, clientID string) (*OAuthConsent, error)
+	SaveConsent(ctx context.Context, c *OAuthConsent) error
+	CreateServiceClient(ctx context.Context, c *ServiceClient) error
+	FindServiceClient(ctx context.Context, clientID string) (*ServiceClient, error)
+	ListServiceClients(ctx context.Context) ([]*ServiceClient, error)
+	DeleteServiceClient(ctx context.Context, clientID string) error
 }
@@ -0,0 +1,18 @@
+package models
+
+// JWK is a JSON Web Key (RFC 7517) holding the
+// public part of an RSA key used to sign id tokens
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS is the JSON Web Key Set published to other
+// services so they can verify our id tokens
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// Mail holds a plain text email message
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
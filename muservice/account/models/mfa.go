@@ -0,0 +1,19 @@
+package models
+
+import "github.com/google/uuid"
+
+// MFA holds the TOTP second factor of a user
+// It is only used to sign in once Enabled
+type MFA struct {
+	UID          uuid.UUID `db:"uid" json:"-"`
+	Secret       string    `db:"secret" json:"-"`
+	Enabled      bool      `db:"enabled" json:"enabled"`
+	LastUsedStep int64     `db:"last_used_step" json:"-"`
+}
+
+// MFAEnrollment holds what a user needs to add
+// their TOTP secret to an authenticator app
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
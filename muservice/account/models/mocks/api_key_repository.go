@@ -0,0 +1,91 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyRepository is a mock type for model.APIKeyRepository
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+// Create is a mock of model.APIKeyRepository Create
+func (m *MockAPIKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	ret := m.Called(ctx, k)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// FindByHash is a mock of model.APIKeyRepository FindByHash
+func (m *MockAPIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	ret := m.Called(ctx, keyHash)
+
+	var r0 *models.APIKey
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.APIKey)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// FindByUID is a mock of model.APIKeyRepository FindByUID
+func (m *MockAPIKeyRepository) FindByUID(ctx context.Context, uid uuid.UUID) ([]*models.APIKey, error) {
+	ret := m.Called(ctx, uid)
+
+	var r0 []*models.APIKey
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.APIKey)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// UpdateLastUsed is a mock of model.APIKeyRepository UpdateLastUsed
+func (m *MockAPIKeyRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID) error {
+	ret := m.Called(ctx, id)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// Delete is a mock of model.APIKeyRepository Delete
+func (m *MockAPIKeyRepository) Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error {
+	ret := m.Called(ctx, uid, id)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
@@ -0,0 +1,89 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockCredentialRepository is a mock type for model.CredentialRepository
+type MockCredentialRepository struct {
+	mock.Mock
+}
+
+// Create is a mock of model.CredentialRepository Create
+func (m *MockCredentialRepository) Create(ctx context.Context, c *models.Credential) error {
+	ret := m.Called(ctx, c)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// FindByID is a mock of model.CredentialRepository FindByID
+func (m *MockCredentialRepository) FindByID(ctx context.Context, id string) (*models.Credential, error) {
+	ret := m.Called(ctx, id)
+
+	var r0 *models.Credential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.Credential)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// FindByUID is a mock of model.CredentialRepository FindByUID
+func (m *MockCredentialRepository) FindByUID(ctx context.Context, uid uuid.UUID) ([]*models.Credential, error) {
+	ret := m.Called(ctx, uid)
+
+	var r0 []*models.Credential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.Credential)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// UpdateSignCount is a mock of model.CredentialRepository UpdateSignCount
+func (m *MockCredentialRepository) UpdateSignCount(ctx context.Context, id string, signCount int64) error {
+	ret := m.Called(ctx, id, signCount)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// Delete is a mock of model.CredentialRepository Delete
+func (m *MockCredentialRepository) Delete(ctx context.Context, uid uuid.UUID, id string) error {
+	ret := m.Called(ctx, uid, id)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"context"
+	"io"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockImageRepository is a mock type for model.ImageRepository
+type MockImageRepository struct {
+	mock.Mock
+}
+
+// UpdateProfile is a mock of model.ImageRepository UpdateProfile
+func (m *MockImageRepository) UpdateProfile(ctx context.Context, objName string, img io.Reader) (string, error) {
+	ret := m.Called(ctx, objName, img)
+
+	var r0 string
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// DeleteProfile is a mock of model.ImageRepository DeleteProfile
+func (m *MockImageRepository) DeleteProfile(ctx context.Context, objName string) error {
+	ret := m.Called(ctx, objName)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
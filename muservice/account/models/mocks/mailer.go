@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMailer is a mock type for model.Mailer
+type MockMailer struct {
+	mock.Mock
+}
+
+// Send is a mock of model.Mailer Send
+func (m *MockMailer) Send(ctx context.Context, mail *models.Mail) error {
+	ret := m.Called(ctx, mail)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
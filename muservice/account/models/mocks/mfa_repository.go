@@ -0,0 +1,110 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMFARepository is a mock type for model.MFARepository
+type MockMFARepository struct {
+	mock.Mock
+}
+
+// FindByUID is a mock of model.MFARepository FindByUID
+func (m *MockMFARepository) FindByUID(ctx context.Context, uid uuid.UUID) (*models.MFA, error) {
+	ret := m.Called(ctx, uid)
+
+	var r0 *models.MFA
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.MFA)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// SetSecret is a mock of model.MFARepository SetSecret
+func (m *MockMFARepository) SetSecret(ctx context.Context, uid uuid.UUID, secret string) error {
+	ret := m.Called(ctx, uid, secret)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// Enable is a mock of model.MFARepository Enable
+func (m *MockMFARepository) Enable(ctx context.Context, uid uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	ret := m.Called(ctx, uid, step, recoveryCodeHashes)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// UseStep is a mock of model.MFARepository UseStep
+func (m *MockMFARepository) UseStep(ctx context.Context, uid uuid.UUID, step int64) error {
+	ret := m.Called(ctx, uid, step)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// UseRecoveryCode is a mock of model.MFARepository UseRecoveryCode
+func (m *MockMFARepository) UseRecoveryCode(ctx context.Context, uid uuid.UUID, codeHash string) error {
+	ret := m.Called(ctx, uid, codeHash)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ReplaceRecoveryCodes is a mock of model.MFARepository ReplaceRecoveryCodes
+func (m *MockMFARepository) ReplaceRecoveryCodes(ctx context.Context, uid uuid.UUID, recoveryCodeHashes []string) error {
+	ret := m.Called(ctx, uid, recoveryCodeHashes)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// Delete is a mock of model.MFARepository Delete
+func (m *MockMFARepository) Delete(ctx context.Context, uid uuid.UUID) error {
+	ret := m.Called(ctx, uid)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
@@ -0,0 +1,142 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMFAService is a mock type for model.MFAService
+type MockMFAService struct {
+	mock.Mock
+}
+
+// Enroll is a mock of model.MFAService Enroll
+func (m *MockMFAService) Enroll(ctx context.Context, u *models.User) (*models.MFAEnrollment, error) {
+	ret := m.Called(ctx, u)
+
+	var r0 *models.MFAEnrollment
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.MFAEnrollment)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ConfirmEnrollment is a mock of model.MFAService ConfirmEnrollment
+func (m *MockMFAService) ConfirmEnrollment(ctx context.Context, uid uuid.UUID, code string) ([]string, error) {
+	ret := m.Called(ctx, uid, code)
+
+	var r0 []string
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// Disable is a mock of model.MFAService Disable
+func (m *MockMFAService) Disable(ctx context.Context, uid uuid.UUID, code string) error {
+	ret := m.Called(ctx, uid, code)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// RegenerateRecoveryCodes is a mock of model.MFAService RegenerateRecoveryCodes
+func (m *MockMFAService) RegenerateRecoveryCodes(ctx context.Context, uid uuid.UUID, code string) ([]string, error) {
+	ret := m.Called(ctx, uid, code)
+
+	var r0 []string
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// IsEnabled is a mock of model.MFAService IsEnabled
+func (m *MockMFAService) IsEnabled(ctx context.Context, uid uuid.UUID) (bool, error) {
+	ret := m.Called(ctx, uid)
+
+	r0 := ret.Bool(0)
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// Verify is a mock of model.MFAService Verify
+func (m *MockMFAService) Verify(ctx context.Context, uid uuid.UUID, code string) error {
+	ret := m.Called(ctx, uid, code)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// NewPendingToken is a mock of model.MFAService NewPendingToken
+func (m *MockMFAService) NewPendingToken(ctx context.Context, u *models.User) (string, error) {
+	ret := m.Called(ctx, u)
+
+	r0 := ret.String(0)
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ValidatePendingToken is a mock of model.MFAService ValidatePendingToken
+func (m *MockMFAService) ValidatePendingToken(tokenString string) (*models.User, error) {
+	ret := m.Called(tokenString)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,161 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOAuthRepository is a mock type for model.OAuthRepository
+type MockOAuthRepository struct {
+	mock.Mock
+}
+
+// CreateClient is a mock of model.OAuthRepository CreateClient
+func (m *MockOAuthRepository) CreateClient(ctx context.Context, c *models.OAuthClient) error {
+	ret := m.Called(ctx, c)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// FindClient is a mock of model.OAuthRepository FindClient
+func (m *MockOAuthRepository) FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	ret := m.Called(ctx, clientID)
+
+	var r0 *models.OAuthClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.OAuthClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ListClients is a mock of model.OAuthRepository ListClients
+func (m *MockOAuthRepository) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	ret := m.Called(ctx)
+
+	var r0 []*models.OAuthClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.OAuthClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// FindConsent is a mock of model.OAuthRepository FindConsent
+func (m *MockOAuthRepository) FindConsent(ctx context.Context, uid uuid.UUID, clientID string) (*models.OAuthConsent, error) {
+	ret := m.Called(ctx, uid, clientID)
+
+	var r0 *models.OAuthConsent
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.OAuthConsent)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// SaveConsent is a mock of model.OAuthRepository SaveConsent
+func (m *MockOAuthRepository) SaveConsent(ctx context.Context, c *models.OAuthConsent) error {
+	ret := m.Called(ctx, c)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// CreateServiceClient is a mock of model.OAuthRepository CreateServiceClient
+func (m *MockOAuthRepository) CreateServiceClient(ctx context.Context, c *models.ServiceClient) error {
+	ret := m.Called(ctx, c)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// FindServiceClient is a mock of model.OAuthRepository FindServiceClient
+func (m *MockOAuthRepository) FindServiceClient(ctx context.Context, clientID string) (*models.ServiceClient, error) {
+	ret := m.Called(ctx, clientID)
+
+	var r0 *models.ServiceClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.ServiceClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ListServiceClients is a mock of model.OAuthRepository ListServiceClients
+func (m *MockOAuthRepository) ListServiceClients(ctx context.Context) ([]*models.ServiceClient, error) {
+	ret := m.Called(ctx)
+
+	var r0 []*models.ServiceClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.ServiceClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// DeleteServiceClient is a mock of model.OAuthRepository DeleteServiceClient
+func (m *MockOAuthRepository) DeleteServiceClient(ctx context.Context, clientID string) error {
+	ret := m.Called(ctx, clientID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
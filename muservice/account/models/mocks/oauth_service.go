@@ -0,0 +1,192 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOAuthService is a mock type for model.OAuthService
+type MockOAuthService struct {
+	mock.Mock
+}
+
+// CreateClient is a mock of model.OAuthService CreateClient
+func (m *MockOAuthService) CreateClient(ctx context.Context, c *models.OAuthClient) error {
+	ret := m.Called(ctx, c)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ListClients is a mock of model.OAuthService ListClients
+func (m *MockOAuthService) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	ret := m.Called(ctx)
+
+	var r0 []*models.OAuthClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.OAuthClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// Authorize is a mock of model.OAuthService Authorize
+func (m *MockOAuthService) Authorize(ctx context.Context, uid uuid.UUID, req *models.AuthorizeRequest) (*models.AuthorizeResult, error) {
+	ret := m.Called(ctx, uid, req)
+
+	var r0 *models.AuthorizeResult
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.AuthorizeResult)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// Consent is a mock of model.OAuthService Consent
+func (m *MockOAuthService) Consent(ctx context.Context, uid uuid.UUID, req *models.AuthorizeRequest, approved bool) (*models.AuthorizeResult, error) {
+	ret := m.Called(ctx, uid, req, approved)
+
+	var r0 *models.AuthorizeResult
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.AuthorizeResult)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// Token is a mock of model.OAuthService Token
+func (m *MockOAuthService) Token(ctx context.Context, req *models.TokenRequest) (*models.OAuthTokenResponse, error) {
+	ret := m.Called(ctx, req)
+
+	var r0 *models.OAuthTokenResponse
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.OAuthTokenResponse)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// CreateServiceClient is a mock of model.OAuthService CreateServiceClient
+func (m *MockOAuthService) CreateServiceClient(ctx context.Context, c *models.ServiceClient) (string, error) {
+	ret := m.Called(ctx, c)
+
+	var r0 string
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ListServiceClients is a mock of model.OAuthService ListServiceClients
+func (m *MockOAuthService) ListServiceClients(ctx context.Context) ([]*models.ServiceClient, error) {
+	ret := m.Called(ctx)
+
+	var r0 []*models.ServiceClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.ServiceClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// DeleteServiceClient is a mock of model.OAuthService DeleteServiceClient
+func (m *MockOAuthService) DeleteServiceClient(ctx context.Context, clientID string) error {
+	ret := m.Called(ctx, clientID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// AuthenticateClient is a mock of model.OAuthService AuthenticateClient
+func (m *MockOAuthService) AuthenticateClient(ctx context.Context, clientID string, secret string) (*models.ServiceClient, error) {
+	ret := m.Called(ctx, clientID, secret)
+
+	var r0 *models.ServiceClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.ServiceClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// AuthenticatePublicClient is a mock of model.OAuthService AuthenticatePublicClient
+func (m *MockOAuthService) AuthenticatePublicClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	ret := m.Called(ctx, clientID)
+
+	var r0 *models.OAuthClient
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.OAuthClient)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRateLimiter is a mock type for model.RateLimiter
+type MockRateLimiter struct {
+	mock.Mock
+}
+
+// Allow is a mock of model.RateLimiter Allow
+func (m *MockRateLimiter) Allow(ctx context.Context, key string, limit models.RateLimit) (*models.RateLimitResult, error) {
+	ret := m.Called(ctx, key, limit)
+
+	var r0 *models.RateLimitResult
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.RateLimitResult)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
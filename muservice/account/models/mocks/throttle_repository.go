@@ -0,0 +1,110 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockThrottleRepository is a mock type for model.ThrottleRepository
+type MockThrottleRepository struct {
+	mock.Mock
+}
+
+// AddFailedAttempt is a mock of model.ThrottleRepository AddFailedAttempt
+func (m *MockThrottleRepository) AddFailedAttempt(ctx context.Context, kind string, identifier string, window time.Duration) (int64, error) {
+	ret := m.Called(ctx, kind, identifier, window)
+
+	var r0 int64
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ResetFailedAttempts is a mock of model.ThrottleRepository ResetFailedAttempts
+func (m *MockThrottleRepository) ResetFailedAttempts(ctx context.Context, kind string, identifier string) error {
+	ret := m.Called(ctx, kind, identifier)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SetLockout is a mock of model.ThrottleRepository SetLockout
+func (m *MockThrottleRepository) SetLockout(ctx context.Context, kind string, identifier string, duration time.Duration) error {
+	ret := m.Called(ctx, kind, identifier, duration)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// GetLockout is a mock of model.ThrottleRepository GetLockout
+func (m *MockThrottleRepository) GetLockout(ctx context.Context, kind string, identifier string) (time.Duration, error) {
+	ret := m.Called(ctx, kind, identifier)
+
+	var r0 time.Duration
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// AddLockoutEvent is a mock of model.ThrottleRepository AddLockoutEvent
+func (m *MockThrottleRepository) AddLockoutEvent(ctx context.Context, e *models.LockoutEvent) error {
+	ret := m.Called(ctx, e)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ListLockoutEvents is a mock of model.ThrottleRepository ListLockoutEvents
+func (m *MockThrottleRepository) ListLockoutEvents(ctx context.Context, limit int64) ([]*models.LockoutEvent, error) {
+	ret := m.Called(ctx, limit)
+
+	var r0 []*models.LockoutEvent
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.LockoutEvent)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
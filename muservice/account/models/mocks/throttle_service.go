@@ -0,0 +1,71 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockThrottleService is a mock type for model.ThrottleService
+type MockThrottleService struct {
+	mock.Mock
+}
+
+// Check is a mock of model.ThrottleService Check
+func (m *MockThrottleService) Check(ctx context.Context, email string, ip string) error {
+	ret := m.Called(ctx, email, ip)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// RecordFailure is a mock of model.ThrottleService RecordFailure
+func (m *MockThrottleService) RecordFailure(ctx context.Context, email string, ip string) error {
+	ret := m.Called(ctx, email, ip)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// RecordSuccess is a mock of model.ThrottleService RecordSuccess
+func (m *MockThrottleService) RecordSuccess(ctx context.Context, email string) error {
+	ret := m.Called(ctx, email)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// LockoutEvents is a mock of model.ThrottleService LockoutEvents
+func (m *MockThrottleService) LockoutEvents(ctx context.Context) ([]*models.LockoutEvent, error) {
+	ret := m.Called(ctx)
+
+	var r0 []*models.LockoutEvent
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.LockoutEvent)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/NetworkPy/muserv/muservice/account/models"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -13,8 +14,8 @@ type MockTokenRepository struct {
 }
 
 // SetRefreshToken is a mock of model.TokenRepository SetRefreshToken
-func (m *MockTokenRepository) SetRefreshToken(ctx context.Context, userID string, tokenID string, expiresIn time.Duration) error {
-	ret := m.Called(ctx, userID, tokenID, expiresIn)
+func (m *MockTokenRepository) SetRefreshToken(ctx context.Context, userID string, tokenID string, session *models.Session, expiresIn time.Duration) error {
+	ret := m.Called(ctx, userID, tokenID, session, expiresIn)
 
 	var r0 error
 
@@ -37,3 +38,259 @@ func (m *MockTokenRepository) DeleteRefreshToken(ctx context.Context, userID str
 
 	return r0
 }
+
+// DeleteUserRefreshTokens is a mock of model.TokenRepository DeleteUserRefreshTokens
+func (m *MockTokenRepository) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	ret := m.Called(ctx, userID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// RotateRefreshToken is a mock of model.TokenRepository RotateRefreshToken
+func (m *MockTokenRepository) RotateRefreshToken(ctx context.Context, userID string, tokenID string, expiresIn time.Duration) (*models.Session, error) {
+	ret := m.Called(ctx, userID, tokenID, expiresIn)
+
+	var r0 *models.Session
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.Session)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// GetRotatedRefreshToken is a mock of model.TokenRepository GetRotatedRefreshToken
+func (m *MockTokenRepository) GetRotatedRefreshToken(ctx context.Context, userID string, tokenID string) (string, error) {
+	ret := m.Called(ctx, userID, tokenID)
+
+	var r0 string
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// DeleteRefreshTokenFamily is a mock of model.TokenRepository DeleteRefreshTokenFamily
+func (m *MockTokenRepository) DeleteRefreshTokenFamily(ctx context.Context, userID string, familyID string) error {
+	ret := m.Called(ctx, userID, familyID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SetPasswordResetToken is a mock of model.TokenRepository SetPasswordResetToken
+func (m *MockTokenRepository) SetPasswordResetToken(ctx context.Context, tokenHash string, userID string, expiresIn time.Duration) error {
+	ret := m.Called(ctx, tokenHash, userID, expiresIn)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ConsumePasswordResetToken is a mock of model.TokenRepository ConsumePasswordResetToken
+func (m *MockTokenRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	ret := m.Called(ctx, tokenHash)
+
+	var r0 string
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserRefreshTokensExcept is a mock of model.TokenRepository DeleteUserRefreshTokensExcept
+func (m *MockTokenRepository) DeleteUserRefreshTokensExcept(ctx context.Context, userID string, tokenID string) error {
+	ret := m.Called(ctx, userID, tokenID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ListRefreshTokens is a mock of model.TokenRepository ListRefreshTokens
+func (m *MockTokenRepository) ListRefreshTokens(ctx context.Context, userID string) ([]*models.Session, error) {
+	ret := m.Called(ctx, userID)
+
+	var r0 []*models.Session
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.Session)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// SetRefreshTokenWithinLimit is a mock of model.TokenRepository SetRefreshTokenWithinLimit
+func (m *MockTokenRepository) SetRefreshTokenWithinLimit(ctx context.Context, userID string, tokenID string, session *models.Session, expiresIn time.Duration, max int64, evict bool) error {
+	ret := m.Called(ctx, userID, tokenID, session, expiresIn, max, evict)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SetWebAuthnChallenge is a mock of model.TokenRepository SetWebAuthnChallenge
+func (m *MockTokenRepository) SetWebAuthnChallenge(ctx context.Context, challenge string, value string, expiresIn time.Duration) error {
+	ret := m.Called(ctx, challenge, value, expiresIn)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ConsumeWebAuthnChallenge is a mock of model.TokenRepository ConsumeWebAuthnChallenge
+func (m *MockTokenRepository) ConsumeWebAuthnChallenge(ctx context.Context, challenge string) (string, error) {
+	ret := m.Called(ctx, challenge)
+
+	var r0 string
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// SetMagicLinkToken is a mock of model.TokenRepository SetMagicLinkToken
+func (m *MockTokenRepository) SetMagicLinkToken(ctx context.Context, tokenHash string, email string, expiresIn time.Duration) error {
+	ret := m.Called(ctx, tokenHash, email, expiresIn)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ConsumeMagicLinkToken is a mock of model.TokenRepository ConsumeMagicLinkToken
+func (m *MockTokenRepository) ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (string, error) {
+	ret := m.Called(ctx, tokenHash)
+
+	var r0 string
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// SetAuthorizationCode is a mock of model.TokenRepository SetAuthorizationCode
+func (m *MockTokenRepository) SetAuthorizationCode(ctx context.Context, codeHash string, code *models.AuthorizationCode, expiresIn time.Duration) error {
+	ret := m.Called(ctx, codeHash, code, expiresIn)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ConsumeAuthorizationCode is a mock of model.TokenRepository ConsumeAuthorizationCode
+func (m *MockTokenRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	ret := m.Called(ctx, codeHash)
+
+	var r0 *models.AuthorizationCode
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.AuthorizationCode)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// GetRefreshToken is a mock of model.TokenRepository GetRefreshToken
+func (m *MockTokenRepository) GetRefreshToken(ctx context.Context, userID string, tokenID string) (*models.Session, error) {
+	ret := m.Called(ctx, userID, tokenID)
+
+	var r0 *models.Session
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.Session)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -32,6 +33,63 @@ func (m *MockTokenService) NewPairFromUser(ctx context.Context, u *models.User,
 	return r0, r1
 }
 
+// Signout mocks concrete Signout
+func (m *MockTokenService) Signout(ctx context.Context, uid uuid.UUID, tokenID string) error {
+	ret := m.Called(ctx, uid, tokenID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SignoutAll mocks concrete SignoutAll
+func (m *MockTokenService) SignoutAll(ctx context.Context, uid uuid.UUID) error {
+	ret := m.Called(ctx, uid)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ListSessions mocks concrete ListSessions
+func (m *MockTokenService) ListSessions(ctx context.Context, uid uuid.UUID) ([]*models.Session, error) {
+	ret := m.Called(ctx, uid)
+
+	var r0 []*models.Session
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.Session)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// RevokeSession mocks concrete RevokeSession
+func (m *MockTokenService) RevokeSession(ctx context.Context, uid uuid.UUID, sessionID string) error {
+	ret := m.Called(ctx, uid, sessionID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
 // ValidateIDToken mocks concrete ValidateIDToken
 func (m *MockTokenService) ValidateIDToken(tokenString string) (*models.User, error) {
 	ret := m.Called(tokenString)
@@ -70,3 +128,153 @@ func (m *MockTokenService) ValidateRefreshToken(refreshTokenString string) (*mod
 	return r0, r1
 
 }
+
+// JWKS mocks concrete JWKS
+func (m *MockTokenService) JWKS() *models.JWKS {
+	ret := m.Called()
+
+	var r0 *models.JWKS
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.JWKS)
+	}
+
+	return r0
+}
+
+// ValidateAccessToken mocks concrete ValidateAccessToken
+func (m *MockTokenService) ValidateAccessToken(tokenString string) (*models.AccessToken, error) {
+	ret := m.Called(tokenString)
+
+	var r0 *models.AccessToken
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.AccessToken)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ValidateServiceToken mocks concrete ValidateServiceToken
+func (m *MockTokenService) ValidateServiceToken(tokenString string) (*models.AccessToken, error) {
+	ret := m.Called(tokenString)
+
+	var r0 *models.AccessToken
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.AccessToken)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// CreateAPIKey mocks concrete CreateAPIKey
+func (m *MockTokenService) CreateAPIKey(ctx context.Context, k *models.APIKey) (string, error) {
+	ret := m.Called(ctx, k)
+
+	var r0 string
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ListAPIKeys mocks concrete ListAPIKeys
+func (m *MockTokenService) ListAPIKeys(ctx context.Context, uid uuid.UUID) ([]*models.APIKey, error) {
+	ret := m.Called(ctx, uid)
+
+	var r0 []*models.APIKey
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.APIKey)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// DeleteAPIKey mocks concrete DeleteAPIKey
+func (m *MockTokenService) DeleteAPIKey(ctx context.Context, uid uuid.UUID, id uuid.UUID) error {
+	ret := m.Called(ctx, uid, id)
+
+	var r0 error
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ValidateAPIKey mocks concrete ValidateAPIKey
+func (m *MockTokenService) ValidateAPIKey(ctx context.Context, key string) (*models.User, *models.APIKey, error) {
+	ret := m.Called(ctx, key)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 *models.APIKey
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(*models.APIKey)
+	}
+
+	var r2 error
+
+	if ret.Get(2) != nil {
+		r2 = ret.Get(2).(error)
+	}
+
+	return r0, r1, r2
+}
+
+// IntrospectToken mocks concrete IntrospectToken
+func (m *MockTokenService) IntrospectToken(ctx context.Context, tokenString string) (*models.TokenIntrospection, error) {
+	ret := m.Called(ctx, tokenString)
+
+	var r0 *models.TokenIntrospection
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.TokenIntrospection)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// RevokeToken mocks concrete RevokeToken
+func (m *MockTokenService) RevokeToken(ctx context.Context, clientID string, tokenString string, anyClient bool) error {
+	ret := m.Called(ctx, clientID, tokenString, anyClient)
+
+	var r0 error
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
@@ -59,3 +59,63 @@ func (m *MockUserRepository) FindByEmail(ctx context.Context, email string) (*mo
 
 	return r0, r1
 }
+
+// Update is a mock for UserRepository Update
+func (m *MockUserRepository) Update(ctx context.Context, u *models.User) error {
+	ret := m.Called(ctx, u)
+
+	var r0 error
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// UpdateImage is a mock for UserRepository UpdateImage
+func (m *MockUserRepository) UpdateImage(ctx context.Context, uid uuid.UUID, imageURL string) (*models.User, error) {
+	ret := m.Called(ctx, uid, imageURL)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// SetEmailVerified is a mock for UserRepository SetEmailVerified
+func (m *MockUserRepository) SetEmailVerified(ctx context.Context, uid uuid.UUID, email string) (*models.User, error) {
+	ret := m.Called(ctx, uid, email)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// UpdatePassword is a mock for UserRepository UpdatePassword
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, uid uuid.UUID, password string) error {
+	ret := m.Called(ctx, uid, password)
+
+	var r0 error
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"io"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
 	"github.com/google/uuid"
@@ -57,3 +58,148 @@ func (m *MockUserService) Signin(ctx context.Context, u *models.User) error {
 
 	return r0
 }
+
+// UpdateDetails is a mock of UserService.UpdateDetails
+func (m *MockUserService) UpdateDetails(ctx context.Context, u *models.User) error {
+	ret := m.Called(ctx, u)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SetProfileImage is a mock of UserService.SetProfileImage
+func (m *MockUserService) SetProfileImage(ctx context.Context, uid uuid.UUID, img io.Reader) (*models.User, error) {
+	ret := m.Called(ctx, uid, img)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ClearProfileImage is a mock of UserService.ClearProfileImage
+func (m *MockUserService) ClearProfileImage(ctx context.Context, uid uuid.UUID) error {
+	ret := m.Called(ctx, uid)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SendVerificationEmail is a mock of UserService.SendVerificationEmail
+func (m *MockUserService) SendVerificationEmail(ctx context.Context, uid uuid.UUID) error {
+	ret := m.Called(ctx, uid)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// VerifyEmail is a mock of UserService.VerifyEmail
+func (m *MockUserService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	ret := m.Called(ctx, token)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ForgotPassword is a mock of UserService.ForgotPassword
+func (m *MockUserService) ForgotPassword(ctx context.Context, email string) error {
+	ret := m.Called(ctx, email)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ResetPassword is a mock of UserService.ResetPassword
+func (m *MockUserService) ResetPassword(ctx context.Context, token string, password string) error {
+	ret := m.Called(ctx, token, password)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// ChangePassword is a mock of UserService.ChangePassword
+func (m *MockUserService) ChangePassword(ctx context.Context, uid uuid.UUID, currentPassword string, newPassword string, keepTokenID string) error {
+	ret := m.Called(ctx, uid, currentPassword, newPassword, keepTokenID)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SendMagicLink is a mock of UserService.SendMagicLink
+func (m *MockUserService) SendMagicLink(ctx context.Context, email string) error {
+	ret := m.Called(ctx, email)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
+
+// SigninWithMagicLink is a mock of UserService.SigninWithMagicLink
+func (m *MockUserService) SigninWithMagicLink(ctx context.Context, token string) (*models.User, error) {
+	ret := m.Called(ctx, token)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
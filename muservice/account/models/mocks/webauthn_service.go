@@ -0,0 +1,117 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebAuthnService is a mock type for model.WebAuthnService
+type MockWebAuthnService struct {
+	mock.Mock
+}
+
+// BeginRegistration is a mock of model.WebAuthnService BeginRegistration
+func (m *MockWebAuthnService) BeginRegistration(ctx context.Context, u *models.User) (*models.PublicKeyCredentialCreationOptions, error) {
+	ret := m.Called(ctx, u)
+
+	var r0 *models.PublicKeyCredentialCreationOptions
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.PublicKeyCredentialCreationOptions)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// FinishRegistration is a mock of model.WebAuthnService FinishRegistration
+func (m *MockWebAuthnService) FinishRegistration(ctx context.Context, uid uuid.UUID, name string, c *models.CredentialCreationResponse) (*models.Credential, error) {
+	ret := m.Called(ctx, uid, name, c)
+
+	var r0 *models.Credential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.Credential)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// BeginLogin is a mock of model.WebAuthnService BeginLogin
+func (m *MockWebAuthnService) BeginLogin(ctx context.Context) (*models.PublicKeyCredentialRequestOptions, error) {
+	ret := m.Called(ctx)
+
+	var r0 *models.PublicKeyCredentialRequestOptions
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.PublicKeyCredentialRequestOptions)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// FinishLogin is a mock of model.WebAuthnService FinishLogin
+func (m *MockWebAuthnService) FinishLogin(ctx context.Context, c *models.CredentialAssertionResponse) (*models.User, error) {
+	ret := m.Called(ctx, c)
+
+	var r0 *models.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*models.User)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// ListCredentials is a mock of model.WebAuthnService ListCredentials
+func (m *MockWebAuthnService) ListCredentials(ctx context.Context, uid uuid.UUID) ([]*models.Credential, error) {
+	ret := m.Called(ctx, uid)
+
+	var r0 []*models.Credential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]*models.Credential)
+	}
+
+	var r1 error
+
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(error)
+	}
+
+	return r0, r1
+}
+
+// DeleteCredential is a mock of model.WebAuthnService DeleteCredential
+func (m *MockWebAuthnService) DeleteCredential(ctx context.Context, uid uuid.UUID, id string) error {
+	ret := m.Called(ctx, uid, id)
+
+	var r0 error
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(error)
+	}
+
+	return r0
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Supported OAuth grant types
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// OAuthClient is an application users can authorize to
+// access their account. Clients are public and prove the
+// authorization with PKCE instead of a secret
+type OAuthClient struct {
+	ClientID     string         `db:"client_id" json:"clientId"`
+	Name         string         `db:"name" json:"name"`
+	RedirectURIs pq.StringArray `db:"redirect_uris" json:"redirectUris"`
+	Scopes       pq.StringArray `db:"scopes" json:"scopes"`
+	CreatedAt    time.Time      `db:"created_at" json:"createdAt"`
+}
+
+// ServiceClient is a confidential client other services use to get
+// access tokens for themselves with the client_credentials grant
+// Only a hash of its secret is stored
+type ServiceClient struct {
+	ClientID   string         `db:"client_id" json:"clientId"`
+	Name       string         `db:"name" json:"name"`
+	SecretHash string         `db:"secret_hash" json:"-"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	CreatedAt  time.Time      `db:"created_at" json:"createdAt"`
+}
+
+// OAuthConsent holds the scopes a user has granted a client
+type OAuthConsent struct {
+	UID       uuid.UUID      `db:"uid" json:"-"`
+	ClientID  string         `db:"client_id" json:"clientId"`
+	Scopes    pq.StringArray `db:"scopes" json:"scopes"`
+	CreatedAt time.Time      `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time      `db:"updated_at" json:"updatedAt"`
+}
+
+// AuthorizeRequest holds the parameters of an authorization request
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// AuthorizeResult is the outcome of an authorization request. Either
+// the user has to consent to the Scopes requested by Client, or the
+// user agent is sent to RedirectTo, which holds the code or an error
+type AuthorizeResult struct {
+	ConsentRequired bool         `json:"consentRequired"`
+	Client          *OAuthClient `json:"client,omitempty"`
+	Scopes          []string     `json:"scopes,omitempty"`
+	RedirectTo      string       `json:"redirectTo,omitempty"`
+}
+
+// AuthorizationCode is stored until it is exchanged for tokens
+// The Nonce of the request is returned in the id token. RedirectURISent
+// is set if the client sent the RedirectURI, it then has to be sent
+// again when the code is exchanged
+type AuthorizationCode struct {
+	ClientID        string    `json:"clientId"`
+	UID             uuid.UUID `json:"uid"`
+	RedirectURI     string    `json:"redirectUri"`
+	RedirectURISent bool      `json:"redirectUriSent,omitempty"`
+	Scope           string    `json:"scope"`
+	CodeChallenge   string    `json:"codeChallenge"`
+	Nonce           string    `json:"nonce,omitempty"`
+}
+
+// TokenRequest holds the parameters of a request to the token endpoint
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	ClientID     string
+	ClientSecret string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// OAuthTokenResponse is the successful response of the token endpoint
+// IDToken is set when the openid scope was granted
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// ScopeTokensIntrospect is the scope service clients
+// need to introspect tokens
+const ScopeTokensIntrospect = "tokens:introspect"
+
+// ScopeTokensRevoke is the scope service clients need
+// to revoke refresh tokens issued to any client
+const ScopeTokensRevoke = "tokens:revoke"
+
+// Types of tokens, which are used as token_type_hint
+// and token_type of token introspection
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+	TokenTypeIDToken      = "id_token"
+)
+
+// TokenIntrospection is the response of the introspection endpoint
+// (RFC 7662). Only Active is set for inactive tokens
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	ID        string `json:"jti,omitempty"`
+}
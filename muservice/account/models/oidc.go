@@ -0,0 +1,64 @@
+package models
+
+// Scopes of OpenID Connect, profile and email
+// select the claims returned by the userinfo endpoint
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OpenIDConfiguration is the OpenID Connect discovery document
+// Endpoints of features which are not enabled are left empty
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                     string   `json:"token_endpoint,omitempty"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                string   `json:"revocation_endpoint,omitempty"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	IntrospectionEndpointAuthMethods  []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	RevocationEndpointAuthMethods     []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// UserInfo holds the standard claims of a user
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+	Website       string `json:"website,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+// NewUserInfo returns the claims of u which are granted by scopes
+// sub is always returned
+func NewUserInfo(u *User, scopes []string) *UserInfo {
+	info := &UserInfo{
+		Subject: u.UID.String(),
+	}
+
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeProfile:
+			info.Name = u.Name
+			info.Picture = u.ImageURL
+			info.Website = u.Website
+		case ScopeEmail:
+			emailVerified := u.EmailVerified
+			info.Email = u.Email
+			info.EmailVerified = &emailVerified
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RateLimit allows Rate requests per Period, of which up
+// to Burst may be made at once
+type RateLimit struct {
+	Rate   int64
+	Period time.Duration
+	Burst  int64
+}
+
+// Interval is the time it takes to regain capacity for one request
+func (l RateLimit) Interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// RateLimitResult holds the outcome of a rate limited request
+// RetryAfter is only set for requests which are not allowed,
+// ResetAfter is the time until the full Burst is available again
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
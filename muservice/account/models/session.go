@@ -0,0 +1,41 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Session holds the metadata of a signed in device, which is
+// stored with its refresh token. Listed sessions are identified by
+// their token family, sessions without one by the id of their refresh
+// token. Sessions of OAuth clients have the ClientID and the granted Scope
+type Session struct {
+	ID              string    `json:"id"`
+	FamilyID        string    `json:"-"`
+	ClientID        string    `json:"clientId,omitempty"`
+	Scope           string    `json:"scope,omitempty"`
+	UserAgent       string    `json:"userAgent"`
+	IP              string    `json:"ip"`
+	CreatedAt       time.Time `json:"createdAt"`
+	LastRefreshedAt time.Time `json:"lastRefreshedAt"`
+}
+
+// ClientInfo describes the client a request was made from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo returns a copy of ctx carrying the client info
+func WithClientInfo(ctx context.Context, ci ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, ci)
+}
+
+// ClientInfoFromContext returns the client info of ctx, which
+// is empty if none was set
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	ci, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return ci
+}
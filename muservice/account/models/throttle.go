@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Kinds of identifiers sign in attempts are counted by
+const (
+	ThrottleKindEmail = "email"
+	ThrottleKindIP    = "ip"
+)
+
+// LockoutEvent records an identifier being locked out
+// after too many failed sign in attempts
+type LockoutEvent struct {
+	Kind       string    `json:"kind"`
+	Identifier string    `json:"identifier"`
+	Attempts   int64     `json:"attempts"`
+	LockedAt   time.Time `json:"lockedAt"`
+	Until      time.Time `json:"until"`
+}
@@ -5,9 +5,10 @@ import "github.com/google/uuid"
 // RefreshToken stores token properties that
 // are accessed in multiple application layers
 type RefreshToken struct {
-	ID  uuid.UUID `json:"-"`
-	UID uuid.UUID `json:"-"`
-	SS  string    `json:"refreshToken"`
+	ID       uuid.UUID `json:"-"`
+	UID      uuid.UUID `json:"-"`
+	FamilyID uuid.UUID `json:"-"`
+	SS       string    `json:"refreshToken"`
 }
 
 // IDToken stores token properties that
@@ -21,3 +22,12 @@ type TokenPair struct {
 	IDToken
 	RefreshToken
 }
+
+// AccessToken holds the claims of a validated OAuth access
+// token, which lets ClientID act for the user with UID
+// UID is uuid.Nil for tokens service clients got for themselves
+type AccessToken struct {
+	UID      uuid.UUID
+	ClientID string
+	Scopes   []string
+}
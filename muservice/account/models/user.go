@@ -3,10 +3,11 @@ package models
 import "github.com/google/uuid"
 
 type User struct {
-	UID      uuid.UUID `db:"uid" json:"uid"`
-	Email    string    `db:"email" json:"email"`
-	Passowrd string    `db:"password" json:"-"`
-	Name     string    `db:"name" json:"name"`
-	ImageURL string    `db:"image_url" json:"imageUrl"`
-	Website  string    `db:"website" json:"website"`
+	UID           uuid.UUID `db:"uid" json:"uid"`
+	Email         string    `db:"email" json:"email"`
+	EmailVerified bool      `db:"email_verified" json:"emailVerified"`
+	Passowrd      string    `db:"password" json:"-"`
+	Name          string    `db:"name" json:"name"`
+	ImageURL      string    `db:"image_url" json:"imageUrl"`
+	Website       string    `db:"website" json:"website"`
 }
@@ -0,0 +1,87 @@
+package models
+
+// The types below are the JSON encodings of the WebAuthn options and
+// credentials exchanged with browsers. Binary values are base64url encoded
+
+// RelyingParty identifies this service to authenticators
+type RelyingParty struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUser identifies the user a credential is created for
+// ID is the user handle, which is returned on sign in
+type WebAuthnUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter is a credential algorithm accepted by this service
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor references an existing credential
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// AuthenticatorSelection holds requirements for authenticators
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PublicKeyCredentialCreationOptions are passed to navigator.credentials.create
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   WebAuthnUser           `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// PublicKeyCredentialRequestOptions are passed to navigator.credentials.get
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AttestationResponse is the response of an authenticator to a registration
+type AttestationResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AttestationObject string `json:"attestationObject" binding:"required"`
+}
+
+// CredentialCreationResponse is the credential created by navigator.credentials.create
+type CredentialCreationResponse struct {
+	ID       string              `json:"id" binding:"required"`
+	RawID    string              `json:"rawId"`
+	Type     string              `json:"type" binding:"required,eq=public-key"`
+	Response AttestationResponse `json:"response" binding:"required"`
+}
+
+// AssertionResponse is the response of an authenticator to a sign in
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// CredentialAssertionResponse is the credential returned by navigator.credentials.get
+type CredentialAssertionResponse struct {
+	ID       string            `json:"id" binding:"required"`
+	RawID    string            `json:"rawId"`
+	Type     string            `json:"type" binding:"required,eq=public-key"`
+	Response AssertionResponse `json:"response" binding:"required"`
+}
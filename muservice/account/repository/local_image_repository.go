@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+)
+
+// localImageRepository is a local filesystem implementation
+// of service layer ImageRepository
+// Images are stored in Dir and served below BaseURL
+type localImageRepository struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocalImageRepository is a factory for initializing Image Repositories
+// storing images on the local filesystem
+func NewLocalImageRepository(dir string, baseURL string) (models.ImageRepository, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create image directory: %w", err)
+	}
+
+	return &localImageRepository{
+		Dir:     dir,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// UpdateProfile stores img under objName and returns the url it is served at
+func (r *localImageRepository) UpdateProfile(ctx context.Context, objName string, img io.Reader) (string, error) {
+	path, err := r.path(objName)
+
+	if err != nil {
+		log.Printf("Invalid image object name: %v\n", objName)
+		return "", apperrors.NewInternal()
+	}
+
+	// write to a temporary file first, so a partially
+	// written image is never served
+	tmp, err := ioutil.TempFile(r.Dir, ".upload-*")
+
+	if err != nil {
+		log.Printf("Unable to create temporary image file: %v\n", err)
+		return "", apperrors.NewInternal()
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, img); err != nil {
+		tmp.Close()
+		log.Printf("Unable to write image file: %v\n", err)
+		return "", apperrors.NewInternal()
+	}
+
+	if err := tmp.Close(); err != nil {
+		log.Printf("Unable to write image file: %v\n", err)
+		return "", apperrors.NewInternal()
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		log.Printf("Unable to set permissions of image file: %v\n", err)
+		return "", apperrors.NewInternal()
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("Unable to move image file in place: %v\n", err)
+		return "", apperrors.NewInternal()
+	}
+
+	return fmt.Sprintf("%s/%s", r.BaseURL, objName), nil
+}
+
+// DeleteProfile removes the image stored under objName
+func (r *localImageRepository) DeleteProfile(ctx context.Context, objName string) error {
+	path, err := r.path(objName)
+
+	if err != nil {
+		log.Printf("Invalid image object name: %v\n", objName)
+		return apperrors.NewInternal()
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to delete image object: %v\n%v", objName, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// path returns the file path of objName and makes
+// sure it can not point outside of Dir
+func (r *localImageRepository) path(objName string) (string, error) {
+	if objName == "" || objName != filepath.Base(objName) || strings.HasPrefix(objName, ".") {
+		return "", fmt.Errorf("invalid object name: %s", objName)
+	}
+
+	return filepath.Join(r.Dir, objName), nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+)
+
+// sweepInterval is how often expired keys are removed
+const sweepInterval = time.Minute
+
+// memoryRateLimiter is an in-memory implementation of RateLimiter
+// It uses the same algorithm as the redis implementation, but its
+// state is not shared, so it is only suited for a single instance
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimiter is a factory for initializing RateLimiters
+// storing their state in memory
+func NewMemoryRateLimiter() models.RateLimiter {
+	return &memoryRateLimiter{
+		tats: map[string]time.Time{},
+		now:  time.Now,
+	}
+}
+
+// Allow counts a request for key and reports whether it is within limit
+func (r *memoryRateLimiter) Allow(ctx context.Context, key string, limit models.RateLimit) (*models.RateLimitResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.sweep(now)
+
+	interval := limit.Interval()
+
+	tat, ok := r.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-interval * time.Duration(limit.Burst))
+	diff := now.Sub(allowAt)
+
+	if diff < 0 {
+		return &models.RateLimitResult{
+			Allowed:    false,
+			Limit:      limit.Burst,
+			Remaining:  0,
+			RetryAfter: -diff,
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+
+	r.tats[key] = newTat
+
+	return &models.RateLimitResult{
+		Allowed:    true,
+		Limit:      limit.Burst,
+		Remaining:  int64(diff / interval),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+// sweep removes keys whose capacity is fully restored
+func (r *memoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, tat := range r.tats {
+		if tat.Before(now) {
+			delete(r.tats, key)
+		}
+	}
+
+	r.lastSweep = now
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// pgAPIKeyRepository is data/repository implementation
+// of service layer APIKeyRepository
+type pgAPIKeyRepository struct {
+	Db *sqlx.DB
+}
+
+// NewAPIKeyRepository is a factory for initializing API Key Repositories
+func NewAPIKeyRepository(db *sqlx.DB) models.APIKeyRepository {
+	return &pgAPIKeyRepository{
+		Db: db,
+	}
+}
+
+// Create stores a new API key, k is updated with the stored row
+func (r *pgAPIKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (uid, name, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *;
+	`
+
+	if err := r.Db.GetContext(ctx, k, query, k.UID, k.Name, k.KeyHash, k.Scopes, k.ExpiresAt); err != nil {
+		log.Printf("Could not create api key for uid: %v. Reason: %v\n", k.UID, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// FindByHash fetches an API key by the hash of the key
+func (r *pgAPIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	k := &models.APIKey{}
+
+	query := "SELECT * FROM api_keys WHERE key_hash=$1"
+
+	if err := r.Db.GetContext(ctx, k, query, keyHash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NewNotFound("api_key", "")
+		}
+
+		log.Printf("Unable to get api key. Err: %v\n", err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return k, nil
+}
+
+// FindByUID fetches all API keys of a user
+func (r *pgAPIKeyRepository) FindByUID(ctx context.Context, uid uuid.UUID) ([]*models.APIKey, error) {
+	keys := []*models.APIKey{}
+
+	query := "SELECT * FROM api_keys WHERE uid=$1 ORDER BY created_at"
+
+	if err := r.Db.SelectContext(ctx, &keys, query, uid); err != nil {
+		log.Printf("Unable to get api keys of uid: %v. Err: %v\n", uid, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return keys, nil
+}
+
+// UpdateLastUsed records that an API key was used
+func (r *pgAPIKeyRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID) error {
+	query := "UPDATE api_keys SET last_used_at=now() WHERE id=$1"
+
+	if _, err := r.Db.ExecContext(ctx, query, id); err != nil {
+		log.Printf("Error updating last_used_at of api key: %v in database: %v\n", id, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// Delete removes an API key of a user
+func (r *pgAPIKeyRepository) Delete(ctx context.Context, uid uuid.UUID, id uuid.UUID) error {
+	query := "DELETE FROM api_keys WHERE uid=$1 AND id=$2"
+
+	result, err := r.Db.ExecContext(ctx, query, uid, id)
+
+	if err != nil {
+		log.Printf("Error deleting api key: %v in database: %v\n", id, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("api_key", id.String())
+	}
+
+	return nil
+}
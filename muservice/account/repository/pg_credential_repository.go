@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// pgCredentialRepository is data/repository implementation
+// of service layer CredentialRepository
+type pgCredentialRepository struct {
+	Db *sqlx.DB
+}
+
+// NewCredentialRepository is a factory for initializing Credential Repositories
+func NewCredentialRepository(db *sqlx.DB) models.CredentialRepository {
+	return &pgCredentialRepository{
+		Db: db,
+	}
+}
+
+// Create stores a new credential, c is updated with the stored row
+func (r *pgCredentialRepository) Create(ctx context.Context, c *models.Credential) error {
+	query := `
+		INSERT INTO credentials (id, uid, name, public_key, sign_count)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *;
+	`
+
+	if err := r.Db.GetContext(ctx, c, query, c.ID, c.UID, c.Name, c.PublicKey, c.SignCount); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			log.Printf("Could not create credential for uid: %v. Reason: %v\n", c.UID, err.Code.Name())
+			return apperrors.NewConflict("credential", c.ID)
+		}
+
+		log.Printf("Could not create credential for uid: %v. Reason: %v\n", c.UID, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// FindByID fetches a credential by its credential id
+func (r *pgCredentialRepository) FindByID(ctx context.Context, id string) (*models.Credential, error) {
+	c := &models.Credential{}
+
+	query := "SELECT * FROM credentials WHERE id=$1"
+
+	if err := r.Db.GetContext(ctx, c, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NewNotFound("credential", id)
+		}
+
+		log.Printf("Unable to get credential: %v. Err: %v\n", id, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return c, nil
+}
+
+// FindByUID fetches all credentials of a user
+func (r *pgCredentialRepository) FindByUID(ctx context.Context, uid uuid.UUID) ([]*models.Credential, error) {
+	credentials := []*models.Credential{}
+
+	query := "SELECT * FROM credentials WHERE uid=$1 ORDER BY created_at"
+
+	if err := r.Db.SelectContext(ctx, &credentials, query, uid); err != nil {
+		log.Printf("Unable to get credentials of uid: %v. Err: %v\n", uid, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return credentials, nil
+}
+
+// UpdateSignCount stores the sign count of a credential after it was used
+func (r *pgCredentialRepository) UpdateSignCount(ctx context.Context, id string, signCount int64) error {
+	query := "UPDATE credentials SET sign_count=$2, last_used_at=now() WHERE id=$1"
+
+	result, err := r.Db.ExecContext(ctx, query, id, signCount)
+
+	if err != nil {
+		log.Printf("Error updating sign_count of credential: %v in database: %v\n", id, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("credential", id)
+	}
+
+	return nil
+}
+
+// Delete removes a credential of a user
+func (r *pgCredentialRepository) Delete(ctx context.Context, uid uuid.UUID, id string) error {
+	query := "DELETE FROM credentials WHERE uid=$1 AND id=$2"
+
+	result, err := r.Db.ExecContext(ctx, query, uid, id)
+
+	if err != nil {
+		log.Printf("Error deleting credential: %v in database: %v\n", id, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("credential", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// pgMFARepository is data/repository implementation
+// of service layer MFARepository
+type pgMFARepository struct {
+	Db *sqlx.DB
+}
+
+// NewMFARepository is a factory for initializing MFA Repositories
+func NewMFARepository(db *sqlx.DB) models.MFARepository {
+	return &pgMFARepository{
+		Db: db,
+	}
+}
+
+// FindByUID fetches the second factor of a user
+func (r *pgMFARepository) FindByUID(ctx context.Context, uid uuid.UUID) (*models.MFA, error) {
+	mfa := &models.MFA{}
+
+	query := "SELECT * FROM user_mfa WHERE uid=$1"
+
+	if err := r.Db.GetContext(ctx, mfa, query, uid); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NewNotFound("mfa", uid.String())
+		}
+
+		log.Printf("Unable to get mfa of uid: %v. Err: %v\n", uid, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return mfa, nil
+}
+
+// SetSecret stores a new, not yet enabled, TOTP secret for a user
+// An enabled second factor is never replaced
+func (r *pgMFARepository) SetSecret(ctx context.Context, uid uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_mfa (uid, secret) VALUES ($1, $2)
+		ON CONFLICT (uid) DO UPDATE
+		SET secret=EXCLUDED.secret, last_used_step=0
+		WHERE user_mfa.enabled=FALSE;
+	`
+
+	result, err := r.Db.ExecContext(ctx, query, uid, secret)
+
+	if err != nil {
+		log.Printf("Error setting mfa secret of uid: %v in database: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewConflict("mfa", uid.String())
+	}
+
+	return nil
+}
+
+// Enable enables the second factor of a user, marks step as used
+// and replaces the user's recovery codes
+func (r *pgMFARepository) Enable(ctx context.Context, uid uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.Db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Printf("Unable to begin transaction to enable mfa of uid: %v. Err: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	defer tx.Rollback()
+
+	query := `
+		UPDATE user_mfa
+		SET enabled=TRUE, last_used_step=$2
+		WHERE uid=$1 AND enabled=FALSE;
+	`
+
+	result, err := tx.ExecContext(ctx, query, uid, step)
+
+	if err != nil {
+		log.Printf("Error enabling mfa of uid: %v in database: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("mfa", uid.String())
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, uid, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Unable to commit enabling mfa of uid: %v. Err: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// UseStep marks the TOTP time step as used
+// Steps up to the last used one are rejected, so a code can only be used once
+func (r *pgMFARepository) UseStep(ctx context.Context, uid uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step=$2
+		WHERE uid=$1 AND enabled=TRUE AND last_used_step < $2;
+	`
+
+	result, err := r.Db.ExecContext(ctx, query, uid, step)
+
+	if err != nil {
+		log.Printf("Error updating last_used_step of uid: %v in database: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("step", uid.String())
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (r *pgMFARepository) UseRecoveryCode(ctx context.Context, uid uuid.UUID, codeHash string) error {
+	query := `
+		UPDATE recovery_codes
+		SET used_at=now()
+		WHERE uid=$1 AND code_hash=$2 AND used_at IS NULL;
+	`
+
+	result, err := r.Db.ExecContext(ctx, query, uid, codeHash)
+
+	if err != nil {
+		log.Printf("Error using recovery code of uid: %v in database: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("recovery code", uid.String())
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user
+func (r *pgMFARepository) ReplaceRecoveryCodes(ctx context.Context, uid uuid.UUID, recoveryCodeHashes []string) error {
+	tx, err := r.Db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Printf("Unable to begin transaction to replace recovery codes of uid: %v. Err: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, uid, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Unable to commit recovery codes of uid: %v. Err: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// Delete removes the second factor and recovery codes of a user
+func (r *pgMFARepository) Delete(ctx context.Context, uid uuid.UUID) error {
+	tx, err := r.Db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Printf("Unable to begin transaction to delete mfa of uid: %v. Err: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE uid=$1", uid); err != nil {
+		log.Printf("Error deleting recovery codes of uid: %v in database: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_mfa WHERE uid=$1", uid); err != nil {
+		log.Printf("Error deleting mfa of uid: %v in database: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Unable to commit deleting mfa of uid: %v. Err: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user
+// and inserts the given ones within tx
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, uid uuid.UUID, recoveryCodeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE uid=$1", uid); err != nil {
+		log.Printf("Error deleting recovery codes of uid: %v in database: %v\n", uid, err)
+		return apperrors.NewInternal()
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		query := "INSERT INTO recovery_codes (uid, code_hash) VALUES ($1, $2)"
+
+		if _, err := tx.ExecContext(ctx, query, uid, codeHash); err != nil {
+			log.Printf("Error inserting recovery code of uid: %v in database: %v\n", uid, err)
+			return apperrors.NewInternal()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// pgOAuthRepository is data/repository implementation
+// of service layer OAuthRepository
+type pgOAuthRepository struct {
+	Db *sqlx.DB
+}
+
+// NewOAuthRepository is a factory for initializing OAuth Repositories
+func NewOAuthRepository(db *sqlx.DB) models.OAuthRepository {
+	return &pgOAuthRepository{
+		Db: db,
+	}
+}
+
+// CreateClient stores a new OAuth client, c is updated with the stored row
+func (r *pgOAuthRepository) CreateClient(ctx context.Context, c *models.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (client_id, name, redirect_uris, scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *;
+	`
+
+	if err := r.Db.GetContext(ctx, c, query, c.ClientID, c.Name, c.RedirectURIs, c.Scopes); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			log.Printf("Could not create oauth client: %v. Reason: %v\n", c.ClientID, err.Code.Name())
+			return apperrors.NewConflict("client_id", c.ClientID)
+		}
+
+		log.Printf("Could not create oauth client: %v. Reason: %v\n", c.ClientID, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// FindClient fetches an OAuth client by its client id
+func (r *pgOAuthRepository) FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	c := &models.OAuthClient{}
+
+	query := "SELECT * FROM oauth_clients WHERE client_id=$1"
+
+	if err := r.Db.GetContext(ctx, c, query, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NewNotFound("client_id", clientID)
+		}
+
+		log.Printf("Unable to get oauth client: %v. Err: %v\n", clientID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return c, nil
+}
+
+// ListClients fetches all OAuth clients
+func (r *pgOAuthRepository) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	clients := []*models.OAuthClient{}
+
+	query := "SELECT * FROM oauth_clients ORDER BY created_at"
+
+	if err := r.Db.SelectContext(ctx, &clients, query); err != nil {
+		log.Printf("Unable to get oauth clients. Err: %v\n", err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return clients, nil
+}
+
+// FindConsent fetches the consent a user has given a client
+func (r *pgOAuthRepository) FindConsent(ctx context.Context, uid uuid.UUID, clientID string) (*models.OAuthConsent, error) {
+	c := &models.OAuthConsent{}
+
+	query := "SELECT * FROM oauth_consents WHERE uid=$1 AND client_id=$2"
+
+	if err := r.Db.GetContext(ctx, c, query, uid, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NewNotFound("consent", clientID)
+		}
+
+		log.Printf("Unable to get consent of uid: %v for client: %v. Err: %v\n", uid, clientID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return c, nil
+}
+
+// SaveConsent creates or replaces the consent a user has given
+// a client, c is updated with the stored row
+func (r *pgOAuthRepository) SaveConsent(ctx context.Context, c *models.OAuthConsent) error {
+	query := `
+		INSERT INTO oauth_consents (uid, client_id, scopes) VALUES ($1, $2, $3)
+		ON CONFLICT (uid, client_id) DO UPDATE
+		SET scopes=EXCLUDED.scopes, updated_at=now()
+		RETURNING *;
+	`
+
+	if err := r.Db.GetContext(ctx, c, query, c.UID, c.ClientID, c.Scopes); err != nil {
+		log.Printf("Could not save consent of uid: %v for client: %v. Reason: %v\n", c.UID, c.ClientID, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// CreateServiceClient stores a new service client, c is updated with the stored row
+func (r *pgOAuthRepository) CreateServiceClient(ctx context.Context, c *models.ServiceClient) error {
+	query := `
+		INSERT INTO service_clients (client_id, name, secret_hash, scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *;
+	`
+
+	if err := r.Db.GetContext(ctx, c, query, c.ClientID, c.Name, c.SecretHash, c.Scopes); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			log.Printf("Could not create service client: %v. Reason: %v\n", c.ClientID, err.Code.Name())
+			return apperrors.NewConflict("client_id", c.ClientID)
+		}
+
+		log.Printf("Could not create service client: %v. Reason: %v\n", c.ClientID, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// FindServiceClient fetches a service client by its client id
+func (r *pgOAuthRepository) FindServiceClient(ctx context.Context, clientID string) (*models.ServiceClient, error) {
+	c := &models.ServiceClient{}
+
+	query := "SELECT * FROM service_clients WHERE client_id=$1"
+
+	if err := r.Db.GetContext(ctx, c, query, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NewNotFound("client_id", clientID)
+		}
+
+		log.Printf("Unable to get service client: %v. Err: %v\n", clientID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return c, nil
+}
+
+// ListServiceClients fetches all service clients
+func (r *pgOAuthRepository) ListServiceClients(ctx context.Context) ([]*models.ServiceClient, error) {
+	clients := []*models.ServiceClient{}
+
+	query := "SELECT * FROM service_clients ORDER BY created_at"
+
+	if err := r.Db.SelectContext(ctx, &clients, query); err != nil {
+		log.Printf("Unable to get service clients. Err: %v\n", err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return clients, nil
+}
+
+// DeleteServiceClient removes a service client, tokens issued
+// to it remain valid until they expire
+func (r *pgOAuthRepository) DeleteServiceClient(ctx context.Context, clientID string) error {
+	query := "DELETE FROM service_clients WHERE client_id=$1"
+
+	result, err := r.Db.ExecContext(ctx, query, clientID)
+
+	if err != nil {
+		log.Printf("Unable to delete service client: %v. Err: %v\n", clientID, err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("client_id", clientID)
+	}
+
+	return nil
+}
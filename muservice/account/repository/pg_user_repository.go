@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"log"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
@@ -68,3 +69,101 @@ func (r *pgUserRepository) FindByEmail(ctx context.Context, email string) (*mode
 
 	return user, nil
 }
+
+// Update updates a user's properties
+// Changing the email address resets email_verified
+func (r *pgUserRepository) Update(ctx context.Context, u *models.User) error {
+	query := `
+		UPDATE users
+		SET name=:name, email=:email, website=:website,
+			email_verified=(email_verified AND email=:email)
+		WHERE uid=:uid
+		RETURNING *;
+	`
+
+	nstmt, err := r.Db.PrepareNamedContext(ctx, query)
+
+	if err != nil {
+		log.Printf("Unable to prepare user update query: %v\n", err)
+		return apperrors.NewInternal()
+	}
+
+	defer nstmt.Close()
+
+	if err := nstmt.GetContext(ctx, u, u); err != nil {
+		// check unique constraint
+		if err, ok := err.(*pq.Error); ok && err.Code.Name() == "unique_violation" {
+			log.Printf("Could not update user: %v to email: %v. Reason: %v\n", u.UID, u.Email, err.Code.Name())
+			return apperrors.NewConflict("email", u.Email)
+		}
+
+		log.Printf("Failed to update details for user: %v. Reason: %v\n", u.UID, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// UpdateImage is used to separately update a user's image separate from
+// other account details
+func (r *pgUserRepository) UpdateImage(ctx context.Context, uid uuid.UUID, imageURL string) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET image_url=$2
+		WHERE uid=$1
+		RETURNING *;
+	`
+
+	u := &models.User{}
+
+	err := r.Db.GetContext(ctx, u, query, uid, imageURL)
+
+	if err != nil {
+		log.Printf("Error updating image_url in database: %v\n", err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return u, nil
+}
+
+// SetEmailVerified marks the email address of a user as verified
+// The update only applies if the user still has the given email address
+func (r *pgUserRepository) SetEmailVerified(ctx context.Context, uid uuid.UUID, email string) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET email_verified=true
+		WHERE uid=$1 AND email=$2
+		RETURNING *;
+	`
+
+	u := &models.User{}
+
+	if err := r.Db.GetContext(ctx, u, query, uid, email); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NewNotFound("email", email)
+		}
+
+		log.Printf("Error updating email_verified in database: %v\n", err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return u, nil
+}
+
+// UpdatePassword replaces the password hash of a user
+func (r *pgUserRepository) UpdatePassword(ctx context.Context, uid uuid.UUID, password string) error {
+	query := "UPDATE users SET password=$2 WHERE uid=$1"
+
+	result, err := r.Db.ExecContext(ctx, query, uid, password)
+
+	if err != nil {
+		log.Printf("Error updating password in database: %v\n", err)
+		return apperrors.NewInternal()
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return apperrors.NewNotFound("uid", uid.String())
+	}
+
+	return nil
+}
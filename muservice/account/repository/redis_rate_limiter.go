@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/go-redis/redis/v8"
+)
+
+// gcraScript implements the generic cell rate algorithm
+// The key stores the theoretical arrival time (tat) of the next request
+// in milliseconds, using the redis clock so all instances agree
+// ARGV[1] is the emission interval and ARGV[2] the burst
+// Returns allowed (0/1), remaining, retry after and reset after in ms
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+
+local newTat = tat + interval
+local allowAt = newTat - interval * burst
+local diff = now - allowAt
+
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+
+redis.call("SET", KEYS[1], newTat, "PX", newTat - now)
+
+return {1, math.floor(diff / interval), 0, newTat - now}
+`)
+
+// redisRateLimiter is a redis implementation of RateLimiter
+// which is shared by all instances of the service
+type redisRateLimiter struct {
+	Redis *redis.Client
+}
+
+// NewRedisRateLimiter is a factory for initializing RateLimiters
+// storing their state in redis
+func NewRedisRateLimiter(redisClient *redis.Client) models.RateLimiter {
+	return &redisRateLimiter{
+		Redis: redisClient,
+	}
+}
+
+// Allow counts a request for key and reports whether it is within limit
+func (r *redisRateLimiter) Allow(ctx context.Context, key string, limit models.RateLimit) (*models.RateLimitResult, error) {
+	interval := limit.Interval().Milliseconds()
+
+	if interval < 1 {
+		interval = 1
+	}
+
+	values, err := gcraScript.Run(ctx, r.Redis, []string{"ratelimit:" + key}, interval, limit.Burst).Int64Slice()
+
+	if err != nil {
+		log.Printf("Could not run rate limit script for key: %s: %v\n", key, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return &models.RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
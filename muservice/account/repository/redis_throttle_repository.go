@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// lockoutEventsKey holds a list of the most recent lockout events
+const lockoutEventsKey = "signin_lockout_events"
+
+// maxLockoutEvents is the number of lockout events kept
+const maxLockoutEvents = 1000
+
+// redisThrottleRepository is data/repository implementation
+// of service layer ThrottleRepository
+type redisThrottleRepository struct {
+	Redis *redis.Client
+}
+
+// NewThrottleRepository is a factory for initializing Throttle Repositories
+func NewThrottleRepository(redisClient *redis.Client) models.ThrottleRepository {
+	return &redisThrottleRepository{
+		Redis: redisClient,
+	}
+}
+
+// AddFailedAttempt records a failed attempt and returns the number of
+// failed attempts within window. Attempts are kept in a sorted set scored
+// by time, so older attempts slide out of the window
+func (r *redisThrottleRepository) AddFailedAttempt(ctx context.Context, kind string, identifier string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("signin_attempts:%s:%s", kind, identifier)
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	windowStart := now - int64(window/time.Millisecond)
+
+	var count *redis.IntCmd
+
+	_, err := r.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(windowStart, 10))
+		pipe.ZAdd(ctx, key, &redis.Z{
+			Score:  float64(now),
+			Member: uuid.New().String(),
+		})
+		count = pipe.ZCard(ctx, key)
+		pipe.Expire(ctx, key, window)
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Could not add failed attempt to redis for %s: %s: %v\n", kind, identifier, err)
+		return 0, apperrors.NewInternal()
+	}
+
+	return count.Val(), nil
+}
+
+// ResetFailedAttempts deletes the failed attempts of identifier
+func (r *redisThrottleRepository) ResetFailedAttempts(ctx context.Context, kind string, identifier string) error {
+	key := fmt.Sprintf("signin_attempts:%s:%s", kind, identifier)
+
+	if err := r.Redis.Del(ctx, key).Err(); err != nil {
+		log.Printf("Could not reset failed attempts in redis for %s: %s: %v\n", kind, identifier, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// SetLockout blocks sign in attempts of identifier for duration
+func (r *redisThrottleRepository) SetLockout(ctx context.Context, kind string, identifier string, duration time.Duration) error {
+	key := fmt.Sprintf("signin_lockout:%s:%s", kind, identifier)
+
+	if err := r.Redis.Set(ctx, key, 1, duration).Err(); err != nil {
+		log.Printf("Could not SET lockout to redis for %s: %s: %v\n", kind, identifier, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// GetLockout returns the time until sign in attempts of
+// identifier are allowed again, or 0 if they are allowed
+func (r *redisThrottleRepository) GetLockout(ctx context.Context, kind string, identifier string) (time.Duration, error) {
+	key := fmt.Sprintf("signin_lockout:%s:%s", kind, identifier)
+
+	ttl, err := r.Redis.PTTL(ctx, key).Result()
+
+	if err != nil {
+		log.Printf("Could not get lockout from redis for %s: %s: %v\n", kind, identifier, err)
+		return 0, apperrors.NewInternal()
+	}
+
+	// negative values if the key does not exist or has no expiry
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// AddLockoutEvent stores e, only the most recent events are kept
+func (r *redisThrottleRepository) AddLockoutEvent(ctx context.Context, e *models.LockoutEvent) error {
+	event, err := json.Marshal(e)
+
+	if err != nil {
+		log.Printf("Could not marshal lockout event: %v\n", err)
+		return apperrors.NewInternal()
+	}
+
+	_, err = r.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, lockoutEventsKey, event)
+		pipe.LTrim(ctx, lockoutEventsKey, 0, maxLockoutEvents-1)
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Could not add lockout event to redis: %v\n", err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// ListLockoutEvents returns up to limit lockout events, newest first
+func (r *redisThrottleRepository) ListLockoutEvents(ctx context.Context, limit int64) ([]*models.LockoutEvent, error) {
+	values, err := r.Redis.LRange(ctx, lockoutEventsKey, 0, limit-1).Result()
+
+	if err != nil {
+		log.Printf("Could not list lockout events from redis: %v\n", err)
+		return nil, apperrors.NewInternal()
+	}
+
+	events := make([]*models.LockoutEvent, 0, len(values))
+
+	for _, v := range values {
+		e := &models.LockoutEvent{}
+
+		if err := json.Unmarshal([]byte(v), e); err != nil {
+			log.Printf("Skipping invalid lockout event: %v\n", err)
+			continue
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
@@ -2,8 +2,11 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
@@ -11,6 +14,124 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// legacyFamilyID is the value stored for refresh tokens
+// which were issued before token families were introduced
+const legacyFamilyID = "0"
+
+// sessionLimitScript stores a new refresh token while making sure its user
+// has at most ARGV[2] sessions afterwards. KEYS[1] is the session index,
+// KEYS[2] the key of the new token and KEYS[3..] the keys of the tokens
+// in the index, whose ids are ARGV[8..] in the same order. Expired entries
+// are removed from the index first. If there are too many sessions and
+// ARGV[3] is 1, the sessions expiring first are deleted with their refresh
+// tokens, otherwise -1 is returned. -2 is returned if a token to delete
+// was added to the index after its keys were read
+// ARGV[1] is the current time in milliseconds, ARGV[4] is the id of the
+// new token, ARGV[5] its value, ARGV[6] its expiry in milliseconds and
+// ARGV[7] the time it expires at in milliseconds
+// Returns the number of deleted sessions
+var sessionLimitScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+
+local over = redis.call("ZCARD", KEYS[1]) + 1 - tonumber(ARGV[2])
+if over < 0 then
+	over = 0
+end
+
+if over > 0 then
+	if ARGV[3] ~= "1" then
+		return -1
+	end
+
+	local keys = {}
+	for i = 3, #KEYS do
+		keys[ARGV[i + 5]] = KEYS[i]
+	end
+
+	local oldest = redis.call("ZRANGE", KEYS[1], 0, over - 1)
+	for _, tokenID in ipairs(oldest) do
+		if not keys[tokenID] then
+			return -2
+		end
+	end
+
+	for _, tokenID in ipairs(oldest) do
+		redis.call("DEL", keys[tokenID])
+		redis.call("ZREM", KEYS[1], tokenID)
+	end
+end
+
+redis.call("SET", KEYS[2], ARGV[5], "PX", ARGV[6])
+redis.call("ZADD", KEYS[1], ARGV[7], ARGV[4])
+
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[6]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[6])
+end
+
+return over
+`)
+
+// sessionLimitAttempts is how often storing a refresh token within the
+// session limit is attempted while tokens are added concurrently
+const sessionLimitAttempts = 5
+
+// extendExpiryScript sets the expiry of KEYS[1] to ARGV[1] milliseconds
+// unless it already expires later, so the expiry is only ever extended
+var extendExpiryScript = redis.NewScript(`
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+
+return 1
+`)
+
+// sessionIndexKey is the key of the sorted set indexing the
+// refresh tokens of a user, scored by their expiry in milliseconds
+func sessionIndexKey(userID string) string {
+	return fmt.Sprintf("sessions:%s", userID)
+}
+
+// sessionValue is the value stored for refresh tokens
+type sessionValue struct {
+	FamilyID        string    `json:"familyId"`
+	ClientID        string    `json:"clientId,omitempty"`
+	Scope           string    `json:"scope,omitempty"`
+	UserAgent       string    `json:"userAgent,omitempty"`
+	IP              string    `json:"ip,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	LastRefreshedAt time.Time `json:"lastRefreshedAt"`
+}
+
+// parseSession parses the value stored for a refresh token. Tokens
+// stored before sessions were recorded hold the id of their token
+// family, or legacyFamilyID, and have no metadata
+func parseSession(tokenID string, val string) (*models.Session, error) {
+	if !strings.HasPrefix(val, "{") {
+		if val == legacyFamilyID {
+			val = ""
+		}
+
+		return &models.Session{ID: tokenID, FamilyID: val}, nil
+	}
+
+	var v sessionValue
+
+	if err := json.Unmarshal([]byte(val), &v); err != nil {
+		return nil, err
+	}
+
+	return &models.Session{
+		ID:              tokenID,
+		FamilyID:        v.FamilyID,
+		ClientID:        v.ClientID,
+		Scope:           v.Scope,
+		UserAgent:       v.UserAgent,
+		IP:              v.IP,
+		CreatedAt:       v.CreatedAt,
+		LastRefreshedAt: v.LastRefreshedAt,
+	}, nil
+}
+
 // redisTokenRepository is data/repository implementation
 // of service layer TokenRepository
 type redisTokenRepository struct {
@@ -24,26 +145,523 @@ func NewTokenRepository(redisClient *redis.Client) models.TokenRepository {
 	}
 }
 
+// encodeSession encodes the value stored for a refresh token
+func encodeSession(session *models.Session) ([]byte, error) {
+	return json.Marshal(&sessionValue{
+		FamilyID:        session.FamilyID,
+		ClientID:        session.ClientID,
+		Scope:           session.Scope,
+		UserAgent:       session.UserAgent,
+		IP:              session.IP,
+		CreatedAt:       session.CreatedAt,
+		LastRefreshedAt: session.LastRefreshedAt,
+	})
+}
+
 // SetRefreshToken stores a refresh token with an expiry time
-func (r *redisTokenRepository) SetRefreshToken(ctx context.Context, userID string, tokenID string, expiresIn time.Duration) error {
+// The value stored is the session the token belongs to
+func (r *redisTokenRepository) SetRefreshToken(ctx context.Context, userID string, tokenID string, session *models.Session, expiresIn time.Duration) error {
+	val, err := encodeSession(session)
+
+	if err != nil {
+		log.Printf("Could not encode session of userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return apperrors.NewInternal()
+	}
+
 	// We'll store userID with token id so we can scan (non-blocking)
 	// over the user's tokens and delete them in case of token leakage
 	key := fmt.Sprintf("%s:%s", userID, tokenID)
-	if err := r.Redis.Set(ctx, key, 0, expiresIn).Err(); err != nil {
+	indexKey := sessionIndexKey(userID)
+	expiresAt := time.Now().Add(expiresIn).UnixNano() / int64(time.Millisecond)
+
+	// the index is kept as long as the token expiring last, a token
+	// expiring earlier must not shorten the expiry of the index
+	_, err = r.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, val, expiresIn)
+		pipe.ZAdd(ctx, indexKey, &redis.Z{
+			Score:  float64(expiresAt),
+			Member: tokenID,
+		})
+		extendExpiryScript.Eval(ctx, pipe, []string{indexKey}, expiresIn.Milliseconds())
+		return nil
+	})
+
+	if err != nil {
 		log.Printf("Could not SET refresh token to redis for userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
 		return apperrors.NewInternal()
 	}
 	return nil
 }
 
+// SetRefreshTokenWithinLimit stores a refresh token like SetRefreshToken
+// and makes sure the user has at most max refresh tokens afterwards. Both
+// happen in one step, so concurrent sign ins can't exceed the limit
+// If evict is set, the tokens expiring first, which are those refreshed
+// least recently, are deleted. Otherwise a Forbidden error is returned
+// and the token is not stored if the user has too many tokens
+func (r *redisTokenRepository) SetRefreshTokenWithinLimit(ctx context.Context, userID string, tokenID string, session *models.Session, expiresIn time.Duration, max int64, evict bool) error {
+	val, err := encodeSession(session)
+
+	if err != nil {
+		log.Printf("Could not encode session of userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return apperrors.NewInternal()
+	}
+
+	evictArg := 0
+	if evict {
+		evictArg = 1
+	}
+
+	indexKey := sessionIndexKey(userID)
+
+	for attempt := 0; attempt < sessionLimitAttempts; attempt++ {
+		// the script may only delete keys it is passed, so the
+		// tokens which may have to be evicted are read first
+		tokenIDs, err := r.Redis.ZRange(ctx, indexKey, 0, -1).Result()
+
+		if err != nil {
+			log.Printf("Could not read session index from redis for userID: %s: %v\n", userID, err)
+			return apperrors.NewInternal()
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(expiresIn).UnixNano() / int64(time.Millisecond)
+
+		keys := []string{indexKey, fmt.Sprintf("%s:%s", userID, tokenID)}
+		args := []interface{}{now.UnixNano() / int64(time.Millisecond), max, evictArg, tokenID, val, expiresIn.Milliseconds(), expiresAt}
+
+		for _, id := range tokenIDs {
+			keys = append(keys, fmt.Sprintf("%s:%s", userID, id))
+			args = append(args, id)
+		}
+
+		evicted, err := sessionLimitScript.Run(ctx, r.Redis, keys, args...).Int64()
+
+		if err != nil {
+			log.Printf("Could not SET refresh token within limit to redis for userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+			return apperrors.NewInternal()
+		}
+
+		// a token was added while the index was read
+		if evicted == -2 {
+			continue
+		}
+
+		if evicted == -1 {
+			return apperrors.NewForbidden("Maximum number of sessions reached, sign out of another session first")
+		}
+
+		if evicted > 0 {
+			log.Printf("Evicted %d refresh tokens of userID: %s\n", evicted, userID)
+		}
+
+		return nil
+	}
+
+	log.Printf("Could not SET refresh token within limit to redis for userID/tokenID: %s/%s: sessions kept changing\n", userID, tokenID)
+	return apperrors.NewInternal()
+}
+
+// deleteRefreshToken deletes a refresh token together with its
+// entry in the session index and returns the number of deleted tokens
+func (r *redisTokenRepository) deleteRefreshToken(ctx context.Context, userID string, tokenID string) (int64, error) {
+	var deleted *redis.IntCmd
+
+	_, err := r.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(ctx, fmt.Sprintf("%s:%s", userID, tokenID))
+		pipe.ZRem(ctx, sessionIndexKey(userID), tokenID)
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted.Val(), nil
+}
+
 // DeleteRefreshToken used to delete old  refresh tokens
 // Services my access this to revolve tokens
 func (r *redisTokenRepository) DeleteRefreshToken(ctx context.Context, userID string, tokenID string) error {
-	key := fmt.Sprintf("%s:%s", userID, tokenID)
-	if err := r.Redis.Del(ctx, key).Err(); err != nil {
+	deleted, err := r.deleteRefreshToken(ctx, userID, tokenID)
+
+	if err != nil {
 		log.Printf("Could not delete refresh token to redis for userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
 		return apperrors.NewInternal()
 	}
 
+	// If no key was deleted, the refresh token is invalid
+	if deleted < 1 {
+		log.Printf("Refresh token to redis for userID/tokenID: %s/%s does not exist\n", userID, tokenID)
+		return apperrors.NewAuthorization("Invalid refresh token")
+	}
+
 	return nil
 }
+
+// DeleteUserRefreshTokens looks for all tokens beginning with
+// userID and scans to delete them in a non-blocking fashion
+func (r *redisTokenRepository) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	pattern := fmt.Sprintf("%s:*", userID)
+
+	iter := r.Redis.Scan(ctx, 0, pattern, 5).Iterator()
+	failCount := 0
+
+	for iter.Next(ctx) {
+		if err := r.Redis.Del(ctx, iter.Val()).Err(); err != nil {
+			log.Printf("Failed to delete refresh token: %s\n", iter.Val())
+			failCount++
+		}
+	}
+
+	// tokens which were not scanned are still valid
+	if err := iter.Err(); err != nil {
+		log.Printf("Failed to scan refresh tokens of userID: %s: %v\n", userID, err)
+		return apperrors.NewInternal()
+	}
+
+	if err := r.Redis.Del(ctx, sessionIndexKey(userID)).Err(); err != nil {
+		log.Printf("Failed to delete session index of userID: %s\n", userID)
+		failCount++
+	}
+
+	if failCount > 0 {
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// DeleteUserRefreshTokensExcept deletes all tokens of userID
+// except tokenID, which keeps the current session signed in
+func (r *redisTokenRepository) DeleteUserRefreshTokensExcept(ctx context.Context, userID string, tokenID string) error {
+	pattern := fmt.Sprintf("%s:*", userID)
+	keep := fmt.Sprintf("%s:%s", userID, tokenID)
+
+	iter := r.Redis.Scan(ctx, 0, pattern, 5).Iterator()
+	failCount := 0
+
+	for iter.Next(ctx) {
+		if iter.Val() == keep {
+			continue
+		}
+
+		if _, err := r.deleteRefreshToken(ctx, userID, strings.TrimPrefix(iter.Val(), userID+":")); err != nil {
+			log.Printf("Failed to delete refresh token: %s\n", iter.Val())
+			failCount++
+		}
+	}
+
+	// tokens which were not scanned are still valid
+	if err := iter.Err(); err != nil {
+		log.Printf("Failed to scan refresh tokens of userID: %s: %v\n", userID, err)
+		return apperrors.NewInternal()
+	}
+
+	if failCount > 0 {
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// ListRefreshTokens scans over the user's tokens and returns
+// their sessions, most recently refreshed first
+func (r *redisTokenRepository) ListRefreshTokens(ctx context.Context, userID string) ([]*models.Session, error) {
+	prefix := fmt.Sprintf("%s:", userID)
+
+	iter := r.Redis.Scan(ctx, 0, prefix+"*", 5).Iterator()
+	sessions := []*models.Session{}
+
+	for iter.Next(ctx) {
+		val, err := r.Redis.Get(ctx, iter.Val()).Result()
+
+		// token may have expired in the meantime
+		if err == redis.Nil {
+			continue
+		}
+
+		if err != nil {
+			log.Printf("Failed to get refresh token: %s: %v\n", iter.Val(), err)
+			return nil, apperrors.NewInternal()
+		}
+
+		session, err := parseSession(strings.TrimPrefix(iter.Val(), prefix), val)
+
+		if err != nil {
+			log.Printf("Failed to parse session of refresh token: %s: %v\n", iter.Val(), err)
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err := iter.Err(); err != nil {
+		log.Printf("Failed to scan refresh tokens of userID: %s: %v\n", userID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastRefreshedAt.After(sessions[j].LastRefreshedAt)
+	})
+
+	return sessions, nil
+}
+
+// RotateRefreshToken removes a refresh token from the valid list and
+// remembers it as rotated until expiresIn has passed, so that a later
+// attempt to use it again can be recognized. It returns the session of
+// the token, whose family id is empty for tokens issued without a family
+func (r *redisTokenRepository) RotateRefreshToken(ctx context.Context, userID string, tokenID string, expiresIn time.Duration) (*models.Session, error) {
+	key := fmt.Sprintf("%s:%s", userID, tokenID)
+
+	var getDel *redis.StringCmd
+
+	// a missing token makes the transaction return redis.Nil
+	_, err := r.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		getDel = pipe.GetDel(ctx, key)
+		pipe.ZRem(ctx, sessionIndexKey(userID), tokenID)
+		return nil
+	})
+
+	val := getDel.Val()
+
+	if err == redis.Nil {
+		log.Printf("Refresh token to redis for userID/tokenID: %s/%s does not exist\n", userID, tokenID)
+		return nil, apperrors.NewAuthorization("Invalid refresh token")
+	}
+
+	if err != nil {
+		log.Printf("Could not rotate refresh token in redis for userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	session, err := parseSession(tokenID, val)
+
+	if err != nil {
+		log.Printf("Could not parse session of userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	if session.FamilyID == "" {
+		return session, nil
+	}
+
+	rotatedKey := fmt.Sprintf("rotated:%s:%s", userID, tokenID)
+	if err := r.Redis.Set(ctx, rotatedKey, session.FamilyID, expiresIn).Err(); err != nil {
+		log.Printf("Could not SET rotated refresh token to redis for userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return session, nil
+}
+
+// GetRefreshToken returns the session of a refresh token which is
+// still valid, without rotating it
+func (r *redisTokenRepository) GetRefreshToken(ctx context.Context, userID string, tokenID string) (*models.Session, error) {
+	key := fmt.Sprintf("%s:%s", userID, tokenID)
+
+	val, err := r.Redis.Get(ctx, key).Result()
+
+	if err == redis.Nil {
+		return nil, apperrors.NewAuthorization("Invalid refresh token")
+	}
+
+	if err != nil {
+		log.Printf("Could not GET refresh token from redis for userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	session, err := parseSession(tokenID, val)
+
+	if err != nil {
+		log.Printf("Could not parse session of userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return session, nil
+}
+
+// GetRotatedRefreshToken returns the id of the token family of a
+// refresh token which has already been rotated, or an empty string
+// if the token is not known to have been rotated
+func (r *redisTokenRepository) GetRotatedRefreshToken(ctx context.Context, userID string, tokenID string) (string, error) {
+	rotatedKey := fmt.Sprintf("rotated:%s:%s", userID, tokenID)
+
+	familyID, err := r.Redis.Get(ctx, rotatedKey).Result()
+
+	if err == redis.Nil {
+		return "", nil
+	}
+
+	if err != nil {
+		log.Printf("Could not GET rotated refresh token from redis for userID/tokenID: %s/%s: %v\n", userID, tokenID, err)
+		return "", apperrors.NewInternal()
+	}
+
+	return familyID, nil
+}
+
+// DeleteRefreshTokenFamily scans over the user's tokens and
+// deletes those which belong to the token family
+func (r *redisTokenRepository) DeleteRefreshTokenFamily(ctx context.Context, userID string, familyID string) error {
+	pattern := fmt.Sprintf("%s:*", userID)
+
+	iter := r.Redis.Scan(ctx, 0, pattern, 5).Iterator()
+	failCount := 0
+
+	for iter.Next(ctx) {
+		val, err := r.Redis.Get(ctx, iter.Val()).Result()
+
+		// token may have expired in the meantime
+		if err == redis.Nil {
+			continue
+		}
+
+		if err != nil {
+			log.Printf("Failed to get refresh token: %s\n", iter.Val())
+			failCount++
+			continue
+		}
+
+		session, err := parseSession("", val)
+
+		if err != nil || session.FamilyID != familyID {
+			continue
+		}
+
+		if _, err := r.deleteRefreshToken(ctx, userID, strings.TrimPrefix(iter.Val(), userID+":")); err != nil {
+			log.Printf("Failed to delete refresh token: %s of family: %s\n", iter.Val(), familyID)
+			failCount++
+		}
+	}
+
+	// tokens which were not scanned may still belong to the family
+	if err := iter.Err(); err != nil {
+		log.Printf("Failed to scan refresh tokens of userID: %s for family: %s: %v\n", userID, familyID, err)
+		return apperrors.NewInternal()
+	}
+
+	if failCount > 0 {
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// SetPasswordResetToken stores the hash of a password reset token
+// The value stored is the id of the user the token was issued to
+func (r *redisTokenRepository) SetPasswordResetToken(ctx context.Context, tokenHash string, userID string, expiresIn time.Duration) error {
+	return r.setOnce(ctx, fmt.Sprintf("password_reset:%s", tokenHash), userID, expiresIn)
+}
+
+// ConsumePasswordResetToken deletes a password reset token and returns
+// the id of the user it was issued to. A token can only be consumed once
+func (r *redisTokenRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	userID, err := r.consumeOnce(ctx, fmt.Sprintf("password_reset:%s", tokenHash))
+
+	if err == redis.Nil {
+		return "", apperrors.NewAuthorization("Invalid password reset token")
+	}
+
+	return userID, err
+}
+
+// SetWebAuthnChallenge stores a WebAuthn challenge with the
+// ceremony and user it was created for
+func (r *redisTokenRepository) SetWebAuthnChallenge(ctx context.Context, challenge string, value string, expiresIn time.Duration) error {
+	return r.setOnce(ctx, fmt.Sprintf("webauthn_challenge:%s", challenge), value, expiresIn)
+}
+
+// ConsumeWebAuthnChallenge deletes a WebAuthn challenge and returns
+// its value. A challenge can only be consumed once
+func (r *redisTokenRepository) ConsumeWebAuthnChallenge(ctx context.Context, challenge string) (string, error) {
+	value, err := r.consumeOnce(ctx, fmt.Sprintf("webauthn_challenge:%s", challenge))
+
+	if err == redis.Nil {
+		return "", apperrors.NewAuthorization("Invalid or expired challenge")
+	}
+
+	return value, err
+}
+
+// SetMagicLinkToken stores the hash of a magic link token
+// The value stored is the email address the link was sent to
+func (r *redisTokenRepository) SetMagicLinkToken(ctx context.Context, tokenHash string, email string, expiresIn time.Duration) error {
+	return r.setOnce(ctx, fmt.Sprintf("magic_link:%s", tokenHash), email, expiresIn)
+}
+
+// ConsumeMagicLinkToken deletes a magic link token and returns the email
+// address it was sent to. A token can only be consumed once
+func (r *redisTokenRepository) ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (string, error) {
+	email, err := r.consumeOnce(ctx, fmt.Sprintf("magic_link:%s", tokenHash))
+
+	if err == redis.Nil {
+		return "", apperrors.NewAuthorization("Invalid magic link")
+	}
+
+	return email, err
+}
+
+// SetAuthorizationCode stores the hash of an OAuth authorization
+// code with the authorization it grants
+func (r *redisTokenRepository) SetAuthorizationCode(ctx context.Context, codeHash string, code *models.AuthorizationCode, expiresIn time.Duration) error {
+	val, err := json.Marshal(code)
+
+	if err != nil {
+		log.Printf("Could not encode authorization code of client: %s: %v\n", code.ClientID, err)
+		return apperrors.NewInternal()
+	}
+
+	return r.setOnce(ctx, fmt.Sprintf("oauth_code:%s", codeHash), string(val), expiresIn)
+}
+
+// ConsumeAuthorizationCode deletes an OAuth authorization code and
+// returns the authorization it grants. A code can only be consumed once
+func (r *redisTokenRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	val, err := r.consumeOnce(ctx, fmt.Sprintf("oauth_code:%s", codeHash))
+
+	if err == redis.Nil {
+		return nil, apperrors.NewAuthorization("Invalid authorization code")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	code := &models.AuthorizationCode{}
+
+	if err := json.Unmarshal([]byte(val), code); err != nil {
+		log.Printf("Could not decode authorization code: %v\n", err)
+		return nil, apperrors.NewInternal()
+	}
+
+	return code, nil
+}
+
+// setOnce stores a value which can be consumed once until it expires
+func (r *redisTokenRepository) setOnce(ctx context.Context, key string, value string, expiresIn time.Duration) error {
+	if err := r.Redis.Set(ctx, key, value, expiresIn).Err(); err != nil {
+		log.Printf("Could not SET %s to redis: %v\n", key, err)
+		return apperrors.NewInternal()
+	}
+
+	return nil
+}
+
+// consumeOnce gets and deletes a value stored by setOnce
+// It returns redis.Nil if the value doesn't exist
+func (r *redisTokenRepository) consumeOnce(ctx context.Context, key string) (string, error) {
+	value, err := r.Redis.GetDel(ctx, key).Result()
+
+	if err == redis.Nil {
+		return "", err
+	}
+
+	if err != nil {
+		log.Printf("Could not consume %s in redis: %v\n", key, err)
+		return "", apperrors.NewInternal()
+	}
+
+	return value, nil
+}
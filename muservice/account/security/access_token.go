@@ -0,0 +1,80 @@
+package security
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// AccessTokenType is the typ header of access tokens (RFC 9068)
+// which tells them apart from id tokens signed with the same keys
+const AccessTokenType = "at+jwt"
+
+// AccessTokenCustomClaims holds the payload of an OAuth access token
+// The subject is the user the client acts on behalf of
+type AccessTokenCustomClaims struct {
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id"`
+	jwt.StandardClaims
+}
+
+// GenerateAccessToken creates an access token for the OAuth
+// client with clientID, signed with the RSA key
+func GenerateAccessToken(subject string, clientID string, scope string, key *SigningKey, exp int64, opts TokenOptions) (string, error) {
+	unixTime := time.Now().Unix()
+	tokenID, err := uuid.NewRandom()
+
+	if err != nil {
+		log.Println("Failed to generate access token ID")
+		return "", err
+	}
+
+	claims := AccessTokenCustomClaims{
+		Scope:    scope,
+		ClientID: clientID,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   subject,
+			IssuedAt:  unixTime,
+			NotBefore: unixTime,
+			ExpiresAt: unixTime + exp,
+			Id:        tokenID.String(),
+			Issuer:    opts.Issuer,
+			Audience:  opts.Audience,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = AccessTokenType
+	token.Header["kid"] = key.ID
+	ss, err := token.SignedString(key.Private)
+
+	if err != nil {
+		log.Println("Failed to sign access token string")
+		return "", err
+	}
+
+	return ss, nil
+}
+
+// ValidateAccessToken returns the token's claims if the token is
+// a valid access token signed with one of keys
+func ValidateAccessToken(tokenString string, keys *KeySet, v *Verifier) (*AccessTokenCustomClaims, error) {
+	claims := &AccessTokenCustomClaims{}
+
+	err := v.parse(tokenString, claims, &claims.StandardClaims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != AccessTokenType {
+			return nil, fmt.Errorf("unexpected token type: %v", token.Header["typ"])
+		}
+
+		return rsaPublicKey(token, keys)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,237 @@
+package security
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrMalformedCBOR is returned for CBOR data which can't be decoded
+var ErrMalformedCBOR = errors.New("malformed cbor")
+
+// cborMaxDepth limits nesting of arrays and maps
+const cborMaxDepth = 16
+
+// decodeCBOR decodes the first CBOR data item of data and returns it with
+// the number of bytes it used. It supports the subset of CBOR used by
+// WebAuthn authenticators, indefinite lengths are rejected
+// Integers are returned as int64, maps as map[interface{}]interface{}
+// with int64 or string keys
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := &cborDecoder{data: data}
+
+	v, err := d.decode(0)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return v, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("%w: nested too deep", ErrMalformedCBOR)
+	}
+
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrMalformedCBOR)
+	}
+
+	initial := d.data[d.pos]
+	d.pos++
+
+	major := initial >> 5
+	info := initial & 0x1f
+
+	// floats and simple values don't have a length
+	if major == 7 {
+		return d.decodeSimple(info)
+	}
+
+	arg, err := d.argument(info)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", ErrMalformedCBOR)
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", ErrMalformedCBOR)
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case 3:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4:
+		// every item takes at least one byte
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("%w: array too long", ErrMalformedCBOR)
+		}
+
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos)/2 {
+			return nil, fmt.Errorf("%w: map too long", ErrMalformedCBOR)
+		}
+
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("%w: unsupported map key type %T", ErrMalformedCBOR, key)
+			}
+
+			if _, ok := m[key]; ok {
+				return nil, fmt.Errorf("%w: duplicate map key %v", ErrMalformedCBOR, key)
+			}
+
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	default:
+		// tags are ignored, only their content is returned
+		return d.decode(depth + 1)
+	}
+}
+
+// argument reads the argument of a data item, which
+// is its value or length depending on the major type
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	var size int
+
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, fmt.Errorf("%w: unsupported additional information %d", ErrMalformedCBOR, info)
+	}
+
+	b, err := d.bytes(uint64(size))
+
+	if err != nil {
+		return 0, err
+	}
+
+	var arg uint64
+	for _, c := range b {
+		arg = arg<<8 | uint64(c)
+	}
+
+	return arg, nil
+}
+
+func (d *cborDecoder) decodeSimple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		b, err := d.bytes(2)
+		if err != nil {
+			return nil, err
+		}
+		return float16(binary.BigEndian.Uint16(b)), nil
+	case 26:
+		b, err := d.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 27:
+		b, err := d.bytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported simple value %d", ErrMalformedCBOR, info)
+	}
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrMalformedCBOR)
+	}
+
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+
+	return b, nil
+}
+
+// float16 converts an IEEE 754 half precision float
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if h&0x8000 != 0 {
+		return -v
+	}
+
+	return v
+}
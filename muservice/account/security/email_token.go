@@ -0,0 +1,77 @@
+package security
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// Purposes of email tokens, a token is only accepted for its purpose
+const (
+	PurposeVerifyEmail = "verify_email"
+	PurposeMagicLink   = "magic_link"
+)
+
+// EmailTokenCustomClaims holds structure of jwt claims of tokens sent
+// to a user's email address. The subject is the user's ID
+// Email binds the token to the address it was sent to
+type EmailTokenCustomClaims struct {
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+	jwt.StandardClaims
+}
+
+// GenerateEmailToken creates a token for purpose which proves
+// the user with uid received an email sent to email
+func GenerateEmailToken(uid uuid.UUID, email string, purpose string, key string, exp int64) (string, error) {
+	currentTime := time.Now()
+	tokenExp := currentTime.Add(time.Duration(exp) * time.Second)
+
+	claims := EmailTokenCustomClaims{
+		Purpose: purpose,
+		Email:   email,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   uid.String(),
+			IssuedAt:  currentTime.Unix(),
+			NotBefore: currentTime.Unix(),
+			ExpiresAt: tokenExp.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	ss, err := token.SignedString([]byte(key))
+
+	if err != nil {
+		log.Println("Failed to sign email token string")
+		return "", err
+	}
+
+	return ss, nil
+}
+
+// ValidateEmailToken returns the token's claims if the token
+// is valid and was created for purpose
+func ValidateEmailToken(tokenString string, purpose string, key string, v *Verifier) (*EmailTokenCustomClaims, error) {
+	claims := &EmailTokenCustomClaims{}
+
+	err := v.parse(tokenString, claims, &claims.StandardClaims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		return []byte(key), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("token has invalid purpose: %s", claims.Purpose)
+	}
+
+	return claims, nil
+}
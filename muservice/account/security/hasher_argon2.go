@@ -0,0 +1,94 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2idHasher hashes passwords with Argon2id
+// Encoded as $argon2id$v=19$m=<Memory>,t=<Time>,p=<Threads>$<salt>$<hash>
+type Argon2idHasher struct {
+	Memory  uint32 // in KiB
+	Time    uint32 // number of passes
+	Threads uint8
+	SaltLen int
+	KeyLen  int
+}
+
+// ID is the PHC identifier of Argon2id
+func (a *Argon2idHasher) ID() string {
+	return "argon2id"
+}
+
+// Hash hashes password with a new random salt
+func (a *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, uint32(a.KeyLen))
+
+	return EncodePHC(a.ID(), strconv.Itoa(argon2.Version), a.params(), salt, hash), nil
+}
+
+// Verify checks password against h using the parameters stored in h
+func (a *Argon2idHasher) Verify(h *PHCHash, password string) (bool, error) {
+	m, t, p, err := a.parseParams(h)
+
+	if err != nil {
+		return false, err
+	}
+
+	hash := argon2.IDKey([]byte(password), h.Salt, t, m, p, uint32(len(h.Hash)))
+
+	return subtle.ConstantTimeCompare(hash, h.Hash) == 1, nil
+}
+
+// NeedsRehash reports whether h was hashed with other parameters than a
+func (a *Argon2idHasher) NeedsRehash(h *PHCHash) bool {
+	m, t, p, err := a.parseParams(h)
+
+	if err != nil {
+		return true
+	}
+
+	return m != a.Memory || t != a.Time || p != a.Threads || len(h.Salt) != a.SaltLen || len(h.Hash) != a.KeyLen
+}
+
+func (a *Argon2idHasher) params() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", a.Memory, a.Time, a.Threads)
+}
+
+// parseParams returns the cost parameters stored in h
+// The bounds keep a corrupted hash from exhausting memory
+func (a *Argon2idHasher) parseParams(h *PHCHash) (uint32, uint32, uint8, error) {
+	if h.Version != strconv.Itoa(argon2.Version) {
+		return 0, 0, 0, fmt.Errorf("%w: unsupported argon2 version %s", ErrMalformedHash, h.Version)
+	}
+
+	m, err := h.IntParam("m", 8, 4*1024*1024)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	t, err := h.IntParam("t", 1, 100)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	p, err := h.IntParam("p", 1, 255)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return uint32(m), uint32(t), uint8(p), nil
+}
@@ -0,0 +1,97 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ScryptHasher hashes passwords with scrypt
+// Encoded as $scrypt$ln=<LogN>,r=<R>,p=<P>$<salt>$<hash>
+type ScryptHasher struct {
+	LogN    int // log2 of the CPU/memory cost N
+	R       int // block size
+	P       int // parallelization
+	SaltLen int
+	KeyLen  int
+}
+
+// ID is the PHC identifier of scrypt
+func (s *ScryptHasher) ID() string {
+	return "scrypt"
+}
+
+// Hash hashes password with a new random salt
+func (s *ScryptHasher) Hash(password string) (string, error) {
+	salt := make([]byte, s.SaltLen)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash, err := scrypt.Key([]byte(password), salt, 1<<s.LogN, s.R, s.P, s.KeyLen)
+
+	if err != nil {
+		return "", err
+	}
+
+	return EncodePHC(s.ID(), "", s.params(), salt, hash), nil
+}
+
+// Verify checks password against h using the parameters stored in h
+func (s *ScryptHasher) Verify(h *PHCHash, password string) (bool, error) {
+	logN, r, p, err := s.parseParams(h)
+
+	if err != nil {
+		return false, err
+	}
+
+	hash, err := scrypt.Key([]byte(password), h.Salt, 1<<logN, r, p, len(h.Hash))
+
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(hash, h.Hash) == 1, nil
+}
+
+// NeedsRehash reports whether h was hashed with other parameters than s
+func (s *ScryptHasher) NeedsRehash(h *PHCHash) bool {
+	logN, r, p, err := s.parseParams(h)
+
+	if err != nil {
+		return true
+	}
+
+	return logN != s.LogN || r != s.R || p != s.P || len(h.Salt) != s.SaltLen || len(h.Hash) != s.KeyLen
+}
+
+func (s *ScryptHasher) params() string {
+	return fmt.Sprintf("ln=%d,r=%d,p=%d", s.LogN, s.R, s.P)
+}
+
+// parseParams returns the cost parameters stored in h
+// The bounds keep a corrupted hash from exhausting memory
+func (s *ScryptHasher) parseParams(h *PHCHash) (int, int, int, error) {
+	logN, err := h.IntParam("ln", 1, 24)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	r, err := h.IntParam("r", 1, 32)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	p, err := h.IntParam("p", 1, 16)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return logN, r, p, nil
+}
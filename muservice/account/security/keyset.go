@@ -0,0 +1,137 @@
+package security
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+)
+
+// SigningKey holds an RSA key pair along with its key id (kid)
+// Private is nil for keys which may only be used for verification
+type SigningKey struct {
+	ID      string
+	Private *rsa.PrivateKey
+	Public  *rsa.PublicKey
+}
+
+// KeySet holds the keys used for id tokens. New tokens are
+// signed with the active key, while tokens signed with any key
+// of the set can be verified. This allows rotating signing keys
+// without invalidating tokens which have already been issued
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	order  []string
+}
+
+// NewSigningKey creates a SigningKey which can sign tokens
+func NewSigningKey(priv *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{
+		ID:      thumbprint(&priv.PublicKey),
+		Private: priv,
+		Public:  &priv.PublicKey,
+	}
+}
+
+// NewVerifyingKey creates a SigningKey which can only verify tokens
+func NewVerifyingKey(pub *rsa.PublicKey) *SigningKey {
+	return &SigningKey{
+		ID:     thumbprint(pub),
+		Public: pub,
+	}
+}
+
+// NewKeySet creates a KeySet signing with active
+// and additionally verifying with verifyOnly keys
+func NewKeySet(active *SigningKey, verifyOnly ...*SigningKey) (*KeySet, error) {
+	if active == nil || active.Private == nil {
+		return nil, fmt.Errorf("active key must be able to sign tokens")
+	}
+
+	ks := &KeySet{
+		active: active,
+		keys: map[string]*SigningKey{
+			active.ID: active,
+		},
+		order: []string{active.ID},
+	}
+
+	for _, k := range verifyOnly {
+		if _, exists := ks.keys[k.ID]; exists {
+			return nil, fmt.Errorf("duplicate key with kid: %s", k.ID)
+		}
+
+		ks.keys[k.ID] = &SigningKey{
+			ID:     k.ID,
+			Public: k.Public,
+		}
+		ks.order = append(ks.order, k.ID)
+	}
+
+	return ks, nil
+}
+
+// Active returns the key new tokens are signed with
+func (ks *KeySet) Active() *SigningKey {
+	return ks.active
+}
+
+// Get returns the key identified by kid
+func (ks *KeySet) Get(kid string) (*SigningKey, bool) {
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// JWKS returns the public keys of the set, active key first
+func (ks *KeySet) JWKS() *models.JWKS {
+	jwks := &models.JWKS{}
+
+	for _, kid := range ks.order {
+		jwks.Keys = append(jwks.Keys, toJWK(ks.keys[kid]))
+	}
+
+	return jwks
+}
+
+func toJWK(k *SigningKey) models.JWK {
+	n, e := encodePublicKey(k.Public)
+
+	return models.JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: k.ID,
+		N:   n,
+		E:   e,
+	}
+}
+
+// encodePublicKey returns the base64url encoded modulus and exponent of pub
+func encodePublicKey(pub *rsa.PublicKey) (string, string) {
+	n := base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+
+	return n, e
+}
+
+// thumbprint computes the JWK thumbprint (RFC 7638) of pub,
+// so a key always gets the same kid without any configuration
+func thumbprint(pub *rsa.PublicKey) string {
+	n, e := encodePublicKey(pub)
+
+	// members must be in lexicographic order
+	canonical, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{e, "RSA", n})
+
+	sum := sha256.Sum256(canonical)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,73 @@
+package security
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// PurposeMFAPending marks tokens of users which provided their
+// password but still have to provide their second factor
+const PurposeMFAPending = "mfa_pending"
+
+// MFATokenCustomClaims holds structure of jwt claims of mfa pending tokens
+// The subject is the user's ID, Email is used to throttle attempts
+type MFATokenCustomClaims struct {
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+	jwt.StandardClaims
+}
+
+// GenerateMFAToken creates a short lived token stating the user
+// with uid and email signed in with their password
+func GenerateMFAToken(uid uuid.UUID, email string, key string, exp int64) (string, error) {
+	currentTime := time.Now()
+	tokenExp := currentTime.Add(time.Duration(exp) * time.Second)
+
+	claims := MFATokenCustomClaims{
+		Purpose: PurposeMFAPending,
+		Email:   email,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   uid.String(),
+			IssuedAt:  currentTime.Unix(),
+			NotBefore: currentTime.Unix(),
+			ExpiresAt: tokenExp.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	ss, err := token.SignedString([]byte(key))
+
+	if err != nil {
+		log.Println("Failed to sign mfa token string")
+		return "", err
+	}
+
+	return ss, nil
+}
+
+// ValidateMFAToken returns the token's claims if the token is a valid mfa pending token
+func ValidateMFAToken(tokenString string, key string, v *Verifier) (*MFATokenCustomClaims, error) {
+	claims := &MFATokenCustomClaims{}
+
+	err := v.parse(tokenString, claims, &claims.StandardClaims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		return []byte(key), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != PurposeMFAPending {
+		return nil, fmt.Errorf("token has invalid purpose: %s", claims.Purpose)
+	}
+
+	return claims, nil
+}
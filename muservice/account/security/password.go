@@ -1,45 +1,188 @@
 package security
 
 import (
-	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/scrypt"
 )
 
+// Errors returned when a stored password hash can't be verified
+var (
+	ErrMalformedHash = errors.New("malformed password hash")
+	ErrUnknownHasher = errors.New("unknown password hasher")
+)
+
+// Hasher hashes and verifies passwords with one algorithm
+// Hashes are encoded in PHC string format, which stores the
+// algorithm and its parameters next to the salt and hash
+type Hasher interface {
+	// ID is the PHC identifier of the algorithm, eg. scrypt
+	ID() string
+	Hash(password string) (string, error)
+	Verify(h *PHCHash, password string) (bool, error)
+	// NeedsRehash reports whether h was hashed with other parameters
+	NeedsRehash(h *PHCHash) bool
+}
+
+// DefaultScryptHasher uses the parameters of legacy hashes
+var DefaultScryptHasher = &ScryptHasher{
+	LogN:    15,
+	R:       8,
+	P:       1,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// DefaultArgon2idHasher uses parameters recommended by RFC 9106
+var DefaultArgon2idHasher = &Argon2idHasher{
+	Memory:  64 * 1024,
+	Time:    3,
+	Threads: 4,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// hashers holds the hashers passwords can be verified with by ID
+var hashers = map[string]Hasher{}
+
+// defaultHasher is used for new password hashes
+var defaultHasher Hasher = DefaultScryptHasher
+
+// dummyHashes holds a hash per hasher ID, which passwords
+// are checked against when there is no user to check against
+var dummyHashes sync.Map
+
+func init() {
+	RegisterHasher(DefaultScryptHasher)
+	RegisterHasher(DefaultArgon2idHasher)
+}
+
+// RegisterHasher makes passwords hashed by h verifiable, replacing
+// a hasher with the same ID. It is not safe for concurrent use and
+// should only be called on startup
+func RegisterHasher(h Hasher) {
+	hashers[h.ID()] = h
+	dummyHashes.Delete(h.ID())
+}
+
+// SetDefaultHasher selects the registered hasher used for new password
+// hashes. Existing hashes of other hashers are rehashed on signin
+// It is not safe for concurrent use and should only be called on startup
+func SetDefaultHasher(id string) error {
+	h, ok := hashers[id]
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownHasher, id)
+	}
+
+	defaultHasher = h
+
+	return nil
+}
+
+// HashPassword hashes password with the default hasher
 func HashPassword(password string) (string, error) {
-	// example for making salt - https://play.golang.org/p/_Aw6WeWC42I
-	salt := make([]byte, 32)
-	_, err := rand.Read(salt)
+	return defaultHasher.Hash(password)
+}
+
+// ComparePasswords checks suppliedPassword against storedPassword, which
+// is either in PHC string format or a legacy hex encoded hash.salt
+// Hashes are compared in constant time. A storedPassword which can't be
+// decoded returns an error wrapping ErrMalformedHash
+func ComparePasswords(storedPassword string, suppliedPassword string) (bool, error) {
+	if !isPHC(storedPassword) {
+		return compareLegacyPasswords(storedPassword, suppliedPassword)
+	}
+
+	h, err := parsePHC(storedPassword)
+
 	if err != nil {
-		return "", err
+		return false, err
 	}
 
-	// using recommended cost parameters from - https://godoc.org/golang.org/x/crypto/scrypt
-	shash, err := scrypt.Key([]byte(password), salt, 32768, 8, 1, 32)
+	hasher, ok := hashers[h.ID]
+
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownHasher, h.ID)
+	}
+
+	return hasher.Verify(h, suppliedPassword)
+}
+
+// CompareDummyPassword takes about as long as checking suppliedPassword
+// against a hash of the default hasher. It is used when there is no
+// stored hash, so response times don't reveal whether a user exists
+func CompareDummyPassword(suppliedPassword string) {
+	id := defaultHasher.ID()
+	dummy, ok := dummyHashes.Load(id)
+
+	if !ok {
+		h, err := defaultHasher.Hash("dummy password")
+
+		if err != nil {
+			return
+		}
+
+		dummy, _ = dummyHashes.LoadOrStore(id, h)
+	}
+
+	_, _ = ComparePasswords(dummy.(string), suppliedPassword)
+}
+
+// NeedsRehash reports whether storedPassword should be replaced by a hash
+// of the default hasher, because it uses another algorithm, other
+// parameters or the legacy format
+func NeedsRehash(storedPassword string) bool {
+	if !isPHC(storedPassword) {
+		return true
+	}
+
+	h, err := parsePHC(storedPassword)
+
 	if err != nil {
-		return "", err
+		return false
 	}
 
-	// return hex-encoded string with salt appended to password
-	hashedPW := fmt.Sprintf("%s.%s", hex.EncodeToString(shash), hex.EncodeToString(salt))
+	if h.ID != defaultHasher.ID() {
+		return true
+	}
 
-	return hashedPW, nil
+	return defaultHasher.NeedsRehash(h)
 }
 
-func ComparePasswords(storedPassword string, suppliedPassword string) (bool, error) {
+// compareLegacyPasswords checks suppliedPassword against a hash
+// created before hashes were stored in PHC string format
+// hex encoded scrypt(32768, 8, 1) hash and salt, separated by a dot
+func compareLegacyPasswords(storedPassword string, suppliedPassword string) (bool, error) {
 	pwsalt := strings.Split(storedPassword, ".")
 
-	// check supplied password salted with hash
+	if len(pwsalt) != 2 {
+		return false, fmt.Errorf("%w: expected hash.salt", ErrMalformedHash)
+	}
+
+	hash, err := hex.DecodeString(pwsalt[0])
+
+	if err != nil || len(hash) != 32 {
+		return false, fmt.Errorf("%w: invalid legacy hash", ErrMalformedHash)
+	}
+
 	salt, err := hex.DecodeString(pwsalt[1])
 
-	if err != nil {
-		return false, fmt.Errorf("unable to verify user password")
+	if err != nil || len(salt) == 0 {
+		return false, fmt.Errorf("%w: invalid legacy salt", ErrMalformedHash)
 	}
 
-	shash, _ := scrypt.Key([]byte(suppliedPassword), salt, 32768, 8, 1, 32)
+	// check supplied password salted with hash
+	shash, err := scrypt.Key([]byte(suppliedPassword), salt, 32768, 8, 1, 32)
+
+	if err != nil {
+		return false, fmt.Errorf("unable to verify user password: %w", err)
+	}
 
-	return hex.EncodeToString(shash) == pwsalt[0], nil
+	return subtle.ConstantTimeCompare(shash, hash) == 1, nil
 }
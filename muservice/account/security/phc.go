@@ -0,0 +1,130 @@
+package security
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PHCHash is a decoded password hash in PHC string format
+// $<id>[$v=<version>][$<param>=<value>(,<param>=<value>)*]$<salt>$<hash>
+// Salt and hash are encoded in base64 without padding
+type PHCHash struct {
+	ID      string
+	Version string
+	Params  map[string]string
+	Salt    []byte
+	Hash    []byte
+}
+
+// minHashLen is the minimum length of a hash in bytes
+const minHashLen = 16
+
+// isPHC reports whether encoded is in PHC string format
+// rather than the legacy hash.salt format
+func isPHC(encoded string) bool {
+	return strings.HasPrefix(encoded, "$")
+}
+
+// parsePHC decodes a password hash in PHC string format
+func parsePHC(encoded string) (*PHCHash, error) {
+	if !isPHC(encoded) {
+		return nil, fmt.Errorf("%w: not in PHC format", ErrMalformedHash)
+	}
+
+	fields := strings.Split(encoded[1:], "$")
+
+	// id, salt and hash are required
+	if len(fields) < 3 || len(fields) > 5 || fields[0] == "" {
+		return nil, fmt.Errorf("%w: invalid number of fields", ErrMalformedHash)
+	}
+
+	h := &PHCHash{
+		ID:     fields[0],
+		Params: map[string]string{},
+	}
+
+	rest := fields[1 : len(fields)-2]
+
+	if len(rest) > 0 && strings.HasPrefix(rest[0], "v=") {
+		h.Version = strings.TrimPrefix(rest[0], "v=")
+		rest = rest[1:]
+	}
+
+	if len(rest) > 1 {
+		return nil, fmt.Errorf("%w: invalid number of fields", ErrMalformedHash)
+	}
+
+	if len(rest) == 1 {
+		for _, param := range strings.Split(rest[0], ",") {
+			kv := strings.SplitN(param, "=", 2)
+
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, fmt.Errorf("%w: invalid parameter %q", ErrMalformedHash, param)
+			}
+
+			h.Params[kv[0]] = kv[1]
+		}
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(fields[len(fields)-2])
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid salt", ErrMalformedHash)
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(fields[len(fields)-1])
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid hash", ErrMalformedHash)
+	}
+
+	// a short hash would be easy to match by chance
+	if len(salt) == 0 || len(hash) < minHashLen {
+		return nil, fmt.Errorf("%w: salt or hash too short", ErrMalformedHash)
+	}
+
+	h.Salt = salt
+	h.Hash = hash
+
+	return h, nil
+}
+
+// IntParam returns the parameter name of h as integer in [min, max]
+func (h *PHCHash) IntParam(name string, min int, max int) (int, error) {
+	v, ok := h.Params[name]
+
+	if !ok {
+		return 0, fmt.Errorf("%w: missing parameter %s", ErrMalformedHash, name)
+	}
+
+	n, err := strconv.Atoi(v)
+
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%w: invalid parameter %s=%s", ErrMalformedHash, name, v)
+	}
+
+	return n, nil
+}
+
+// EncodePHC formats a password hash in PHC string format
+// version is left out if empty, params are written in the given order
+func EncodePHC(id string, version string, params string, salt []byte, hash []byte) string {
+	var b strings.Builder
+
+	b.WriteString("$" + id)
+
+	if version != "" {
+		b.WriteString("$v=" + version)
+	}
+
+	if params != "" {
+		b.WriteString("$" + params)
+	}
+
+	b.WriteString("$" + base64.RawStdEncoding.EncodeToString(salt))
+	b.WriteString("$" + base64.RawStdEncoding.EncodeToString(hash))
+
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package security
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// recoveryCodeLen is the number of random bytes of a recovery code
+const recoveryCodeLen = 10
+
+// GenerateRecoveryCodes creates n random recovery codes
+// of the form xxxx-xxxx-xxxx-xxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeLen)
+
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(totpEncoding.EncodeToString(b))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+	}
+
+	return codes, nil
+}
+
+// HashRecoveryCode returns the value stored for a recovery code
+// Codes are compared case insensitively and without separators
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashOpaqueToken(normalized)
+}
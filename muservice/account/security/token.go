@@ -1,7 +1,6 @@
 package security
 
 import (
-	"crypto/rsa"
 	"fmt"
 	"log"
 	"time"
@@ -12,11 +11,106 @@ import (
 )
 
 // IDTokenCustomClaims holds structure of jwt claims of idToken
+// Depending on the ClaimsMode, either the whole user is embedded
+// or only OpenID Connect standard claims are set
+// AuthorizedParty and Nonce are only set for id tokens of OAuth clients
 type IDTokenCustomClaims struct {
-	User *models.User `json:"user"`
+	User            *models.User `json:"user,omitempty"`
+	Email           string       `json:"email,omitempty"`
+	EmailVerified   *bool        `json:"email_verified,omitempty"`
+	Name            string       `json:"name,omitempty"`
+	Picture         string       `json:"picture,omitempty"`
+	Website         string       `json:"website,omitempty"`
+	AuthorizedParty string       `json:"azp,omitempty"`
+	Nonce           string       `json:"nonce,omitempty"`
 	jwt.StandardClaims
 }
 
+// ClaimsMode decides how the user is represented in id tokens
+type ClaimsMode string
+
+// Supported ClaimsModes
+const (
+	ClaimsModeUser ClaimsMode = "user" // embed the whole models.User
+	ClaimsModeOIDC ClaimsMode = "oidc" // OpenID Connect standard claims
+)
+
+// Standard claims which can be selected for ClaimsModeOIDC
+// sub is always set, email_verified comes with email
+const (
+	ClaimEmail   = "email"
+	ClaimName    = "name"
+	ClaimPicture = "picture"
+	ClaimWebsite = "website"
+)
+
+// DefaultOIDCClaims are the claims set when none are configured
+var DefaultOIDCClaims = []string{ClaimEmail, ClaimName, ClaimPicture}
+
+// IDTokenClaims configures the claims written into id tokens
+// Claims is only used in ClaimsModeOIDC
+type IDTokenClaims struct {
+	Mode   ClaimsMode
+	Claims []string
+}
+
+// setClaims writes the claims selected by c for user u into claims
+func (c IDTokenClaims) setClaims(claims *IDTokenCustomClaims, u *models.User) {
+	if c.Mode != ClaimsModeOIDC {
+		claims.User = u
+		return
+	}
+
+	selected := c.Claims
+
+	if len(selected) == 0 {
+		selected = DefaultOIDCClaims
+	}
+
+	for _, claim := range selected {
+		switch claim {
+		case ClaimEmail:
+			emailVerified := u.EmailVerified
+			claims.Email = u.Email
+			claims.EmailVerified = &emailVerified
+		case ClaimName:
+			claims.Name = u.Name
+		case ClaimPicture:
+			claims.Picture = u.ImageURL
+		case ClaimWebsite:
+			claims.Website = u.Website
+		}
+	}
+}
+
+// ToUser returns the user represented by the claims
+// For OIDC claims, fields of claims which were not selected remain empty
+func (c *IDTokenCustomClaims) ToUser() (*models.User, error) {
+	if c.User != nil {
+		return c.User, nil
+	}
+
+	uid, err := uuid.Parse(c.Subject)
+
+	if err != nil {
+		return nil, fmt.Errorf("ID token subject is not a valid uid: %w", err)
+	}
+
+	u := &models.User{
+		UID:      uid,
+		Email:    c.Email,
+		Name:     c.Name,
+		ImageURL: c.Picture,
+		Website:  c.Website,
+	}
+
+	if c.EmailVerified != nil {
+		u.EmailVerified = *c.EmailVerified
+	}
+
+	return u, nil
+}
+
 // RefreshToken holds the actual signed jwt string along with the ID
 // We return the id so it can be used without re-parsing the JWT from signed string
 type RefreshTokenData struct {
@@ -28,27 +122,40 @@ type RefreshTokenData struct {
 // RefreshTokenCustomClaims holds the payload of a refresh token
 // This can be used to extract user id for subsequent
 // application operations (IE, fetch user in Redis)
+// FID is the token family, which is created at sign in and
+// shared by every token produced by rotating its refresh tokens
+// CID is set for refresh tokens issued to OAuth clients
 type RefreshTokenCustomClaims struct {
 	UID uuid.UUID `json:"uid"`
+	FID uuid.UUID `json:"fid"`
+	CID string    `json:"cid,omitempty"`
 	jwt.StandardClaims
 }
 
 // GenerateIDToken generates an IDToken which is a jwt with myCustomClaims
 // Could call this GenerateIDTokenString, but the signature makes this fairly clear
-func GenerateIDToken(u *models.User, key *rsa.PrivateKey, exp int64) (string, error) {
+// The kid of the signing key is added to the header so the
+// token can be verified after the active key has been rotated
+func GenerateIDToken(u *models.User, key *SigningKey, exp int64, idClaims IDTokenClaims, opts TokenOptions) (string, error) {
 	unixTime := time.Now().Unix()
 	tokenExp := unixTime + exp
 
 	claims := IDTokenCustomClaims{
-		User: u,
 		StandardClaims: jwt.StandardClaims{
+			Subject:   u.UID.String(),
 			IssuedAt:  unixTime,
+			NotBefore: unixTime,
 			ExpiresAt: tokenExp,
+			Issuer:    opts.Issuer,
+			Audience:  opts.Audience,
 		},
 	}
 
+	idClaims.setClaims(&claims, u)
+
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	ss, err := token.SignedString(key)
+	token.Header["kid"] = key.ID
+	ss, err := token.SignedString(key.Private)
 
 	if err != nil {
 		log.Println("Failed to sign id token string")
@@ -58,9 +165,47 @@ func GenerateIDToken(u *models.User, key *rsa.PrivateKey, exp int64) (string, er
 	return ss, nil
 }
 
+// GenerateClientIDToken creates an OpenID Connect id token of the user
+// with uid for the OAuth client with clientID, which is its audience
+// The nonce of the authorization request is passed on, if any
+// These tokens are not accepted by ValidateIDToken
+func GenerateClientIDToken(uid uuid.UUID, clientID string, nonce string, key *SigningKey, exp int64, opts TokenOptions) (string, error) {
+	unixTime := time.Now().Unix()
+
+	claims := IDTokenCustomClaims{
+		AuthorizedParty: clientID,
+		Nonce:           nonce,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   uid.String(),
+			IssuedAt:  unixTime,
+			NotBefore: unixTime,
+			ExpiresAt: unixTime + exp,
+			Issuer:    opts.Issuer,
+			Audience:  clientID,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	ss, err := token.SignedString(key.Private)
+
+	if err != nil {
+		log.Println("Failed to sign client id token string")
+		return "", err
+	}
+
+	return ss, nil
+}
+
 // GenerateRefreshToken creates a refresh token
-// The refresh token stores only the user's ID, a string
-func GenerateRefreshToken(uid uuid.UUID, key string, exp int64) (*RefreshTokenData, error) {
+// The refresh token stores only the user's ID and the token family
+func GenerateRefreshToken(uid uuid.UUID, fid uuid.UUID, key string, exp int64, opts TokenOptions) (*RefreshTokenData, error) {
+	return GenerateClientRefreshToken(uid, fid, "", key, exp, opts)
+}
+
+// GenerateClientRefreshToken creates a refresh token for the OAuth
+// client with clientID, which is not accepted by first-party routes
+func GenerateClientRefreshToken(uid uuid.UUID, fid uuid.UUID, clientID string, key string, exp int64, opts TokenOptions) (*RefreshTokenData, error) {
 	currentTime := time.Now()
 	tokenExp := currentTime.Add(time.Duration(exp) * time.Second)
 	tokenID, err := uuid.NewRandom() // v4 uuid in the google uuid lib
@@ -72,10 +217,15 @@ func GenerateRefreshToken(uid uuid.UUID, key string, exp int64) (*RefreshTokenDa
 
 	claims := RefreshTokenCustomClaims{
 		UID: uid,
+		FID: fid,
+		CID: clientID,
 		StandardClaims: jwt.StandardClaims{
 			IssuedAt:  currentTime.Unix(),
+			NotBefore: currentTime.Unix(),
 			ExpiresAt: tokenExp.Unix(),
 			Id:        tokenID.String(),
+			Issuer:    opts.Issuer,
+			Audience:  opts.Audience,
 		},
 	}
 
@@ -95,11 +245,19 @@ func GenerateRefreshToken(uid uuid.UUID, key string, exp int64) (*RefreshTokenDa
 }
 
 // ValidateIDToken returns the token's claims if the token is valid
-func ValidateIDToken(tokenString string, key *rsa.PublicKey) (*IDTokenCustomClaims, error) {
+// The key is selected from keys by the kid header of the token
+// and the claims are checked against the policy of v
+func ValidateIDToken(tokenString string, keys *KeySet, v *Verifier) (*IDTokenCustomClaims, error) {
 	claims := &IDTokenCustomClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return key, nil
+	err := v.parse(tokenString, claims, &claims.StandardClaims, func(token *jwt.Token) (interface{}, error) {
+		// access tokens are signed with the same keys, but
+		// must not be accepted in place of id tokens
+		if token.Header["typ"] == AccessTokenType {
+			return nil, fmt.Errorf("unexpected token type: %v", token.Header["typ"])
+		}
+
+		return rsaPublicKey(token, keys)
 	})
 
 	// For now we'll just return the error and handle logging in service level
@@ -107,24 +265,50 @@ func ValidateIDToken(tokenString string, key *rsa.PublicKey) (*IDTokenCustomClai
 		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, fmt.Errorf("ID token is invalid")
+	// id tokens of OAuth clients prove the sign in to the client,
+	// they must not be used to access the account of the user
+	if claims.AuthorizedParty != "" {
+		return nil, fmt.Errorf("id token was issued to client: %s", claims.AuthorizedParty)
+	}
+
+	return claims, nil
+}
+
+// rsaPublicKey returns the public key of keys a token was signed with
+func rsaPublicKey(token *jwt.Token, keys *KeySet) (interface{}, error) {
+	// only RSA keys are used for these tokens, reject anything else
+	// so a public key is never used as secret of another method
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
 
-	claims, ok := token.Claims.(*IDTokenCustomClaims)
+	kid, ok := token.Header["kid"].(string)
 
+	// tokens issued before keys had ids were signed with the active key
 	if !ok {
-		return nil, fmt.Errorf("ID token valid but couldn't parse claims")
+		return keys.Active().Public, nil
 	}
 
-	return claims, nil
+	key, ok := keys.Get(kid)
+
+	if !ok {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+
+	return key.Public, nil
 }
 
 // ValidateRefresh returns the token's claims if the token is valid
-func ValidateRefreshToken(tokenString string, key string) (*RefreshTokenCustomClaims, error) {
+// and the claims pass the policy of v
+func ValidateRefreshToken(tokenString string, key string, v *Verifier) (*RefreshTokenCustomClaims, error) {
 	claims := &RefreshTokenCustomClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+	err := v.parse(tokenString, claims, &claims.StandardClaims, func(t *jwt.Token) (interface{}, error) {
+		// refresh tokens are only signed with our HMAC secret
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
 		return []byte(key), nil
 	})
 
@@ -132,9 +316,5 @@ func ValidateRefreshToken(tokenString string, key string) (*RefreshTokenCustomCl
 		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, fmt.Errorf("refresh token is invalid")
-	}
-
 	return claims, nil
 }
@@ -0,0 +1,91 @@
+package security
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// TokenOptions holds the registered claims written into minted tokens
+type TokenOptions struct {
+	Issuer   string
+	Audience string
+}
+
+// Verifier holds the policy a token is checked against once it has been
+// parsed. An empty Issuer or Audiences disables the respective check
+type Verifier struct {
+	Algorithms []string      // signing algorithms accepted in the alg header
+	Issuer     string        // expected iss claim
+	Audiences  []string      // accepted aud claims
+	Leeway     time.Duration // allowed clock skew when checking exp, nbf and iat
+}
+
+// parse verifies the signature of tokenString with the key returned by keyFunc
+// and checks its registered claims, which are parsed into std, against the policy
+func (v *Verifier) parse(tokenString string, claims jwt.Claims, std *jwt.StandardClaims, keyFunc jwt.Keyfunc) error {
+	if len(v.Algorithms) == 0 {
+		return fmt.Errorf("no signing algorithms are accepted")
+	}
+
+	// claims are validated below, with leeway
+	parser := &jwt.Parser{
+		ValidMethods:         v.Algorithms,
+		SkipClaimsValidation: true,
+	}
+
+	token, err := parser.ParseWithClaims(tokenString, claims, keyFunc)
+
+	if err != nil {
+		return err
+	}
+
+	if !token.Valid {
+		return fmt.Errorf("token is invalid")
+	}
+
+	return v.verifyClaims(std)
+}
+
+// verifyClaims checks the registered claims of a token
+func (v *Verifier) verifyClaims(c *jwt.StandardClaims) error {
+	now := time.Now().Unix()
+	leeway := int64(v.Leeway / time.Second)
+
+	if c.ExpiresAt == 0 {
+		return fmt.Errorf("token has no expiry")
+	}
+
+	if now > c.ExpiresAt+leeway {
+		return fmt.Errorf("token is expired")
+	}
+
+	if c.NotBefore != 0 && now+leeway < c.NotBefore {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	if c.IssuedAt != 0 && now+leeway < c.IssuedAt {
+		return fmt.Errorf("token used before issued")
+	}
+
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return fmt.Errorf("token has invalid issuer: %s", c.Issuer)
+	}
+
+	if len(v.Audiences) > 0 && !contains(v.Audiences, c.Audience) {
+		return fmt.Errorf("token has invalid audience: %s", c.Audience)
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,290 @@
+package security
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidWebAuthn is wrapped by all errors of WebAuthn verification
+var ErrInvalidWebAuthn = errors.New("invalid webauthn response")
+
+// COSE algorithms of credential public keys which are supported
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// Types of client data of the WebAuthn ceremonies
+const (
+	ClientDataTypeCreate = "webauthn.create"
+	ClientDataTypeGet    = "webauthn.get"
+)
+
+// Flags of authenticator data
+const (
+	AuthenticatorFlagUserPresent            byte = 0x01
+	AuthenticatorFlagUserVerified           byte = 0x04
+	AuthenticatorFlagAttestedCredentialData byte = 0x40
+	AuthenticatorFlagExtensionData          byte = 0x80
+)
+
+// ClientData holds the fields of clientDataJSON which are verified
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// AuthenticatorData holds the data an authenticator signs
+// The credential is only set for registrations
+type AuthenticatorData struct {
+	RPIDHash            []byte
+	Flags               byte
+	SignCount           uint32
+	AAGUID              []byte
+	CredentialID        []byte
+	CredentialPublicKey []byte
+}
+
+// DecodeBase64URL decodes base64url values sent by WebAuthn
+// clients, which may or may not be padded
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// ParseClientData parses clientDataJSON and checks its type
+// challenge and origin. Only the challenge is returned,
+// it has to be checked by the caller
+func ParseClientData(raw []byte, expectedType string, origins []string) (*ClientData, error) {
+	cd := &ClientData{}
+
+	if err := json.Unmarshal(raw, cd); err != nil {
+		return nil, fmt.Errorf("%w: client data: %v", ErrInvalidWebAuthn, err)
+	}
+
+	if cd.Type != expectedType {
+		return nil, fmt.Errorf("%w: client data has type %q", ErrInvalidWebAuthn, cd.Type)
+	}
+
+	if cd.Challenge == "" {
+		return nil, fmt.Errorf("%w: client data has no challenge", ErrInvalidWebAuthn)
+	}
+
+	if cd.CrossOrigin {
+		return nil, fmt.Errorf("%w: cross origin requests are not allowed", ErrInvalidWebAuthn)
+	}
+
+	for _, origin := range origins {
+		if cd.Origin == origin {
+			return cd, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: origin %q is not allowed", ErrInvalidWebAuthn, cd.Origin)
+}
+
+// ParseAuthenticatorData parses authenticator data, verifies it was
+// created for rpID and the user was present
+func ParseAuthenticatorData(raw []byte, rpID string) (*AuthenticatorData, error) {
+	// rpIdHash (32), flags (1), signCount (4)
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidWebAuthn)
+	}
+
+	ad := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(ad.RPIDHash, rpIDHash[:]) {
+		return nil, fmt.Errorf("%w: rp id hash does not match", ErrInvalidWebAuthn)
+	}
+
+	if ad.Flags&AuthenticatorFlagUserPresent == 0 {
+		return nil, fmt.Errorf("%w: user was not present", ErrInvalidWebAuthn)
+	}
+
+	rest := raw[37:]
+
+	if ad.Flags&AuthenticatorFlagAttestedCredentialData != 0 {
+		// aaguid (16), credentialIdLength (2), credentialId, credentialPublicKey
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidWebAuthn)
+		}
+
+		ad.AAGUID = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, fmt.Errorf("%w: invalid credential id length", ErrInvalidWebAuthn)
+		}
+
+		ad.CredentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, n, err := decodeCBOR(rest)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %v", ErrInvalidWebAuthn, err)
+		}
+
+		ad.CredentialPublicKey = rest[:n]
+		rest = rest[n:]
+	}
+
+	if ad.Flags&AuthenticatorFlagExtensionData != 0 {
+		_, n, err := decodeCBOR(rest)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: extensions: %v", ErrInvalidWebAuthn, err)
+		}
+
+		rest = rest[n:]
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrInvalidWebAuthn)
+	}
+
+	return ad, nil
+}
+
+// ParseAttestationObject returns the authenticator data of an attestation
+// object. Only the "none" attestation format is accepted, which is what
+// clients send when no attestation is requested
+func ParseAttestationObject(raw []byte) ([]byte, error) {
+	v, n, err := decodeCBOR(raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %v", ErrInvalidWebAuthn, err)
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok || n != len(raw) {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrInvalidWebAuthn)
+	}
+
+	if format, _ := m["fmt"].(string); format != "none" {
+		return nil, fmt.Errorf("%w: unsupported attestation format %q", ErrInvalidWebAuthn, format)
+	}
+
+	authData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object has no authenticator data", ErrInvalidWebAuthn)
+	}
+
+	return authData, nil
+}
+
+// ParseCOSEKey returns the public key and algorithm of a COSE encoded
+// credential public key. ES256, EdDSA and RS256 keys are supported
+func ParseCOSEKey(raw []byte) (crypto.PublicKey, int64, error) {
+	v, n, err := decodeCBOR(raw)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: cose key: %v", ErrInvalidWebAuthn, err)
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok || n != len(raw) {
+		return nil, 0, fmt.Errorf("%w: cose key is not a map", ErrInvalidWebAuthn)
+	}
+
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("%w: invalid ec2 key", ErrInvalidWebAuthn)
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, fmt.Errorf("%w: ec2 key is not on curve", ErrInvalidWebAuthn)
+		}
+
+		return pub, alg, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%w: invalid okp key", ErrInvalidWebAuthn)
+		}
+
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		nb, _ := m[int64(-1)].([]byte)
+		eb, _ := m[int64(-2)].([]byte)
+
+		if len(nb) < 256 || len(eb) == 0 || len(eb) > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid rsa key", ErrInvalidWebAuthn)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nb),
+			E: int(new(big.Int).SetBytes(eb).Int64()),
+		}, alg, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported key type %d with algorithm %d", ErrInvalidWebAuthn, kty, alg)
+	}
+}
+
+// VerifyAssertionSignature verifies the signature of an assertion
+// over authenticator data and the hash of clientDataJSON
+func VerifyAssertionSignature(coseKey []byte, authData []byte, clientDataJSON []byte, signature []byte) error {
+	pub, alg, err := ParseCOSEKey(coseKey)
+
+	if err != nil {
+		return err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	var valid bool
+
+	switch alg {
+	case COSEAlgES256:
+		digest := sha256.Sum256(signed)
+		valid = ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], signature)
+	case COSEAlgEdDSA:
+		valid = ed25519.Verify(pub.(ed25519.PublicKey), signed, signature)
+	case COSEAlgRS256:
+		digest := sha256.Sum256(signed)
+		valid = rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: invalid signature", ErrInvalidWebAuthn)
+	}
+
+	return nil
+}
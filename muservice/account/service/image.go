@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	// register decoders of accepted image formats
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// AvatarSize is the width and height of stored profile images
+const AvatarSize = 256
+
+// maxImageSide protects against images which are small when
+// compressed, but huge once decoded. 4096x4096 pixels decode
+// to at most 64 MB, which is plenty for a profile image
+const maxImageSide = 4096
+
+// processProfileImage decodes data, crops it to a square and scales
+// it to AvatarSize. The result is re-encoded as PNG, which also
+// strips any metadata the original image contained
+func processProfileImage(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image config: %w", err)
+	}
+
+	if cfg.Width > maxImageSide || cfg.Height > maxImageSide {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, resizeSquare(src, AvatarSize)); err != nil {
+		return nil, fmt.Errorf("unable to encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resizeSquare crops the center square of src and scales it to size x size
+// Each destination pixel is the average of the source pixels it covers
+func resizeSquare(src image.Image, size int) *image.RGBA {
+	at := pixelReader(src)
+
+	b := src.Bounds()
+
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		sy0, sy1 := span(y0, y, side, size)
+
+		for x := 0; x < size; x++ {
+			sx0, sx1 := span(x0, x, side, size)
+
+			var r, g, bl, a, n uint32
+
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := at(sx, sy)
+					r += cr
+					g += cg
+					bl += cb
+					a += ca
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// pixelReader returns a function reading the alpha premultiplied 8 bit
+// color of a pixel of src. The buffers of the image types returned by
+// the decoders are read directly, which avoids allocating a color.Color
+// for every pixel, other images are read through At
+func pixelReader(src image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch img := src.(type) {
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			return uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			a := uint32(p[3])
+			return uint32(p[0]) * a / 0xff, uint32(p[1]) * a / 0xff, uint32(p[2]) * a / 0xff, a
+		}
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			ci := img.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(img.Y[img.YOffset(x, y)], img.Cb[ci], img.Cr[ci])
+			return uint32(r), uint32(g), uint32(b), 0xff
+		}
+	case *image.Gray:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			v := uint32(img.Pix[img.PixOffset(x, y)])
+			return v, v, v, 0xff
+		}
+	default:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			r, g, b, a := src.At(x, y).RGBA()
+			return r >> 8, g >> 8, b >> 8, a >> 8
+		}
+	}
+}
+
+// span returns the range of source pixels covered by
+// destination pixel i, at least one pixel wide
+func span(offset int, i int, side int, size int) (int, int) {
+	start := offset + i*side/size
+	end := offset + (i+1)*side/size
+
+	if end <= start {
+		end = start + 1
+	}
+
+	return start, end
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/security"
+	"github.com/google/uuid"
+)
+
+// Defaults used when MFAConfig leaves them unset
+const (
+	DefaultMFAIssuer              = "Account"
+	DefaultMFATokenExpirationSecs = 5 * 60
+	DefaultMFARecoveryCodes       = 10
+)
+
+// totpSkew is the number of time steps codes are accepted
+// before and after the current one, to allow for clock drift
+const totpSkew = 1
+
+// mfaService manages TOTP second factors and the recovery codes of users
+type mfaService struct {
+	MFARepository          models.MFARepository
+	Issuer                 string
+	PendingTokenSecret     string
+	PendingTokenExpiration int64
+	PendingTokenVerifier   *security.Verifier
+	RecoveryCodes          int
+}
+
+// MFAConfig will hold repositories that will eventually be injected into
+// this service layer. Issuer is shown in authenticator apps, mfa pending
+// tokens are signed with PendingTokenSecret
+type MFAConfig struct {
+	MFARepository              models.MFARepository
+	Issuer                     string
+	PendingTokenSecret         string
+	PendingTokenExpirationSecs int64
+	RecoveryCodes              int
+}
+
+// NewMFAService is a factory function for
+// initializing an MFAService with its repository layer dependencies
+func NewMFAService(c *MFAConfig) models.MFAService {
+	issuer := c.Issuer
+	if issuer == "" {
+		issuer = DefaultMFAIssuer
+	}
+
+	pendingTokenExp := c.PendingTokenExpirationSecs
+	if pendingTokenExp <= 0 {
+		pendingTokenExp = DefaultMFATokenExpirationSecs
+	}
+
+	recoveryCodes := c.RecoveryCodes
+	if recoveryCodes <= 0 {
+		recoveryCodes = DefaultMFARecoveryCodes
+	}
+
+	return &mfaService{
+		MFARepository:          c.MFARepository,
+		Issuer:                 issuer,
+		PendingTokenSecret:     c.PendingTokenSecret,
+		PendingTokenExpiration: pendingTokenExp,
+		PendingTokenVerifier: &security.Verifier{
+			Algorithms: []string{"HS256"},
+		},
+		RecoveryCodes: recoveryCodes,
+	}
+}
+
+// Enroll creates a new TOTP secret for a user, which is
+// only enabled once confirmed with a code
+func (s *mfaService) Enroll(ctx context.Context, u *models.User) (*models.MFAEnrollment, error) {
+	mfa, err := s.MFARepository.FindByUID(ctx, u.UID)
+
+	if err != nil && apperrors.Status(err) != http.StatusNotFound {
+		return nil, err
+	}
+
+	if err == nil && mfa.Enabled {
+		return nil, apperrors.NewBadRequest("Two-factor authentication is already enabled")
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+
+	if err != nil {
+		log.Printf("Unable to generate totp secret for uid: %v. Error: %v\n", u.UID, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	if err := s.MFARepository.SetSecret(ctx, u.UID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollment{
+		Secret: secret,
+		URI:    security.TOTPURI(s.Issuer, u.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables the second factor of a user with the first
+// code of their authenticator app and returns new recovery codes
+func (s *mfaService) ConfirmEnrollment(ctx context.Context, uid uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.MFARepository.FindByUID(ctx, uid)
+
+	if err != nil {
+		if apperrors.Status(err) == http.StatusNotFound {
+			return nil, apperrors.NewBadRequest("Two-factor authentication enrollment was not started")
+		}
+
+		return nil, err
+	}
+
+	if mfa.Enabled {
+		return nil, apperrors.NewBadRequest("Two-factor authentication is already enabled")
+	}
+
+	step, ok := security.ValidateTOTP(mfa.Secret, code, time.Now(), totpSkew)
+
+	if !ok {
+		return nil, apperrors.NewAuthorization("Invalid two-factor authentication code")
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes(uid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.MFARepository.Enable(ctx, uid, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable removes the second factor of a user after checking code
+func (s *mfaService) Disable(ctx context.Context, uid uuid.UUID, code string) error {
+	if err := s.Verify(ctx, uid, code); err != nil {
+		return err
+	}
+
+	return s.MFARepository.Delete(ctx, uid)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user after checking code
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, uid uuid.UUID, code string) ([]string, error) {
+	if err := s.Verify(ctx, uid, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes(uid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.MFARepository.ReplaceRecoveryCodes(ctx, uid, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// IsEnabled reports whether a user has to provide a second factor
+func (s *mfaService) IsEnabled(ctx context.Context, uid uuid.UUID) (bool, error) {
+	mfa, err := s.MFARepository.FindByUID(ctx, uid)
+
+	if err != nil {
+		if apperrors.Status(err) == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return mfa.Enabled, nil
+}
+
+// Verify checks a TOTP code or recovery code of a user
+// Both can only be used once
+func (s *mfaService) Verify(ctx context.Context, uid uuid.UUID, code string) error {
+	mfa, err := s.MFARepository.FindByUID(ctx, uid)
+
+	if err != nil && apperrors.Status(err) != http.StatusNotFound {
+		return err
+	}
+
+	if err != nil || !mfa.Enabled {
+		return apperrors.NewBadRequest("Two-factor authentication is not enabled")
+	}
+
+	invalid := apperrors.NewAuthorization("Invalid two-factor authentication code")
+
+	if len(code) == security.TOTPDigits {
+		step, ok := security.ValidateTOTP(mfa.Secret, code, time.Now(), totpSkew)
+
+		if !ok {
+			return invalid
+		}
+
+		if err := s.MFARepository.UseStep(ctx, uid, step); err != nil {
+			if apperrors.Status(err) == http.StatusNotFound {
+				log.Printf("Rejected reused totp code of uid: %v\n", uid)
+				return invalid
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	if err := s.MFARepository.UseRecoveryCode(ctx, uid, security.HashRecoveryCode(code)); err != nil {
+		if apperrors.Status(err) == http.StatusNotFound {
+			return invalid
+		}
+
+		return err
+	}
+
+	log.Printf("Recovery code was used by uid: %v\n", uid)
+
+	return nil
+}
+
+// NewPendingToken creates a token for a user which provided their password,
+// it is exchanged for tokens together with a second factor
+func (s *mfaService) NewPendingToken(ctx context.Context, u *models.User) (string, error) {
+	token, err := security.GenerateMFAToken(u.UID, u.Email, s.PendingTokenSecret, s.PendingTokenExpiration)
+
+	if err != nil {
+		log.Printf("Error generating mfa token for uid: %v. Error: %v\n", u.UID, err.Error())
+		return "", apperrors.NewInternal()
+	}
+
+	return token, nil
+}
+
+// ValidatePendingToken returns the user, holding only UID
+// and Email, a valid mfa pending token was created for
+func (s *mfaService) ValidatePendingToken(tokenString string) (*models.User, error) {
+	claims, err := security.ValidateMFAToken(tokenString, s.PendingTokenSecret, s.PendingTokenVerifier)
+
+	if err != nil {
+		log.Printf("Unable to validate mfa token - Error: %v\n", err)
+		return nil, apperrors.NewAuthorization("Unable to verify mfa token")
+	}
+
+	uid, err := uuid.Parse(claims.Subject)
+
+	if err != nil {
+		log.Printf("Claims of mfa token hold an invalid uid: %v\n", claims.Subject)
+		return nil, apperrors.NewAuthorization("Unable to verify mfa token")
+	}
+
+	return &models.User{
+		UID:   uid,
+		Email: claims.Email,
+	}, nil
+}
+
+// generateRecoveryCodes returns new recovery codes and their hashes
+func (s *mfaService) generateRecoveryCodes(uid uuid.UUID) ([]string, []string, error) {
+	codes, err := security.GenerateRecoveryCodes(s.RecoveryCodes)
+
+	if err != nil {
+		log.Printf("Unable to generate recovery codes for uid: %v. Error: %v\n", uid, err)
+		return nil, nil, apperrors.NewInternal()
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = security.HashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/NetworkPy/muserv/muservice/account/security"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const mfaTestSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func currentTOTPCode(t *testing.T) (string, int64) {
+	step := security.TOTPStep(time.Now())
+	code, err := security.TOTPCode(mfaTestSecret, step)
+	assert.NoError(t, err)
+
+	return code, step
+}
+
+func TestMFAEnroll(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	u := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+			Issuer:        "Muserv",
+		})
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(nil, apperrors.NewNotFound("mfa", uid.String()))
+		mockMFARepository.On("SetSecret", mock.Anything, uid, mock.AnythingOfType("string")).Return(nil)
+
+		enrollment, err := ms.Enroll(context.TODO(), u)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, enrollment.Secret)
+		assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/Muserv:bob@bob.com?"))
+		assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+		mockMFARepository.AssertCalled(t, "SetSecret", mock.Anything, uid, enrollment.Secret)
+	})
+
+	t.Run("Already enabled", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(&models.MFA{UID: uid, Secret: mfaTestSecret, Enabled: true}, nil)
+
+		enrollment, err := ms.Enroll(context.TODO(), u)
+
+		assert.Nil(t, enrollment)
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+		mockMFARepository.AssertNotCalled(t, "SetSecret", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestMFAConfirmEnrollment(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+
+	t.Run("Success", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+			RecoveryCodes: 4,
+		})
+
+		code, step := currentTOTPCode(t)
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(&models.MFA{UID: uid, Secret: mfaTestSecret}, nil)
+		mockMFARepository.On("Enable", mock.Anything, uid, step, mock.AnythingOfType("[]string")).Return(nil)
+
+		codes, err := ms.ConfirmEnrollment(context.TODO(), uid, code)
+
+		assert.NoError(t, err)
+		assert.Len(t, codes, 4)
+
+		// only hashes of the codes are stored
+		hashes := mockMFARepository.Calls[1].Arguments.Get(3).([]string)
+		for i, code := range codes {
+			assert.Equal(t, security.HashRecoveryCode(code), hashes[i])
+		}
+	})
+
+	t.Run("Invalid code", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(&models.MFA{UID: uid, Secret: mfaTestSecret}, nil)
+
+		codes, err := ms.ConfirmEnrollment(context.TODO(), uid, "abcdef")
+
+		assert.Nil(t, codes)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockMFARepository.AssertNotCalled(t, "Enable", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not started", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(nil, apperrors.NewNotFound("mfa", uid.String()))
+
+		_, err := ms.ConfirmEnrollment(context.TODO(), uid, "123456")
+
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+	})
+}
+
+func TestMFAVerify(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	enabled := &models.MFA{UID: uid, Secret: mfaTestSecret, Enabled: true}
+
+	t.Run("Valid totp code", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		code, step := currentTOTPCode(t)
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(enabled, nil)
+		mockMFARepository.On("UseStep", mock.Anything, uid, step).Return(nil)
+
+		err := ms.Verify(context.TODO(), uid, code)
+
+		assert.NoError(t, err)
+		mockMFARepository.AssertExpectations(t)
+	})
+
+	t.Run("Reused totp code", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		code, step := currentTOTPCode(t)
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(enabled, nil)
+		mockMFARepository.On("UseStep", mock.Anything, uid, step).Return(apperrors.NewNotFound("step", uid.String()))
+
+		err := ms.Verify(context.TODO(), uid, code)
+
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+	})
+
+	t.Run("Recovery code", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(enabled, nil)
+		mockMFARepository.On("UseRecoveryCode", mock.Anything, uid, security.HashRecoveryCode("abcdabcdabcdabcd")).Return(nil)
+
+		err := ms.Verify(context.TODO(), uid, "ABCD-abcd-ABCD-abcd")
+
+		assert.NoError(t, err)
+		mockMFARepository.AssertExpectations(t)
+	})
+
+	t.Run("Unknown recovery code", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(enabled, nil)
+		mockMFARepository.On("UseRecoveryCode", mock.Anything, uid, mock.AnythingOfType("string")).Return(apperrors.NewNotFound("recovery code", uid.String()))
+
+		err := ms.Verify(context.TODO(), uid, "abcd-abcd-abcd-abcd")
+
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+	})
+
+	t.Run("Not enabled", func(t *testing.T) {
+		mockMFARepository := new(mocks.MockMFARepository)
+		ms := NewMFAService(&MFAConfig{
+			MFARepository: mockMFARepository,
+		})
+
+		mockMFARepository.On("FindByUID", mock.Anything, uid).Return(&models.MFA{UID: uid, Secret: mfaTestSecret}, nil)
+
+		err := ms.Verify(context.TODO(), uid, "123456")
+
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+		mockMFARepository.AssertNotCalled(t, "UseStep", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestMFAPendingToken(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	u := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+		Name:  "Bob",
+	}
+
+	ms := NewMFAService(&MFAConfig{
+		PendingTokenSecret: "anmfasecret",
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		token, err := ms.NewPendingToken(context.TODO(), u)
+		assert.NoError(t, err)
+
+		pending, err := ms.ValidatePendingToken(token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.User{UID: uid, Email: u.Email}, pending)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		other := NewMFAService(&MFAConfig{
+			PendingTokenSecret: "anothersecret",
+		})
+
+		token, err := other.NewPendingToken(context.TODO(), u)
+		assert.NoError(t, err)
+
+		pending, err := ms.ValidatePendingToken(token)
+
+		assert.Nil(t, pending)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+	})
+
+	t.Run("Email token is rejected", func(t *testing.T) {
+		token, err := security.GenerateEmailToken(uid, u.Email, security.PurposeVerifyEmail, "anmfasecret", 60)
+		assert.NoError(t, err)
+
+		pending, err := ms.ValidatePendingToken(token)
+
+		assert.Nil(t, pending)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,671 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/security"
+	"github.com/google/uuid"
+)
+
+// Defaults used when OAuthConfig leaves them unset
+const (
+	DefaultAccessTokenExpirationSecs       = 15 * 60
+	DefaultAuthorizationCodeExpirationSecs = 60
+)
+
+// oauthService is an OAuth 2.0 authorization server issuing
+// authorization codes with PKCE to registered clients and
+// access tokens to service clients with the client_credentials grant
+type oauthService struct {
+	OAuthRepository       models.OAuthRepository
+	TokenRepository       models.TokenRepository
+	KeySet                *security.KeySet
+	RefreshSecret         string
+	AccessExpirationSecs  int64
+	RefreshExpirationSecs int64
+	CodeExpiration        time.Duration
+	TokenOptions          security.TokenOptions
+	RefreshTokenVerifier  *security.Verifier
+}
+
+// OAuthConfig will hold repositories that will eventually be injected into
+// this service layer. Access tokens are signed with the KeySet of id tokens,
+// refresh tokens with the RefreshSecret and are stored in the TokenRepository
+// with first-party refresh tokens, so they are revoked the same way
+type OAuthConfig struct {
+	OAuthRepository       models.OAuthRepository
+	TokenRepository       models.TokenRepository
+	KeySet                *security.KeySet
+	RefreshSecret         string
+	AccessExpirationSecs  int64
+	RefreshExpirationSecs int64
+	CodeExpirationSecs    int64
+	Issuer                string
+	Audience              string
+	RefreshTokenVerifier  *security.Verifier
+}
+
+// NewOAuthService is a factory function for
+// initializing an OAuthService with its repository layer dependencies
+func NewOAuthService(c *OAuthConfig) models.OAuthService {
+	accessExp := c.AccessExpirationSecs
+	if accessExp <= 0 {
+		accessExp = DefaultAccessTokenExpirationSecs
+	}
+
+	codeExp := c.CodeExpirationSecs
+	if codeExp <= 0 {
+		codeExp = DefaultAuthorizationCodeExpirationSecs
+	}
+
+	refreshTokenVerifier := c.RefreshTokenVerifier
+	if refreshTokenVerifier == nil {
+		refreshTokenVerifier = &security.Verifier{
+			Algorithms: []string{"HS256"},
+			Issuer:     c.Issuer,
+		}
+
+		if c.Audience != "" {
+			refreshTokenVerifier.Audiences = []string{c.Audience}
+		}
+	}
+
+	return &oauthService{
+		OAuthRepository:       c.OAuthRepository,
+		TokenRepository:       c.TokenRepository,
+		KeySet:                c.KeySet,
+		RefreshSecret:         c.RefreshSecret,
+		AccessExpirationSecs:  accessExp,
+		RefreshExpirationSecs: c.RefreshExpirationSecs,
+		CodeExpiration:        time.Duration(codeExp) * time.Second,
+		TokenOptions: security.TokenOptions{
+			Issuer:   c.Issuer,
+			Audience: c.Audience,
+		},
+		RefreshTokenVerifier: refreshTokenVerifier,
+	}
+}
+
+// CreateClient registers a new OAuth client
+// A client id is generated if c has none
+func (s *oauthService) CreateClient(ctx context.Context, c *models.OAuthClient) error {
+	if len(c.RedirectURIs) == 0 {
+		return apperrors.NewBadRequest("At least one redirect uri is required")
+	}
+
+	for _, redirectURI := range c.RedirectURIs {
+		u, err := url.Parse(redirectURI)
+
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return apperrors.NewBadRequest("Redirect uris must be absolute and must not have a fragment")
+		}
+	}
+
+	if c.ClientID == "" {
+		c.ClientID = uuid.New().String()
+	}
+
+	if c.Scopes == nil {
+		c.Scopes = []string{}
+	}
+
+	return s.OAuthRepository.CreateClient(ctx, c)
+}
+
+// ListClients returns all registered OAuth clients
+func (s *oauthService) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	return s.OAuthRepository.ListClients(ctx)
+}
+
+// CreateServiceClient registers a new service client and returns its
+// secret, which can't be recovered later. A client id is generated if
+// c has none
+func (s *oauthService) CreateServiceClient(ctx context.Context, c *models.ServiceClient) (string, error) {
+	if c.ClientID == "" {
+		c.ClientID = uuid.New().String()
+	}
+
+	if c.Scopes == nil {
+		c.Scopes = []string{}
+	}
+
+	secret, err := security.GenerateOpaqueToken()
+
+	if err != nil {
+		log.Printf("Unable to generate secret of service client: %v. Error: %v\n", c.ClientID, err)
+		return "", apperrors.NewInternal()
+	}
+
+	c.SecretHash = security.HashOpaqueToken(secret)
+
+	if err := s.OAuthRepository.CreateServiceClient(ctx, c); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// ListServiceClients returns all registered service clients
+func (s *oauthService) ListServiceClients(ctx context.Context) ([]*models.ServiceClient, error) {
+	return s.OAuthRepository.ListServiceClients(ctx)
+}
+
+// DeleteServiceClient removes a service client, so it
+// can't get new access tokens
+func (s *oauthService) DeleteServiceClient(ctx context.Context, clientID string) error {
+	return s.OAuthRepository.DeleteServiceClient(ctx, clientID)
+}
+
+// AuthenticateClient returns the service client with clientID if
+// secret is its secret, otherwise an OAuthError is returned
+func (s *oauthService) AuthenticateClient(ctx context.Context, clientID string, secret string) (*models.ServiceClient, error) {
+	return s.authenticateServiceClient(ctx, clientID, secret)
+}
+
+// AuthenticatePublicClient returns the OAuth client with clientID
+// These clients have no secret, so they are only identified
+func (s *oauthService) AuthenticatePublicClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Client authentication failed")
+	}
+
+	return s.findClient(ctx, clientID)
+}
+
+// authorization is a validated authorization request
+type authorization struct {
+	client          *models.OAuthClient
+	redirectURI     string
+	redirectURISent bool
+	scopes          []string
+}
+
+// Authorize handles an authorization request of the user. If the user
+// has already consented to the requested scopes a code is issued right
+// away, otherwise the result asks for the user's consent
+func (s *oauthService) Authorize(ctx context.Context, uid uuid.UUID, req *models.AuthorizeRequest) (*models.AuthorizeResult, error) {
+	a, err := s.authorization(ctx, req)
+
+	if err != nil {
+		return authorizeError(a, req, err)
+	}
+
+	consent, err := s.OAuthRepository.FindConsent(ctx, uid, a.client.ClientID)
+
+	if err != nil && apperrors.Status(err) != http.StatusNotFound {
+		return nil, err
+	}
+
+	if consent == nil || !containsScopes(consent.Scopes, a.scopes) {
+		return &models.AuthorizeResult{
+			ConsentRequired: true,
+			Client:          a.client,
+			Scopes:          a.scopes,
+		}, nil
+	}
+
+	return s.issueCode(ctx, uid, a, req)
+}
+
+// Consent records the user's decision on an authorization request
+// and issues a code if the user approved it
+func (s *oauthService) Consent(ctx context.Context, uid uuid.UUID, req *models.AuthorizeRequest, approved bool) (*models.AuthorizeResult, error) {
+	a, err := s.authorization(ctx, req)
+
+	if err != nil {
+		return authorizeError(a, req, err)
+	}
+
+	if !approved {
+		return authorizeError(a, req, apperrors.NewOAuthError(apperrors.AccessDenied, "The user denied the request"))
+	}
+
+	consent, err := s.OAuthRepository.FindConsent(ctx, uid, a.client.ClientID)
+
+	if err != nil && apperrors.Status(err) != http.StatusNotFound {
+		return nil, err
+	}
+
+	// scopes granted before are kept
+	scopes := a.scopes
+	if consent != nil {
+		scopes = mergeScopes(consent.Scopes, a.scopes)
+	}
+
+	err = s.OAuthRepository.SaveConsent(ctx, &models.OAuthConsent{
+		UID:      uid,
+		ClientID: a.client.ClientID,
+		Scopes:   scopes,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueCode(ctx, uid, a, req)
+}
+
+// authorization validates an authorization request. Errors about the
+// client or redirect uri are returned as BadRequest, as the user must
+// not be redirected to an unverified uri. Otherwise an OAuthError is
+// returned along with the authorization, to redirect the user with it
+func (s *oauthService) authorization(ctx context.Context, req *models.AuthorizeRequest) (*authorization, error) {
+	client, err := s.OAuthRepository.FindClient(ctx, req.ClientID)
+
+	if apperrors.Status(err) == http.StatusNotFound {
+		return nil, apperrors.NewBadRequest("Unknown client_id")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURI := req.RedirectURI
+
+	// the redirect uri may be left out if the client has only one
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+
+	if !contains(client.RedirectURIs, redirectURI) {
+		return nil, apperrors.NewBadRequest("redirect_uri is not registered for the client")
+	}
+
+	a := &authorization{
+		client:          client,
+		redirectURI:     redirectURI,
+		redirectURISent: req.RedirectURI != "",
+	}
+
+	if req.ResponseType != "code" {
+		return a, apperrors.NewOAuthError(apperrors.UnsupportedResponseType, "Only the code response type is supported")
+	}
+
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != security.PKCEMethodS256 {
+		return a, apperrors.NewOAuthError(apperrors.InvalidRequest, "A code_challenge with code_challenge_method S256 is required")
+	}
+
+	a.scopes = parseScope(req.Scope)
+
+	// clients are granted all their scopes if none are requested
+	if len(a.scopes) == 0 {
+		a.scopes = client.Scopes
+	}
+
+	if !containsScopes(client.Scopes, a.scopes) {
+		return a, apperrors.NewOAuthError(apperrors.InvalidScope, "The client may not request the scope")
+	}
+
+	return a, nil
+}
+
+// authorizeError returns a result redirecting the user with err
+// if it is an OAuthError, or returns err otherwise
+func authorizeError(a *authorization, req *models.AuthorizeRequest, err error) (*models.AuthorizeResult, error) {
+	oauthErr, ok := err.(*apperrors.OAuthError)
+
+	if !ok || a == nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("error", string(oauthErr.Code))
+	params.Set("error_description", oauthErr.Description)
+
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	return &models.AuthorizeResult{
+		RedirectTo: withQuery(a.redirectURI, params),
+	}, nil
+}
+
+// issueCode stores a new authorization code and
+// returns the result redirecting the user with it
+func (s *oauthService) issueCode(ctx context.Context, uid uuid.UUID, a *authorization, req *models.AuthorizeRequest) (*models.AuthorizeResult, error) {
+	code, err := security.GenerateOpaqueToken()
+
+	if err != nil {
+		log.Printf("Error generating authorization code for uid: %v. Error: %v\n", uid, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	err = s.TokenRepository.SetAuthorizationCode(ctx, security.HashOpaqueToken(code), &models.AuthorizationCode{
+		ClientID:        a.client.ClientID,
+		UID:             uid,
+		RedirectURI:     a.redirectURI,
+		RedirectURISent: a.redirectURISent,
+		Scope:           strings.Join(a.scopes, " "),
+		CodeChallenge:   req.CodeChallenge,
+		Nonce:           req.Nonce,
+	}, s.CodeExpiration)
+
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	return &models.AuthorizeResult{
+		RedirectTo: withQuery(a.redirectURI, params),
+	}, nil
+}
+
+// Token handles a request to the token endpoint
+// Errors are returned as OAuthError unless they are internal
+func (s *oauthService) Token(ctx context.Context, req *models.TokenRequest) (*models.OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, req)
+	case models.GrantTypeRefreshToken:
+		return s.refresh(ctx, req)
+	case models.GrantTypeClientCredentials:
+		return s.clientCredentials(ctx, req)
+	default:
+		return nil, apperrors.NewOAuthError(apperrors.UnsupportedGrantType, "The grant type is not supported")
+	}
+}
+
+// exchangeCode issues tokens for an authorization code
+func (s *oauthService) exchangeCode(ctx context.Context, req *models.TokenRequest) (*models.OAuthTokenResponse, error) {
+	if req.Code == "" || req.ClientID == "" || req.CodeVerifier == "" {
+		return nil, apperrors.NewOAuthError(apperrors.InvalidRequest, "code, client_id and code_verifier are required")
+	}
+
+	client, err := s.findClient(ctx, req.ClientID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.TokenRepository.ConsumeAuthorizationCode(ctx, security.HashOpaqueToken(req.Code))
+
+	if apperrors.Status(err) == http.StatusUnauthorized {
+		return nil, apperrors.NewOAuthError(apperrors.InvalidGrant, "The authorization code is invalid or has expired")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the redirect uri has to be sent again only if it was sent to
+	// the authorization endpoint (RFC 6749 section 4.1.3)
+	redirectURIMatches := req.RedirectURI == code.RedirectURI || (!code.RedirectURISent && req.RedirectURI == "")
+
+	if code.ClientID != client.ClientID || !redirectURIMatches {
+		log.Printf("Authorization code of client: %s redeemed by client: %s\n", code.ClientID, client.ClientID)
+		return nil, apperrors.NewOAuthError(apperrors.InvalidGrant, "The authorization code was issued to another client or redirect_uri")
+	}
+
+	if !security.VerifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+		return nil, apperrors.NewOAuthError(apperrors.InvalidGrant, "The code_verifier does not match the code_challenge")
+	}
+
+	return s.newTokens(ctx, code.UID, uuid.Nil, &models.Session{
+		ClientID: client.ClientID,
+		Scope:    code.Scope,
+	}, code.Scope, code.Nonce)
+}
+
+// refresh rotates a refresh token of a client and issues new tokens
+// Reusing a rotated refresh token revokes its token family
+func (s *oauthService) refresh(ctx context.Context, req *models.TokenRequest) (*models.OAuthTokenResponse, error) {
+	if req.RefreshToken == "" || req.ClientID == "" {
+		return nil, apperrors.NewOAuthError(apperrors.InvalidRequest, "refresh_token and client_id are required")
+	}
+
+	client, err := s.findClient(ctx, req.ClientID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := security.ValidateRefreshToken(req.RefreshToken, s.RefreshSecret, s.RefreshTokenVerifier)
+
+	// first-party refresh tokens have no client and are rejected as well
+	if err != nil || claims.CID != client.ClientID {
+		log.Printf("Invalid refresh token for client: %s. Error: %v\n", client.ClientID, err)
+		return nil, apperrors.NewOAuthError(apperrors.InvalidGrant, "The refresh token is invalid")
+	}
+
+	userID := claims.UID.String()
+
+	prevSession, err := s.TokenRepository.RotateRefreshToken(ctx, userID, claims.Id, time.Duration(s.RefreshExpirationSecs)*time.Second)
+
+	if apperrors.Status(err) == http.StatusUnauthorized {
+		revokeReusedFamily(ctx, s.TokenRepository, userID, claims.Id)
+		return nil, apperrors.NewOAuthError(apperrors.InvalidGrant, "The refresh token is invalid")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// access tokens may be narrowed to some of the granted scopes
+	scope := prevSession.Scope
+	if requested := parseScope(req.Scope); len(requested) > 0 {
+		if !containsScopes(parseScope(prevSession.Scope), requested) {
+			return nil, apperrors.NewOAuthError(apperrors.InvalidScope, "The scope was not granted")
+		}
+
+		scope = strings.Join(requested, " ")
+	}
+
+	return s.newTokens(ctx, claims.UID, claims.FID, prevSession, scope, "")
+}
+
+// clientCredentials issues an access token to a service client for itself
+// No refresh token is issued, the client authenticates again instead
+func (s *oauthService) clientCredentials(ctx context.Context, req *models.TokenRequest) (*models.OAuthTokenResponse, error) {
+	client, err := s.authenticateServiceClient(ctx, req.ClientID, req.ClientSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// clients are granted all their scopes if none are requested
+	scopes := parseScope(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+
+	if !containsScopes(client.Scopes, scopes) {
+		return nil, apperrors.NewOAuthError(apperrors.InvalidScope, "The client may not request the scope")
+	}
+
+	scope := strings.Join(scopes, " ")
+
+	// the client acts for itself, so it is the subject of the token
+	accessToken, err := security.GenerateAccessToken(client.ClientID, client.ClientID, scope, s.KeySet.Active(), s.AccessExpirationSecs, s.TokenOptions)
+
+	if err != nil {
+		log.Printf("Error generating access token for service client: %v. Error: %v\n", client.ClientID, err.Error())
+		return nil, apperrors.NewInternal()
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   s.AccessExpirationSecs,
+		Scope:       scope,
+	}, nil
+}
+
+// authenticateServiceClient returns the service client with clientID
+// if secret is its secret
+func (s *oauthService) authenticateServiceClient(ctx context.Context, clientID string, secret string) (*models.ServiceClient, error) {
+	invalid := apperrors.NewOAuthError(apperrors.InvalidClient, "Client authentication failed")
+
+	if clientID == "" || secret == "" {
+		return nil, invalid
+	}
+
+	client, err := s.OAuthRepository.FindServiceClient(ctx, clientID)
+
+	if apperrors.Status(err) == http.StatusNotFound {
+		log.Printf("Authentication of unknown service client: %s\n", clientID)
+		return nil, invalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(security.HashOpaqueToken(secret)), []byte(client.SecretHash)) != 1 {
+		log.Printf("Service client: %s authenticated with a wrong secret\n", clientID)
+		return nil, invalid
+	}
+
+	return client, nil
+}
+
+// findClient returns the client with clientID or an invalid_client error
+func (s *oauthService) findClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	client, err := s.OAuthRepository.FindClient(ctx, clientID)
+
+	if apperrors.Status(err) == http.StatusNotFound {
+		return nil, apperrors.NewOAuthError(apperrors.InvalidClient, "Unknown client_id")
+	}
+
+	return client, err
+}
+
+// newTokens issues an access token with scope and a refresh token
+// continuing session, which starts a new token family if fid is nil
+// An id token with nonce is issued too if scope includes openid
+// The session records the client found in ctx
+func (s *oauthService) newTokens(ctx context.Context, uid uuid.UUID, fid uuid.UUID, session *models.Session, scope string, nonce string) (*models.OAuthTokenResponse, error) {
+	if fid == uuid.Nil {
+		newFID, err := uuid.NewRandom()
+
+		if err != nil {
+			log.Printf("Error generating token family for uid: %v. Error: %v\n", uid, err.Error())
+			return nil, apperrors.NewInternal()
+		}
+
+		fid = newFID
+	}
+
+	accessToken, err := security.GenerateAccessToken(uid.String(), session.ClientID, scope, s.KeySet.Active(), s.AccessExpirationSecs, s.TokenOptions)
+
+	if err != nil {
+		log.Printf("Error generating access token for uid: %v. Error: %v\n", uid, err.Error())
+		return nil, apperrors.NewInternal()
+	}
+
+	refreshToken, err := security.GenerateClientRefreshToken(uid, fid, session.ClientID, s.RefreshSecret, s.RefreshExpirationSecs, s.TokenOptions)
+
+	if err != nil {
+		log.Printf("Error generating refresh token for uid: %v. Error: %v\n", uid, err.Error())
+		return nil, apperrors.NewInternal()
+	}
+
+	var idToken string
+
+	if contains(parseScope(scope), models.ScopeOpenID) {
+		idToken, err = security.GenerateClientIDToken(uid, session.ClientID, nonce, s.KeySet.Active(), s.AccessExpirationSecs, s.TokenOptions)
+
+		if err != nil {
+			log.Printf("Error generating id token of client: %s for uid: %v. Error: %v\n", session.ClientID, uid, err.Error())
+			return nil, apperrors.NewInternal()
+		}
+	}
+
+	client := models.ClientInfoFromContext(ctx)
+
+	session.FamilyID = fid.String()
+	session.UserAgent = client.UserAgent
+	session.IP = client.IP
+	session.LastRefreshedAt = time.Now()
+
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = session.LastRefreshedAt
+	}
+
+	if err := s.TokenRepository.SetRefreshToken(ctx, uid.String(), refreshToken.ID.String(), session, refreshToken.ExpiresIn); err != nil {
+		log.Printf("Error storing refresh token of client: %s for uid: %v. Error: %v\n", session.ClientID, uid, err.Error())
+		return nil, apperrors.NewInternal()
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    s.AccessExpirationSecs,
+		RefreshToken: refreshToken.SS,
+		Scope:        scope,
+		IDToken:      idToken,
+	}, nil
+}
+
+// parseScope splits a space separated scope into its unique scopes
+func parseScope(scope string) []string {
+	scopes := []string{}
+
+	for _, s := range strings.Fields(scope) {
+		if !contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+// containsScopes reports whether every scope of requested is in granted
+func containsScopes(granted []string, requested []string) bool {
+	for _, r := range requested {
+		if !contains(granted, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// contains reports whether v is one of values
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeScopes returns the scopes in a or b
+func mergeScopes(a []string, b []string) []string {
+	return parseScope(strings.Join(a, " ") + " " + strings.Join(b, " "))
+}
+
+// withQuery adds params to the query of rawURL
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+
+	for k, v := range params {
+		q[k] = v
+	}
+
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
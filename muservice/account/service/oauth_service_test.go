@@ -0,0 +1,667 @@
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/NetworkPy/muserv/muservice/account/security"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestOAuthService(t *testing.T) (models.OAuthService, *mocks.MockOAuthRepository, *mocks.MockTokenRepository, *security.KeySet) {
+	priv, _ := ioutil.ReadFile("../rsa_private_test.pem")
+	privKey, _ := jwt.ParseRSAPrivateKeyFromPEM(priv)
+	keySet, err := security.NewKeySet(security.NewSigningKey(privKey))
+	assert.NoError(t, err)
+
+	mockOAuthRepository := new(mocks.MockOAuthRepository)
+	mockTokenRepository := new(mocks.MockTokenRepository)
+
+	s := NewOAuthService(&OAuthConfig{
+		OAuthRepository:       mockOAuthRepository,
+		TokenRepository:       mockTokenRepository,
+		KeySet:                keySet,
+		RefreshSecret:         "anotsorandomtestsecret",
+		RefreshExpirationSecs: 60,
+	})
+
+	return s, mockOAuthRepository, mockTokenRepository, keySet
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	client := &models.OAuthClient{
+		ClientID:     "aclient",
+		Name:         "A Client",
+		RedirectURIs: []string{"https://client.example.com/callback"},
+		Scopes:       []string{"read", "write"},
+	}
+	verifier := strings.Repeat("v", 43)
+
+	newRequest := func() *models.AuthorizeRequest {
+		return &models.AuthorizeRequest{
+			ResponseType:        "code",
+			ClientID:            "aclient",
+			Scope:               "read",
+			State:               "astate",
+			CodeChallenge:       security.PKCEChallenge(verifier),
+			CodeChallengeMethod: "S256",
+			Nonce:               "anonce",
+		}
+	}
+
+	t.Run("Unknown client", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(nil, apperrors.NewNotFound("client_id", "aclient"))
+
+		result, err := s.Authorize(context.TODO(), uid, newRequest())
+
+		assert.Nil(t, result)
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+	})
+
+	t.Run("Unregistered redirect uri", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+
+		req := newRequest()
+		req.RedirectURI = "https://attacker.example.com/callback"
+
+		result, err := s.Authorize(context.TODO(), uid, req)
+
+		assert.Nil(t, result)
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+	})
+
+	t.Run("Missing PKCE redirects with an error", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+
+		req := newRequest()
+		req.CodeChallengeMethod = "plain"
+
+		result, err := s.Authorize(context.TODO(), uid, req)
+
+		assert.NoError(t, err)
+
+		redirect, _ := url.Parse(result.RedirectTo)
+		assert.Equal(t, "client.example.com", redirect.Host)
+		assert.Equal(t, "invalid_request", redirect.Query().Get("error"))
+		assert.Equal(t, "astate", redirect.Query().Get("state"))
+	})
+
+	t.Run("Scope not allowed", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+
+		req := newRequest()
+		req.Scope = "read admin"
+
+		result, err := s.Authorize(context.TODO(), uid, req)
+
+		assert.NoError(t, err)
+
+		redirect, _ := url.Parse(result.RedirectTo)
+		assert.Equal(t, "invalid_scope", redirect.Query().Get("error"))
+	})
+
+	t.Run("Consent required", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockOAuthRepository.On("FindConsent", mock.Anything, uid, "aclient").Return(&models.OAuthConsent{
+			UID:      uid,
+			ClientID: "aclient",
+			Scopes:   []string{"write"},
+		}, nil)
+
+		result, err := s.Authorize(context.TODO(), uid, newRequest())
+
+		assert.NoError(t, err)
+		assert.True(t, result.ConsentRequired)
+		assert.Equal(t, client, result.Client)
+		assert.Equal(t, []string{"read"}, result.Scopes)
+		assert.Empty(t, result.RedirectTo)
+		mockTokenRepository.AssertNotCalled(t, "SetAuthorizationCode", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Consented", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		var codeHash string
+		var code *models.AuthorizationCode
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockOAuthRepository.On("FindConsent", mock.Anything, uid, "aclient").Return(&models.OAuthConsent{
+			UID:      uid,
+			ClientID: "aclient",
+			Scopes:   []string{"read", "write"},
+		}, nil)
+		mockTokenRepository.
+			On("SetAuthorizationCode", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.AuthorizationCode"), time.Minute).
+			Run(func(args mock.Arguments) {
+				codeHash = args.Get(1).(string)
+				code = args.Get(2).(*models.AuthorizationCode)
+			}).
+			Return(nil)
+
+		result, err := s.Authorize(context.TODO(), uid, newRequest())
+
+		assert.NoError(t, err)
+		assert.False(t, result.ConsentRequired)
+
+		redirect, _ := url.Parse(result.RedirectTo)
+		assert.Equal(t, "/callback", redirect.Path)
+		assert.Equal(t, "astate", redirect.Query().Get("state"))
+		assert.Equal(t, security.HashOpaqueToken(redirect.Query().Get("code")), codeHash)
+
+		assert.Equal(t, &models.AuthorizationCode{
+			ClientID:      "aclient",
+			UID:           uid,
+			RedirectURI:   "https://client.example.com/callback",
+			Scope:         "read",
+			CodeChallenge: security.PKCEChallenge(verifier),
+			Nonce:         "anonce",
+		}, code)
+	})
+}
+
+func TestOAuthConsent(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	client := &models.OAuthClient{
+		ClientID:     "aclient",
+		RedirectURIs: []string{"https://client.example.com/callback"},
+		Scopes:       []string{"read", "write"},
+	}
+	req := &models.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "aclient",
+		Scope:               "read",
+		CodeChallenge:       security.PKCEChallenge(strings.Repeat("v", 43)),
+		CodeChallengeMethod: "S256",
+	}
+
+	t.Run("Denied", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+
+		result, err := s.Consent(context.TODO(), uid, req, false)
+
+		assert.NoError(t, err)
+
+		redirect, _ := url.Parse(result.RedirectTo)
+		assert.Equal(t, "access_denied", redirect.Query().Get("error"))
+		mockOAuthRepository.AssertNotCalled(t, "SaveConsent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Approved keeps granted scopes", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockOAuthRepository.On("FindConsent", mock.Anything, uid, "aclient").Return(&models.OAuthConsent{
+			UID:      uid,
+			ClientID: "aclient",
+			Scopes:   []string{"write"},
+		}, nil)
+		mockOAuthRepository.
+			On("SaveConsent", mock.Anything, mock.MatchedBy(func(c *models.OAuthConsent) bool {
+				return c.UID == uid && c.ClientID == "aclient" && strings.Join(c.Scopes, " ") == "write read"
+			})).
+			Return(nil)
+		mockTokenRepository.On("SetAuthorizationCode", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		result, err := s.Consent(context.TODO(), uid, req, true)
+
+		assert.NoError(t, err)
+
+		redirect, _ := url.Parse(result.RedirectTo)
+		assert.NotEmpty(t, redirect.Query().Get("code"))
+		mockOAuthRepository.AssertExpectations(t)
+	})
+}
+
+func TestOAuthToken(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	client := &models.OAuthClient{
+		ClientID:     "aclient",
+		RedirectURIs: []string{"https://client.example.com/callback"},
+		Scopes:       []string{"read", "write"},
+	}
+	verifier := strings.Repeat("v", 43)
+	code := &models.AuthorizationCode{
+		ClientID:        "aclient",
+		UID:             uid,
+		RedirectURI:     "https://client.example.com/callback",
+		RedirectURISent: true,
+		Scope:           "read write",
+		CodeChallenge:   security.PKCEChallenge(verifier),
+	}
+
+	newRequest := func() *models.TokenRequest {
+		return &models.TokenRequest{
+			GrantType:    models.GrantTypeAuthorizationCode,
+			Code:         "acode",
+			RedirectURI:  "https://client.example.com/callback",
+			ClientID:     "aclient",
+			CodeVerifier: verifier,
+		}
+	}
+
+	t.Run("Unsupported grant type", func(t *testing.T) {
+		s, _, _, _ := newTestOAuthService(t)
+
+		resp, err := s.Token(context.TODO(), &models.TokenRequest{GrantType: "password"})
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.UnsupportedGrantType, err.(*apperrors.OAuthError).Code)
+	})
+
+	t.Run("Unknown client", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(nil, apperrors.NewNotFound("client_id", "aclient"))
+
+		resp, err := s.Token(context.TODO(), newRequest())
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidClient, err.(*apperrors.OAuthError).Code)
+	})
+
+	t.Run("Code exchanged for tokens", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, keySet := newTestOAuthService(t)
+
+		var session *models.Session
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("ConsumeAuthorizationCode", mock.Anything, security.HashOpaqueToken("acode")).Return(code, nil)
+		mockTokenRepository.
+			On("SetRefreshToken", mock.Anything, uid.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), time.Minute).
+			Run(func(args mock.Arguments) {
+				session = args.Get(3).(*models.Session)
+			}).
+			Return(nil)
+
+		resp, err := s.Token(context.TODO(), newRequest())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.Equal(t, int64(DefaultAccessTokenExpirationSecs), resp.ExpiresIn)
+		assert.Equal(t, "read write", resp.Scope)
+
+		accessClaims, err := security.ValidateAccessToken(resp.AccessToken, keySet, &security.Verifier{Algorithms: []string{"RS256"}})
+		assert.NoError(t, err)
+		assert.Equal(t, uid.String(), accessClaims.Subject)
+		assert.Equal(t, "aclient", accessClaims.ClientID)
+		assert.Equal(t, "read write", accessClaims.Scope)
+
+		// access tokens are not accepted as id tokens
+		_, err = security.ValidateIDToken(resp.AccessToken, keySet, &security.Verifier{Algorithms: []string{"RS256"}})
+		assert.Error(t, err)
+
+		refreshClaims, err := security.ValidateRefreshToken(resp.RefreshToken, "anotsorandomtestsecret", &security.Verifier{Algorithms: []string{"HS256"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "aclient", refreshClaims.CID)
+		assert.Equal(t, refreshClaims.FID.String(), session.FamilyID)
+		assert.Equal(t, "aclient", session.ClientID)
+		assert.Equal(t, "read write", session.Scope)
+
+		// no id token without the openid scope
+		assert.Empty(t, resp.IDToken)
+	})
+
+	t.Run("Id token issued for openid scope", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, keySet := newTestOAuthService(t)
+
+		oidcCode := *code
+		oidcCode.Scope = "openid read"
+		oidcCode.Nonce = "anonce"
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("ConsumeAuthorizationCode", mock.Anything, security.HashOpaqueToken("acode")).Return(&oidcCode, nil)
+		mockTokenRepository.On("SetRefreshToken", mock.Anything, uid.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), time.Minute).Return(nil)
+
+		resp, err := s.Token(context.TODO(), newRequest())
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.IDToken)
+
+		// parsed without the checks of ValidateIDToken
+		claims := &security.IDTokenCustomClaims{}
+		_, err = jwt.ParseWithClaims(resp.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+			return &keySet.Active().Private.PublicKey, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uid.String(), claims.Subject)
+		assert.Equal(t, "aclient", claims.Audience)
+		assert.Equal(t, "aclient", claims.AuthorizedParty)
+		assert.Equal(t, "anonce", claims.Nonce)
+
+		// id tokens of clients don't give access to the account
+		_, err = security.ValidateIDToken(resp.IDToken, keySet, &security.Verifier{Algorithms: []string{"RS256"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Used code", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("ConsumeAuthorizationCode", mock.Anything, security.HashOpaqueToken("acode")).Return(nil, apperrors.NewAuthorization("Invalid authorization code"))
+
+		resp, err := s.Token(context.TODO(), newRequest())
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidGrant, err.(*apperrors.OAuthError).Code)
+	})
+
+	t.Run("Wrong code verifier", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("ConsumeAuthorizationCode", mock.Anything, security.HashOpaqueToken("acode")).Return(code, nil)
+
+		req := newRequest()
+		req.CodeVerifier = strings.Repeat("w", 43)
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidGrant, err.(*apperrors.OAuthError).Code)
+		mockTokenRepository.AssertNotCalled(t, "SetRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Wrong redirect uri", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("ConsumeAuthorizationCode", mock.Anything, security.HashOpaqueToken("acode")).Return(code, nil)
+
+		req := newRequest()
+		req.RedirectURI = "https://client.example.com/other"
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidGrant, err.(*apperrors.OAuthError).Code)
+	})
+
+	t.Run("Redirect uri left out as at authorization", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		// the code was issued to the only registered redirect uri
+		defaultCode := *code
+		defaultCode.RedirectURISent = false
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("ConsumeAuthorizationCode", mock.Anything, security.HashOpaqueToken("acode")).Return(&defaultCode, nil)
+		mockTokenRepository.On("SetRefreshToken", mock.Anything, uid.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), time.Minute).Return(nil)
+
+		req := newRequest()
+		req.RedirectURI = ""
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+	})
+
+	t.Run("Redirect uri left out after it was sent", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("ConsumeAuthorizationCode", mock.Anything, security.HashOpaqueToken("acode")).Return(code, nil)
+
+		req := newRequest()
+		req.RedirectURI = ""
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidGrant, err.(*apperrors.OAuthError).Code)
+	})
+}
+
+func TestOAuthRefresh(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	fid, _ := uuid.NewRandom()
+	client := &models.OAuthClient{
+		ClientID: "aclient",
+		Scopes:   []string{"read", "write"},
+	}
+	secret := "anotsorandomtestsecret"
+
+	t.Run("Rotates the refresh token", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, fid, "aclient", secret, 60, security.TokenOptions{})
+		createdAt := time.Now().Add(-time.Hour).UTC()
+
+		var session *models.Session
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("RotateRefreshToken", mock.Anything, uid.String(), refreshToken.ID.String(), time.Minute).Return(&models.Session{
+			FamilyID:  fid.String(),
+			ClientID:  "aclient",
+			Scope:     "read write",
+			CreatedAt: createdAt,
+		}, nil)
+		mockTokenRepository.
+			On("SetRefreshToken", mock.Anything, uid.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), mock.Anything).
+			Run(func(args mock.Arguments) {
+				session = args.Get(3).(*models.Session)
+			}).
+			Return(nil)
+
+		resp, err := s.Token(context.TODO(), &models.TokenRequest{
+			GrantType:    models.GrantTypeRefreshToken,
+			ClientID:     "aclient",
+			RefreshToken: refreshToken.SS,
+			Scope:        "read",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "read", resp.Scope)
+
+		// the session keeps its family and granted scopes
+		assert.Equal(t, fid.String(), session.FamilyID)
+		assert.Equal(t, "read write", session.Scope)
+		assert.Equal(t, createdAt, session.CreatedAt)
+	})
+
+	t.Run("First-party refresh token", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		refreshToken, _ := security.GenerateRefreshToken(uid, fid, secret, 60, security.TokenOptions{})
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+
+		resp, err := s.Token(context.TODO(), &models.TokenRequest{
+			GrantType:    models.GrantTypeRefreshToken,
+			ClientID:     "aclient",
+			RefreshToken: refreshToken.SS,
+		})
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidGrant, err.(*apperrors.OAuthError).Code)
+		mockTokenRepository.AssertNotCalled(t, "RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reused refresh token revokes the family", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, _ := newTestOAuthService(t)
+
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, fid, "aclient", secret, 60, security.TokenOptions{})
+
+		mockOAuthRepository.On("FindClient", mock.Anything, "aclient").Return(client, nil)
+		mockTokenRepository.On("RotateRefreshToken", mock.Anything, uid.String(), refreshToken.ID.String(), time.Minute).Return(nil, apperrors.NewAuthorization("Invalid refresh token"))
+		mockTokenRepository.On("GetRotatedRefreshToken", mock.Anything, uid.String(), refreshToken.ID.String()).Return(fid.String(), nil)
+		mockTokenRepository.On("DeleteRefreshTokenFamily", mock.Anything, uid.String(), fid.String()).Return(nil)
+
+		resp, err := s.Token(context.TODO(), &models.TokenRequest{
+			GrantType:    models.GrantTypeRefreshToken,
+			ClientID:     "aclient",
+			RefreshToken: refreshToken.SS,
+		})
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidGrant, err.(*apperrors.OAuthError).Code)
+		mockTokenRepository.AssertExpectations(t)
+	})
+}
+
+func TestServiceClients(t *testing.T) {
+	t.Run("Created with a hashed secret", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		var stored *models.ServiceClient
+
+		mockOAuthRepository.
+			On("CreateServiceClient", mock.Anything, mock.AnythingOfType("*models.ServiceClient")).
+			Run(func(args mock.Arguments) {
+				stored = args.Get(1).(*models.ServiceClient)
+			}).
+			Return(nil)
+
+		client := &models.ServiceClient{Name: "billing"}
+		secret, err := s.CreateServiceClient(context.TODO(), client)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, secret)
+		assert.NotEmpty(t, stored.ClientID)
+		assert.Equal(t, []string{}, []string(stored.Scopes))
+		assert.Equal(t, security.HashOpaqueToken(secret), stored.SecretHash)
+		assert.NotContains(t, stored.SecretHash, secret)
+		mockOAuthRepository.AssertExpectations(t)
+	})
+
+	t.Run("Error creating", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("CreateServiceClient", mock.Anything, mock.Anything).Return(apperrors.NewConflict("client_id", "billing"))
+
+		secret, err := s.CreateServiceClient(context.TODO(), &models.ServiceClient{ClientID: "billing", Name: "billing"})
+
+		assert.Empty(t, secret)
+		assert.Equal(t, http.StatusConflict, apperrors.Status(err))
+	})
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	secret := "aservicesecret"
+	client := &models.ServiceClient{
+		ClientID:   "billing",
+		SecretHash: security.HashOpaqueToken(secret),
+		Scopes:     []string{"users:read", "users:write"},
+	}
+
+	newRequest := func() *models.TokenRequest {
+		return &models.TokenRequest{
+			GrantType:    models.GrantTypeClientCredentials,
+			ClientID:     "billing",
+			ClientSecret: secret,
+		}
+	}
+
+	t.Run("Issues an access token", func(t *testing.T) {
+		s, mockOAuthRepository, mockTokenRepository, keySet := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindServiceClient", mock.Anything, "billing").Return(client, nil)
+
+		resp, err := s.Token(context.TODO(), newRequest())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.Equal(t, int64(DefaultAccessTokenExpirationSecs), resp.ExpiresIn)
+		assert.Equal(t, "users:read users:write", resp.Scope)
+		assert.Empty(t, resp.RefreshToken)
+
+		claims, err := security.ValidateAccessToken(resp.AccessToken, keySet, &security.Verifier{Algorithms: []string{"RS256"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "billing", claims.Subject)
+		assert.Equal(t, "billing", claims.ClientID)
+		assert.Equal(t, "users:read users:write", claims.Scope)
+
+		// nothing is stored for access tokens
+		mockTokenRepository.AssertNotCalled(t, "SetRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Narrowed scope", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindServiceClient", mock.Anything, "billing").Return(client, nil)
+
+		req := newRequest()
+		req.Scope = "users:read"
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "users:read", resp.Scope)
+	})
+
+	t.Run("Scope of another client", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindServiceClient", mock.Anything, "billing").Return(client, nil)
+
+		req := newRequest()
+		req.Scope = "users:read admin"
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidScope, err.(*apperrors.OAuthError).Code)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindServiceClient", mock.Anything, "billing").Return(client, nil)
+
+		req := newRequest()
+		req.ClientSecret = "wrong"
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidClient, err.(*apperrors.OAuthError).Code)
+	})
+
+	t.Run("Unknown client", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		mockOAuthRepository.On("FindServiceClient", mock.Anything, "billing").Return(nil, apperrors.NewNotFound("client_id", "billing"))
+
+		resp, err := s.Token(context.TODO(), newRequest())
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidClient, err.(*apperrors.OAuthError).Code)
+	})
+
+	t.Run("Missing secret", func(t *testing.T) {
+		s, mockOAuthRepository, _, _ := newTestOAuthService(t)
+
+		req := newRequest()
+		req.ClientSecret = ""
+
+		resp, err := s.Token(context.TODO(), req)
+
+		assert.Nil(t, resp)
+		assert.Equal(t, apperrors.InvalidClient, err.(*apperrors.OAuthError).Code)
+		mockOAuthRepository.AssertNotCalled(t, "FindServiceClient", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+)
+
+// ThrottlePolicy decides how long sign in attempts of an identifier are
+// blocked after failed attempts within Window. After FreeAttempts, each
+// failure doubles the delay, starting at BaseDelay up to MaxDelay
+// Reaching LockoutAttempts locks the identifier out for LockoutDuration
+type ThrottlePolicy struct {
+	Window          time.Duration
+	FreeAttempts    int64
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAttempts int64
+	LockoutDuration time.Duration
+}
+
+// DefaultEmailThrottlePolicy limits attempts against a single account
+var DefaultEmailThrottlePolicy = ThrottlePolicy{
+	Window:          15 * time.Minute,
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAttempts: 10,
+	LockoutDuration: 15 * time.Minute,
+}
+
+// DefaultIPThrottlePolicy limits attempts from a single client, which
+// allows more attempts as many users may share an address
+var DefaultIPThrottlePolicy = ThrottlePolicy{
+	Window:          15 * time.Minute,
+	FreeAttempts:    20,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAttempts: 100,
+	LockoutDuration: 15 * time.Minute,
+}
+
+// delay returns how long attempts are blocked after
+// the given number of failed attempts
+func (p ThrottlePolicy) delay(failures int64) time.Duration {
+	if p.LockoutAttempts > 0 && failures >= p.LockoutAttempts {
+		return p.LockoutDuration
+	}
+
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+// throttleService counts failed sign in attempts by email and by
+// client ip and blocks further attempts when there are too many
+type throttleService struct {
+	ThrottleRepository models.ThrottleRepository
+	EmailPolicy        ThrottlePolicy
+	IPPolicy           ThrottlePolicy
+}
+
+// ThrottleConfig will hold repositories that will eventually be injected into
+// this service layer. Policies default to DefaultEmailThrottlePolicy
+// and DefaultIPThrottlePolicy
+type ThrottleConfig struct {
+	ThrottleRepository models.ThrottleRepository
+	EmailPolicy        *ThrottlePolicy
+	IPPolicy           *ThrottlePolicy
+}
+
+// NewThrottleService is a factory function for
+// initializing a ThrottleService with its repository layer dependencies
+func NewThrottleService(c *ThrottleConfig) models.ThrottleService {
+	emailPolicy := DefaultEmailThrottlePolicy
+	if c.EmailPolicy != nil {
+		emailPolicy = *c.EmailPolicy
+	}
+
+	ipPolicy := DefaultIPThrottlePolicy
+	if c.IPPolicy != nil {
+		ipPolicy = *c.IPPolicy
+	}
+
+	return &throttleService{
+		ThrottleRepository: c.ThrottleRepository,
+		EmailPolicy:        emailPolicy,
+		IPPolicy:           ipPolicy,
+	}
+}
+
+// Check returns a TooManyRequests error if sign in
+// attempts for email or from ip are blocked
+func (s *throttleService) Check(ctx context.Context, email string, ip string) error {
+	var retryAfter time.Duration
+
+	for _, t := range s.targets(email, ip) {
+		d, err := s.ThrottleRepository.GetLockout(ctx, t.kind, t.identifier)
+
+		if err != nil {
+			return err
+		}
+
+		if d > retryAfter {
+			retryAfter = d
+		}
+	}
+
+	if retryAfter > 0 {
+		return apperrors.NewTooManyRequests(retryAfter)
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed sign in attempt and blocks further
+// attempts according to the policies
+func (s *throttleService) RecordFailure(ctx context.Context, email string, ip string) error {
+	for _, t := range s.targets(email, ip) {
+		failures, err := s.ThrottleRepository.AddFailedAttempt(ctx, t.kind, t.identifier, t.policy.Window)
+
+		if err != nil {
+			return err
+		}
+
+		delay := t.policy.delay(failures)
+
+		if delay == 0 {
+			continue
+		}
+
+		if err := s.ThrottleRepository.SetLockout(ctx, t.kind, t.identifier, delay); err != nil {
+			return err
+		}
+
+		if t.policy.LockoutAttempts == 0 || failures < t.policy.LockoutAttempts {
+			continue
+		}
+
+		log.Printf("Locked out %s: %s after %d failed sign in attempts\n", t.kind, t.identifier, failures)
+
+		now := time.Now()
+
+		err = s.ThrottleRepository.AddLockoutEvent(ctx, &models.LockoutEvent{
+			Kind:       t.kind,
+			Identifier: t.identifier,
+			Attempts:   failures,
+			LockedAt:   now,
+			Until:      now.Add(delay),
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess resets the failed attempts for email
+// The counter of the ip is deliberately not reset, failed attempts from
+// an ip are kept until their window expires. Otherwise an attacker could
+// sign in to their own account now and then to keep guessing the
+// passwords of others from the same ip
+func (s *throttleService) RecordSuccess(ctx context.Context, email string) error {
+	t := s.emailTarget(email)
+
+	return s.ThrottleRepository.ResetFailedAttempts(ctx, t.kind, t.identifier)
+}
+
+// maxLockoutEvents is the number of lockout events returned
+const maxLockoutEvents = 100
+
+// LockoutEvents returns the most recent lockout events, newest first
+func (s *throttleService) LockoutEvents(ctx context.Context) ([]*models.LockoutEvent, error) {
+	return s.ThrottleRepository.ListLockoutEvents(ctx, maxLockoutEvents)
+}
+
+// throttleTarget is an identifier attempts are counted by
+type throttleTarget struct {
+	kind       string
+	identifier string
+	policy     ThrottlePolicy
+}
+
+// targets returns the identifiers attempts are counted by
+func (s *throttleService) targets(email string, ip string) []throttleTarget {
+	targets := []throttleTarget{s.emailTarget(email)}
+
+	if ip != "" {
+		targets = append(targets, throttleTarget{
+			kind:       models.ThrottleKindIP,
+			identifier: ip,
+			policy:     s.IPPolicy,
+		})
+	}
+
+	return targets
+}
+
+// emailTarget returns the identifier attempts for email are counted by
+// Emails are compared case insensitively
+func (s *throttleService) emailTarget(email string) throttleTarget {
+	return throttleTarget{
+		kind:       models.ThrottleKindEmail,
+		identifier: strings.ToLower(strings.TrimSpace(email)),
+		policy:     s.EmailPolicy,
+	}
+}
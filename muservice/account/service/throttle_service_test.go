@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestThrottlePolicyDelay(t *testing.T) {
+	p := ThrottlePolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutAttempts: 10,
+		LockoutDuration: 15 * time.Minute,
+	}
+
+	cases := map[int64]time.Duration{
+		1:  0,
+		3:  0,
+		4:  time.Second,
+		5:  2 * time.Second,
+		6:  4 * time.Second,
+		7:  8 * time.Second,
+		8:  10 * time.Second,
+		9:  10 * time.Second,
+		10: 15 * time.Minute,
+		11: 15 * time.Minute,
+	}
+
+	for failures, expected := range cases {
+		assert.Equal(t, expected, p.delay(failures), "failures: %d", failures)
+	}
+}
+
+func TestThrottleCheck(t *testing.T) {
+	email := "bob@bob.com"
+	ip := "10.0.0.1"
+
+	t.Run("Allowed", func(t *testing.T) {
+		mockThrottleRepository := new(mocks.MockThrottleRepository)
+		ts := NewThrottleService(&ThrottleConfig{
+			ThrottleRepository: mockThrottleRepository,
+		})
+
+		mockThrottleRepository.On("GetLockout", mock.Anything, models.ThrottleKindEmail, email).Return(time.Duration(0), nil)
+		mockThrottleRepository.On("GetLockout", mock.Anything, models.ThrottleKindIP, ip).Return(time.Duration(0), nil)
+
+		err := ts.Check(context.TODO(), email, ip)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Blocked by the longest lockout", func(t *testing.T) {
+		mockThrottleRepository := new(mocks.MockThrottleRepository)
+		ts := NewThrottleService(&ThrottleConfig{
+			ThrottleRepository: mockThrottleRepository,
+		})
+
+		// emails are compared case insensitively
+		mockThrottleRepository.On("GetLockout", mock.Anything, models.ThrottleKindEmail, email).Return(2*time.Second, nil)
+		mockThrottleRepository.On("GetLockout", mock.Anything, models.ThrottleKindIP, ip).Return(time.Minute, nil)
+
+		err := ts.Check(context.TODO(), "Bob@Bob.com", ip)
+
+		assert.Equal(t, apperrors.TooManyRequests, err.(*apperrors.Error).Type)
+		assert.Equal(t, time.Minute, err.(*apperrors.Error).RetryAfter)
+	})
+}
+
+func TestThrottleRecordFailure(t *testing.T) {
+	email := "bob@bob.com"
+	ip := "10.0.0.1"
+
+	t.Run("Free attempt", func(t *testing.T) {
+		mockThrottleRepository := new(mocks.MockThrottleRepository)
+		ts := NewThrottleService(&ThrottleConfig{
+			ThrottleRepository: mockThrottleRepository,
+		})
+
+		mockThrottleRepository.On("AddFailedAttempt", mock.Anything, models.ThrottleKindEmail, email, DefaultEmailThrottlePolicy.Window).Return(int64(1), nil)
+		mockThrottleRepository.On("AddFailedAttempt", mock.Anything, models.ThrottleKindIP, ip, DefaultIPThrottlePolicy.Window).Return(int64(1), nil)
+
+		err := ts.RecordFailure(context.TODO(), email, ip)
+
+		assert.NoError(t, err)
+		mockThrottleRepository.AssertNotCalled(t, "SetLockout", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		mockThrottleRepository := new(mocks.MockThrottleRepository)
+		ts := NewThrottleService(&ThrottleConfig{
+			ThrottleRepository: mockThrottleRepository,
+		})
+
+		mockThrottleRepository.On("AddFailedAttempt", mock.Anything, models.ThrottleKindEmail, email, mock.Anything).Return(int64(5), nil)
+		mockThrottleRepository.On("AddFailedAttempt", mock.Anything, models.ThrottleKindIP, ip, mock.Anything).Return(int64(5), nil)
+		mockThrottleRepository.On("SetLockout", mock.Anything, models.ThrottleKindEmail, email, 2*time.Second).Return(nil)
+
+		err := ts.RecordFailure(context.TODO(), email, ip)
+
+		assert.NoError(t, err)
+		mockThrottleRepository.AssertExpectations(t)
+		mockThrottleRepository.AssertNotCalled(t, "AddLockoutEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Lockout", func(t *testing.T) {
+		mockThrottleRepository := new(mocks.MockThrottleRepository)
+		ts := NewThrottleService(&ThrottleConfig{
+			ThrottleRepository: mockThrottleRepository,
+		})
+
+		mockThrottleRepository.On("AddFailedAttempt", mock.Anything, models.ThrottleKindEmail, email, mock.Anything).Return(int64(10), nil)
+		mockThrottleRepository.On("AddFailedAttempt", mock.Anything, models.ThrottleKindIP, ip, mock.Anything).Return(int64(10), nil)
+		mockThrottleRepository.On("SetLockout", mock.Anything, models.ThrottleKindEmail, email, 15*time.Minute).Return(nil)
+		mockThrottleRepository.
+			On("AddLockoutEvent", mock.Anything, mock.MatchedBy(func(e *models.LockoutEvent) bool {
+				return e.Kind == models.ThrottleKindEmail && e.Identifier == email && e.Attempts == 10
+			})).
+			Return(nil)
+
+		err := ts.RecordFailure(context.TODO(), email, ip)
+
+		assert.NoError(t, err)
+		mockThrottleRepository.AssertExpectations(t)
+	})
+}
+
+func TestThrottleRecordSuccess(t *testing.T) {
+	mockThrottleRepository := new(mocks.MockThrottleRepository)
+	ts := NewThrottleService(&ThrottleConfig{
+		ThrottleRepository: mockThrottleRepository,
+	})
+
+	mockThrottleRepository.On("ResetFailedAttempts", mock.Anything, models.ThrottleKindEmail, "bob@bob.com").Return(nil)
+
+	err := ts.RecordSuccess(context.TODO(), "Bob@bob.com ")
+
+	assert.NoError(t, err)
+	mockThrottleRepository.AssertExpectations(t)
+
+	// failed attempts from the ip survive a successful sign in
+	mockThrottleRepository.AssertNotCalled(t, "ResetFailedAttempts", mock.Anything, models.ThrottleKindIP, mock.Anything)
+}
@@ -2,81 +2,223 @@ package service
 
 import (
 	"context"
-	"crypto/rsa"
+	"fmt"
 	"log"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
 	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
 	"github.com/NetworkPy/muserv/muservice/account/security"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
 )
 
+// SessionLimitPolicy decides what happens when a user with
+// the maximum number of sessions signs in again
+type SessionLimitPolicy string
+
+// SessionLimitEvict signs out the session refreshed least recently
+const SessionLimitEvict SessionLimitPolicy = "evict"
+
+// SessionLimitReject rejects the new sign in
+const SessionLimitReject SessionLimitPolicy = "reject"
+
 // tokenService used for injecting an implementation of TokenRepository
 // for use in service methods along with keys and secrets for
 // signing JWTs
 type tokenService struct {
 	TokenRepository       models.TokenRepository
-	PrivKey               *rsa.PrivateKey
-	PubKey                *rsa.PublicKey
+	APIKeyRepository      models.APIKeyRepository
+	UserRepository        models.UserRepository
+	KeySet                *security.KeySet
 	RefreshSecret         string
 	IDExpirationSecs      int64
 	RefreshExpirationSecs int64
+	TokenOptions          security.TokenOptions
+	IDTokenClaims         security.IDTokenClaims
+	IDTokenVerifier       *security.Verifier
+	RefreshTokenVerifier  *security.Verifier
+	MaxSessions           int64
+	SessionLimitPolicy    SessionLimitPolicy
 }
 
 // TSConfig will hold repositories that will eventually be injected into this
 // this service layer
+// Issuer and Audience are written into every token, IDTokenClaims
+// selects how the user is represented in id tokens. Verifiers default to
+// accepting tokens with the same issuer and audience signed with RS256
+// (id tokens) or HS256 (refresh tokens)
+// MaxSessions limits the sessions of a user if greater than 0, the
+// SessionLimitPolicy defaults to SessionLimitEvict
+// API keys are stored with the APIKeyRepository and are resolved to
+// users of the UserRepository
 type TSConfig struct {
 	TokenRepository       models.TokenRepository
-	PrivKey               *rsa.PrivateKey
-	PubKey                *rsa.PublicKey
+	APIKeyRepository      models.APIKeyRepository
+	UserRepository        models.UserRepository
+	KeySet                *security.KeySet
 	RefreshSecret         string
 	IDExpirationSecs      int64
 	RefreshExpirationSecs int64
+	Issuer                string
+	Audience              string
+	IDTokenClaims         security.IDTokenClaims
+	IDTokenVerifier       *security.Verifier
+	RefreshTokenVerifier  *security.Verifier
+	MaxSessions           int64
+	SessionLimitPolicy    SessionLimitPolicy
 }
 
 // NewTokenService is a factory function for
 // initializing a UserService with its repository layer dependencies
 func NewTokenService(c *TSConfig) models.TokenService {
+	idTokenVerifier := c.IDTokenVerifier
+
+	if idTokenVerifier == nil {
+		idTokenVerifier = defaultVerifier(c, "RS256")
+	}
+
+	refreshTokenVerifier := c.RefreshTokenVerifier
+
+	if refreshTokenVerifier == nil {
+		refreshTokenVerifier = defaultVerifier(c, "HS256")
+	}
+
+	sessionLimitPolicy := c.SessionLimitPolicy
+
+	if sessionLimitPolicy == "" {
+		sessionLimitPolicy = SessionLimitEvict
+	}
+
 	return &tokenService{
 		TokenRepository:       c.TokenRepository,
-		PrivKey:               c.PrivKey,
-		PubKey:                c.PubKey,
+		APIKeyRepository:      c.APIKeyRepository,
+		UserRepository:        c.UserRepository,
+		KeySet:                c.KeySet,
 		RefreshSecret:         c.RefreshSecret,
 		IDExpirationSecs:      c.IDExpirationSecs,
 		RefreshExpirationSecs: c.RefreshExpirationSecs,
+		TokenOptions: security.TokenOptions{
+			Issuer:   c.Issuer,
+			Audience: c.Audience,
+		},
+		IDTokenClaims:        c.IDTokenClaims,
+		IDTokenVerifier:      idTokenVerifier,
+		RefreshTokenVerifier: refreshTokenVerifier,
+		MaxSessions:          c.MaxSessions,
+		SessionLimitPolicy:   sessionLimitPolicy,
+	}
+}
+
+// defaultVerifier accepts tokens signed with alg
+// which this service has issued for itself
+func defaultVerifier(c *TSConfig, alg string) *security.Verifier {
+	v := &security.Verifier{
+		Algorithms: []string{alg},
+		Issuer:     c.Issuer,
+	}
+
+	if c.Audience != "" {
+		v.Audiences = []string{c.Audience}
 	}
+
+	return v
 }
 
 // NewPairFromUser creates fresh id and refresh tokens for the current user
-// If a previous token is included, the previous token is removed from
-// the tokens repository
+// If a previous token is included, the previous token is rotated out of
+// the tokens repository and the pair is only created if it was still there.
+// The new refresh token joins the family and session of the previous token,
+// otherwise a new token family is created, within the limit of MaxSessions.
+// The session records the client found in ctx
 func (s *tokenService) NewPairFromUser(ctx context.Context, u *models.User, prevTokenID string) (*models.TokenPair, error) {
+	var fid uuid.UUID
+
+	now := time.Now()
+	client := models.ClientInfoFromContext(ctx)
+	session := &models.Session{
+		UserAgent:       client.UserAgent,
+		IP:              client.IP,
+		CreatedAt:       now,
+		LastRefreshedAt: now,
+	}
+
+	// rotate user's current refresh token (used when refreshing idToken)
+	// this is done first so that a refresh token which has already been
+	// used, has expired or is unknown can not produce a new pair
+	if prevTokenID != "" {
+		prevSession, err := s.TokenRepository.RotateRefreshToken(ctx, u.UID.String(), prevTokenID, time.Duration(s.RefreshExpirationSecs)*time.Second)
+
+		if err != nil {
+			log.Printf("Could not rotate previous refreshToken for uid: %v, tokenID: %v\n", u.UID.String(), prevTokenID)
+
+			if apperrors.Status(err) == http.StatusUnauthorized {
+				revokeReusedFamily(ctx, s.TokenRepository, u.UID.String(), prevTokenID)
+			}
+
+			return nil, err
+		}
+
+		// tokens issued before sessions were recorded have no creation time
+		if !prevSession.CreatedAt.IsZero() {
+			session.CreatedAt = prevSession.CreatedAt
+		}
+
+		// tokens issued before token families existed have no family
+		// and start a new one below
+		if prevSession.FamilyID != "" {
+			fid, err = uuid.Parse(prevSession.FamilyID)
+
+			if err != nil {
+				log.Printf("Token family of uid: %v could not be parsed as UUID %s\n%v", u.UID, prevSession.FamilyID, err)
+				return nil, apperrors.NewInternal()
+			}
+		}
+	}
+
+	if fid == uuid.Nil {
+		newFID, err := uuid.NewRandom()
+
+		if err != nil {
+			log.Printf("Error generating token family for uid: %v. Error: %v\n", u.UID, err.Error())
+			return nil, apperrors.NewInternal()
+		}
+
+		fid = newFID
+	}
+
 	// No need to use a repository for idToken as it is unrelated to any data source
-	idToken, err := security.GenerateIDToken(u, s.PrivKey, s.IDExpirationSecs)
+	idToken, err := security.GenerateIDToken(u, s.KeySet.Active(), s.IDExpirationSecs, s.IDTokenClaims, s.TokenOptions)
 
 	if err != nil {
 		log.Printf("Error generating idToken for uid: %v. Error: %v\n", u.UID, err.Error())
 		return nil, apperrors.NewInternal()
 	}
 
-	refreshToken, err := security.GenerateRefreshToken(u.UID, s.RefreshSecret, s.RefreshExpirationSecs)
+	refreshToken, err := security.GenerateRefreshToken(u.UID, fid, s.RefreshSecret, s.RefreshExpirationSecs, s.TokenOptions)
 
 	if err != nil {
 		log.Printf("Error generating refreshToken for uid: %v. Error: %v\n", u.UID, err.Error())
 		return nil, apperrors.NewInternal()
 	}
 
+	session.FamilyID = fid.String()
+
 	// set freshly minted refresh token to valid list
-	if err := s.TokenRepository.SetRefreshToken(ctx, u.UID.String(), refreshToken.ID.String(), refreshToken.ExpiresIn); err != nil {
-		log.Printf("Error storing tokenID for uid: %v. Error: %v\n", u.UID, err.Error())
-		return nil, apperrors.NewInternal()
-	}
+	// a new session must not exceed the maximum, rotating a
+	// token keeps the number of sessions
+	if prevTokenID == "" && s.MaxSessions > 0 {
+		evict := s.SessionLimitPolicy != SessionLimitReject
 
-	// delete user's current refresh token (used when refreshing idToken)
-	if prevTokenID != "" {
-		if err := s.TokenRepository.DeleteRefreshToken(ctx, u.UID.String(), prevTokenID); err != nil {
-			log.Printf("Could not delete previous refreshToken for uid: %v, tokenID: %v\n", u.UID.String(), prevTokenID)
+		if err := s.TokenRepository.SetRefreshTokenWithinLimit(ctx, u.UID.String(), refreshToken.ID.String(), session, refreshToken.ExpiresIn, s.MaxSessions, evict); err != nil {
+			log.Printf("Could not store tokenID within the session limit for uid: %v. Error: %v\n", u.UID, err)
+			return nil, err
 		}
+	} else if err := s.TokenRepository.SetRefreshToken(ctx, u.UID.String(), refreshToken.ID.String(), session, refreshToken.ExpiresIn); err != nil {
+		log.Printf("Error storing tokenID for uid: %v. Error: %v\n", u.UID, err.Error())
+		return nil, apperrors.NewInternal()
 	}
 
 	return &models.TokenPair{
@@ -84,17 +226,295 @@ func (s *tokenService) NewPairFromUser(ctx context.Context, u *models.User, prev
 			SS: idToken,
 		},
 		RefreshToken: models.RefreshToken{
-			ID:  refreshToken.ID,
-			UID: u.UID,
-			SS:  refreshToken.SS,
+			ID:       refreshToken.ID,
+			UID:      u.UID,
+			FamilyID: fid,
+			SS:       refreshToken.SS,
 		},
 	}, nil
 }
 
+// revokeReusedFamily is called when a refresh token could not be rotated.
+// If the token has been rotated before, it is being used a second time,
+// which means it has leaked. Every token of its family is then revoked,
+// so neither the legitimate client nor an attacker can continue the session
+func revokeReusedFamily(ctx context.Context, tokenRepository models.TokenRepository, userID string, tokenID string) {
+	familyID, err := tokenRepository.GetRotatedRefreshToken(ctx, userID, tokenID)
+
+	if err != nil || familyID == "" {
+		return
+	}
+
+	log.Printf("Reuse of rotated refresh token detected for uid: %v, tokenID: %v. Revoking token family: %v\n", userID, tokenID, familyID)
+
+	if err := tokenRepository.DeleteRefreshTokenFamily(ctx, userID, familyID); err != nil {
+		log.Printf("Could not revoke token family: %v for uid: %v. Error: %v\n", familyID, userID, err)
+	}
+}
+
+// Signout removes a single refresh token of the user
+// from the valid list, signing out the session it belongs to
+func (s *tokenService) Signout(ctx context.Context, uid uuid.UUID, tokenID string) error {
+	return s.TokenRepository.DeleteRefreshToken(ctx, uid.String(), tokenID)
+}
+
+// SignoutAll removes every refresh token of the user
+// from the valid list, signing out all of the user's sessions
+func (s *tokenService) SignoutAll(ctx context.Context, uid uuid.UUID) error {
+	return s.TokenRepository.DeleteUserRefreshTokens(ctx, uid.String())
+}
+
+// ListSessions returns the sessions the user is signed in with
+// Sessions are identified by their token family, which stays the
+// same when their refresh token is rotated
+func (s *tokenService) ListSessions(ctx context.Context, uid uuid.UUID) ([]*models.Session, error) {
+	sessions, err := s.TokenRepository.ListRefreshTokens(ctx, uid.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens issued without a family keep the id of the token
+	for _, session := range sessions {
+		if session.FamilyID != "" {
+			session.ID = session.FamilyID
+		}
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs out a single session of the user, which is
+// identified by the id of its token family, so it can be revoked even
+// if its refresh token was rotated since the sessions were listed
+func (s *tokenService) RevokeSession(ctx context.Context, uid uuid.UUID, sessionID string) error {
+	sessions, err := s.TokenRepository.ListRefreshTokens(ctx, uid.String())
+
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.FamilyID != "" && session.FamilyID == sessionID {
+			return s.TokenRepository.DeleteRefreshTokenFamily(ctx, uid.String(), sessionID)
+		}
+
+		if session.FamilyID == "" && session.ID == sessionID {
+			err := s.TokenRepository.DeleteRefreshToken(ctx, uid.String(), sessionID)
+
+			if apperrors.Status(err) == http.StatusUnauthorized {
+				return apperrors.NewNotFound("session", sessionID)
+			}
+
+			return err
+		}
+	}
+
+	return apperrors.NewNotFound("session", sessionID)
+}
+
+// CreateAPIKey creates an API key for the user k.UID with the
+// scopes of k and returns the key, which can't be recovered later
+func (s *tokenService) CreateAPIKey(ctx context.Context, k *models.APIKey) (string, error) {
+	if len(k.Scopes) == 0 {
+		return "", apperrors.NewBadRequest("At least one scope is required")
+	}
+
+	for _, scope := range k.Scopes {
+		if !contains(models.APIKeyScopes, scope) {
+			return "", apperrors.NewBadRequest(fmt.Sprintf("Unknown scope: %v", scope))
+		}
+	}
+
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		return "", apperrors.NewBadRequest("Expiry must be in the future")
+	}
+
+	token, err := security.GenerateOpaqueToken()
+
+	if err != nil {
+		log.Printf("Unable to generate api key for uid: %v. Error: %v\n", k.UID, err)
+		return "", apperrors.NewInternal()
+	}
+
+	key := models.APIKeyPrefix + token
+	k.KeyHash = security.HashOpaqueToken(key)
+
+	if err := s.APIKeyRepository.Create(ctx, k); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns the API keys of a user
+func (s *tokenService) ListAPIKeys(ctx context.Context, uid uuid.UUID) ([]*models.APIKey, error) {
+	return s.APIKeyRepository.FindByUID(ctx, uid)
+}
+
+// DeleteAPIKey revokes an API key of a user
+func (s *tokenService) DeleteAPIKey(ctx context.Context, uid uuid.UUID, id uuid.UUID) error {
+	return s.APIKeyRepository.Delete(ctx, uid, id)
+}
+
+// ValidateAPIKey returns the user an API key belongs to along with the
+// key, which holds the granted scopes. Expired keys are rejected
+func (s *tokenService) ValidateAPIKey(ctx context.Context, key string) (*models.User, *models.APIKey, error) {
+	invalid := apperrors.NewAuthorization("Unable to verify user from api key")
+
+	if !strings.HasPrefix(key, models.APIKeyPrefix) {
+		return nil, nil, invalid
+	}
+
+	k, err := s.APIKeyRepository.FindByHash(ctx, security.HashOpaqueToken(key))
+
+	if apperrors.Status(err) == http.StatusNotFound {
+		log.Println("Unknown api key used")
+		return nil, nil, invalid
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		log.Printf("Expired api key: %v of uid: %v used\n", k.ID, k.UID)
+		return nil, nil, invalid
+	}
+
+	u, err := s.UserRepository.FindByID(ctx, k.UID)
+
+	if apperrors.Status(err) == http.StatusNotFound {
+		return nil, nil, invalid
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the key was valid regardless of whether its use is recorded
+	if err := s.APIKeyRepository.UpdateLastUsed(ctx, k.ID); err != nil {
+		log.Printf("Unable to record use of api key: %v\n%v", k.ID, err)
+	}
+
+	return u, k, nil
+}
+
+// IntrospectToken reports whether a token is active along with its
+// claims (RFC 7662). Id and access tokens are active until they expire,
+// refresh tokens only while they haven't been rotated or revoked.
+// Invalid tokens are reported as inactive rather than as errors
+func (s *tokenService) IntrospectToken(ctx context.Context, tokenString string) (*models.TokenIntrospection, error) {
+	if claims, err := security.ValidateIDToken(tokenString, s.KeySet, s.IDTokenVerifier); err == nil {
+		i := introspection(models.TokenTypeIDToken, &claims.StandardClaims)
+		i.Username = claims.Email
+
+		if claims.User != nil {
+			i.Username = claims.User.Email
+		}
+
+		return i, nil
+	}
+
+	if claims, err := security.ValidateAccessToken(tokenString, s.KeySet, s.IDTokenVerifier); err == nil {
+		i := introspection(models.TokenTypeAccessToken, &claims.StandardClaims)
+		i.ClientID = claims.ClientID
+		i.Scope = claims.Scope
+
+		return i, nil
+	}
+
+	// refresh tokens of OAuth clients are introspected too
+	claims, err := security.ValidateRefreshToken(tokenString, s.RefreshSecret, s.RefreshTokenVerifier)
+
+	if err != nil {
+		log.Printf("Introspected token is invalid: %v\n", err)
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+
+	session, err := s.TokenRepository.GetRefreshToken(ctx, claims.UID.String(), claims.Id)
+
+	if apperrors.Status(err) == http.StatusUnauthorized {
+		return &models.TokenIntrospection{Active: false}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	i := introspection(models.TokenTypeRefreshToken, &claims.StandardClaims)
+	i.Subject = claims.UID.String()
+	i.ClientID = claims.CID
+	i.Scope = session.Scope
+
+	return i, nil
+}
+
+// RevokeToken revokes a refresh token of the OAuth client with clientID
+// along with the other tokens of its family, which signs out the session
+// (RFC 7009). Tokens issued to other clients or first-party sessions are
+// refused unless anyClient is set, for service clients trusted to revoke
+// any refresh token. Id and access tokens can't be revoked before they
+// expire. Invalid tokens and tokens which were already revoked are ignored
+func (s *tokenService) RevokeToken(ctx context.Context, clientID string, tokenString string, anyClient bool) error {
+	claims, err := security.ValidateRefreshToken(tokenString, s.RefreshSecret, s.RefreshTokenVerifier)
+
+	if err != nil {
+		_, idErr := security.ValidateIDToken(tokenString, s.KeySet, s.IDTokenVerifier)
+		_, accessErr := security.ValidateAccessToken(tokenString, s.KeySet, s.IDTokenVerifier)
+
+		if idErr == nil || accessErr == nil {
+			return apperrors.NewOAuthError(apperrors.UnsupportedTokenType, "Only refresh tokens can be revoked")
+		}
+
+		log.Printf("Token to revoke is invalid: %v\n", err)
+		return nil
+	}
+
+	if !anyClient && (clientID == "" || claims.CID != clientID) {
+		log.Printf("Client: %s tried to revoke a refresh token of client: %q\n", clientID, claims.CID)
+		return apperrors.NewOAuthError(apperrors.UnauthorizedClient, "The token was not issued to the client")
+	}
+
+	if claims.FID == uuid.Nil {
+		err = s.TokenRepository.DeleteRefreshToken(ctx, claims.UID.String(), claims.Id)
+	} else {
+		err = s.TokenRepository.DeleteRefreshTokenFamily(ctx, claims.UID.String(), claims.FID.String())
+	}
+
+	if apperrors.Status(err) == http.StatusUnauthorized {
+		return nil
+	}
+
+	return err
+}
+
+// introspection creates the response of introspecting
+// an active token with the standard claims c
+func introspection(tokenType string, c *jwt.StandardClaims) *models.TokenIntrospection {
+	return &models.TokenIntrospection{
+		Active:    true,
+		TokenType: tokenType,
+		Subject:   c.Subject,
+		ExpiresAt: c.ExpiresAt,
+		IssuedAt:  c.IssuedAt,
+		NotBefore: c.NotBefore,
+		Issuer:    c.Issuer,
+		Audience:  c.Audience,
+		ID:        c.Id,
+	}
+}
+
+// JWKS returns the public keys id tokens can be verified with
+func (s *tokenService) JWKS() *models.JWKS {
+	return s.KeySet.JWKS()
+}
+
 // ValidateIDToken validates the id token jwt string
 // It returns the user extract from the IDTokenCustomClaims
+// which works for tokens issued in any claims mode
 func (s *tokenService) ValidateIDToken(tokenString string) (*models.User, error) {
-	claims, err := security.ValidateIDToken(tokenString, s.PubKey) // uses public RSA key
+	claims, err := security.ValidateIDToken(tokenString, s.KeySet, s.IDTokenVerifier) // uses public RSA keys
 
 	// We'll just return unauthorized error in all instances of failing to verify user
 	if err != nil {
@@ -102,19 +522,87 @@ func (s *tokenService) ValidateIDToken(tokenString string) (*models.User, error)
 		return nil, apperrors.NewAuthorization("Unable to verify user from idToken")
 	}
 
-	return claims.User, nil
+	u, err := claims.ToUser()
+
+	if err != nil {
+		log.Printf("Unable to extract user from idToken - Error: %v\n", err)
+		return nil, apperrors.NewAuthorization("Unable to verify user from idToken")
+	}
+
+	return u, nil
+}
+
+// ValidateAccessToken validates an access token issued to an OAuth
+// client. Access tokens are signed with the keys of id tokens and
+// are accepted for the same issuer and audiences
+func (s *tokenService) ValidateAccessToken(tokenString string) (*models.AccessToken, error) {
+	claims, err := security.ValidateAccessToken(tokenString, s.KeySet, s.IDTokenVerifier)
+
+	if err != nil {
+		log.Printf("Unable to validate or parse access token - Error: %v\n", err)
+		return nil, apperrors.NewAuthorization("Unable to verify user from access token")
+	}
+
+	// tokens of service clients don't act for a user
+	if claims.Subject == claims.ClientID {
+		log.Printf("Access token of service client: %v used as user access token\n", claims.ClientID)
+		return nil, apperrors.NewAuthorization("Unable to verify user from access token")
+	}
+
+	uid, err := uuid.Parse(claims.Subject)
+
+	if err != nil {
+		log.Printf("Access token subject is not a valid uid: %v\n", claims.Subject)
+		return nil, apperrors.NewAuthorization("Unable to verify user from access token")
+	}
+
+	return &models.AccessToken{
+		UID:      uid,
+		ClientID: claims.ClientID,
+		Scopes:   strings.Fields(claims.Scope),
+	}, nil
+}
+
+// ValidateServiceToken validates an access token issued to a service
+// client with the client_credentials grant. The UID of the returned
+// token is uuid.Nil
+func (s *tokenService) ValidateServiceToken(tokenString string) (*models.AccessToken, error) {
+	claims, err := security.ValidateAccessToken(tokenString, s.KeySet, s.IDTokenVerifier)
+
+	if err != nil {
+		log.Printf("Unable to validate or parse service token - Error: %v\n", err)
+		return nil, apperrors.NewAuthorization("Unable to verify client from access token")
+	}
+
+	// service clients are the subject of their own tokens, other
+	// access tokens let a client act for a user
+	if claims.ClientID == "" || claims.Subject != claims.ClientID {
+		log.Printf("Access token of client: %v for subject: %v used as service token\n", claims.ClientID, claims.Subject)
+		return nil, apperrors.NewAuthorization("Unable to verify client from access token")
+	}
+
+	return &models.AccessToken{
+		ClientID: claims.ClientID,
+		Scopes:   strings.Fields(claims.Scope),
+	}, nil
 }
 
 // ValidateRefreshToken validates the id token jwt string
 // It returns the refreshToken
 func (s *tokenService) ValidateRefreshToken(tokenString string) (*models.RefreshToken, error) {
-	claims, err := security.ValidateRefreshToken(tokenString, s.RefreshSecret)
+	claims, err := security.ValidateRefreshToken(tokenString, s.RefreshSecret, s.RefreshTokenVerifier)
 
 	if err != nil {
 		log.Printf("Unable to validate or parse refreshToken for token string %s\n%v", tokenString, err)
 		return nil, apperrors.NewAuthorization("Unable to verify user from refresh token")
 	}
 
+	// refresh tokens of OAuth clients are only accepted by the token endpoint
+	if claims.CID != "" {
+		log.Printf("Refresh token of OAuth client: %s used as first-party refresh token\n", claims.CID)
+		return nil, apperrors.NewAuthorization("Unable to verify user from refresh token")
+	}
+
 	tokenUUID, err := uuid.Parse(claims.Id)
 
 	if err != nil {
@@ -123,8 +611,9 @@ func (s *tokenService) ValidateRefreshToken(tokenString string) (*models.Refresh
 	}
 
 	return &models.RefreshToken{
-		SS:  tokenString,
-		ID:  tokenUUID,
-		UID: claims.UID,
+		SS:       tokenString,
+		ID:       tokenUUID,
+		UID:      claims.UID,
+		FamilyID: claims.FID,
 	}, nil
 }
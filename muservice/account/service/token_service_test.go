@@ -2,12 +2,19 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
 	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
 	"github.com/NetworkPy/muserv/muservice/account/security"
 	"github.com/stretchr/testify/assert"
@@ -26,6 +33,7 @@ func TestNewPairFromUser(t *testing.T) {
 	pub, _ := ioutil.ReadFile("../rsa_public_test.pem")
 	pubKey, _ := jwt.ParseRSAPublicKeyFromPEM(pub)
 	secret := "anotsorandomtestsecret"
+	keySet, _ := security.NewKeySet(security.NewSigningKey(privKey))
 
 	// instantiate a common token service to be used by all tests
 	mockTokenRepository := new(mocks.MockTokenRepository)
@@ -33,8 +41,7 @@ func TestNewPairFromUser(t *testing.T) {
 	// instantiate a common token service to be used by all tests
 	tokenService := NewTokenService(&TSConfig{
 		TokenRepository:       mockTokenRepository,
-		PrivKey:               privKey,
-		PubKey:                pubKey,
+		KeySet:                keySet,
 		RefreshSecret:         secret,
 		IDExpirationSecs:      idExp,
 		RefreshExpirationSecs: refreshExp,
@@ -57,31 +64,35 @@ func TestNewPairFromUser(t *testing.T) {
 		Passowrd: "blarghedymcblarghface",
 	}
 	prevID := "a_previous_tokenID"
+	prevFamilyID := uuid.New().String()
 
 	setSuccessArguments := mock.Arguments{
-		mock.AnythingOfType("*context.emptyCtx"),
+		mock.Anything,
 		u.UID.String(),
 		mock.AnythingOfType("string"),
+		mock.AnythingOfType("*models.Session"),
 		mock.AnythingOfType("time.Duration"),
 	}
 
 	setErrorArguments := mock.Arguments{
-		mock.AnythingOfType("*context.emptyCtx"),
+		mock.Anything,
 		uErrorCase.UID.String(),
 		mock.AnythingOfType("string"),
+		mock.AnythingOfType("*models.Session"),
 		mock.AnythingOfType("time.Duration"),
 	}
 
-	deleteWithPrevIDArguments := mock.Arguments{
-		mock.AnythingOfType("*context.emptyCtx"),
+	rotateWithPrevIDArguments := mock.Arguments{
+		mock.Anything,
 		u.UID.String(),
 		prevID,
+		time.Duration(refreshExp) * time.Second,
 	}
 
 	// mock call argument/responses
 	mockTokenRepository.On("SetRefreshToken", setSuccessArguments...).Return(nil)
 	mockTokenRepository.On("SetRefreshToken", setErrorArguments...).Return(fmt.Errorf("Error setting refresh token"))
-	mockTokenRepository.On("DeleteRefreshToken", deleteWithPrevIDArguments...).Return(nil)
+	mockTokenRepository.On("RotateRefreshToken", rotateWithPrevIDArguments...).Return(&models.Session{FamilyID: prevFamilyID}, nil)
 
 	t.Run("Returns a token pair with values", func(t *testing.T) {
 		ctx := context.Background()                                    // updated from context.TODO()
@@ -90,8 +101,8 @@ func TestNewPairFromUser(t *testing.T) {
 
 		// SetRefreshToken should be called with setSuccessArguments
 		mockTokenRepository.AssertCalled(t, "SetRefreshToken", setSuccessArguments...)
-		// DeleteRefreshToken should not be called since prevID is ""
-		mockTokenRepository.AssertCalled(t, "DeleteRefreshToken", deleteWithPrevIDArguments...)
+		// RotateRefreshToken should be called since prevID is not ""
+		mockTokenRepository.AssertCalled(t, "RotateRefreshToken", rotateWithPrevIDArguments...)
 
 		var s string
 		assert.IsType(t, s, tokenPair.IDToken.SS)
@@ -100,11 +111,13 @@ func TestNewPairFromUser(t *testing.T) {
 		// simpler to use jwt library which is already imported
 		idTokenClaims := &security.IDTokenCustomClaims{}
 
-		_, err = jwt.ParseWithClaims(tokenPair.IDToken.SS, idTokenClaims, func(token *jwt.Token) (interface{}, error) {
+		idToken, err := jwt.ParseWithClaims(tokenPair.IDToken.SS, idTokenClaims, func(token *jwt.Token) (interface{}, error) {
 			return pubKey, nil
 		})
 
 		assert.NoError(t, err)
+		// idToken should carry the kid of the signing key
+		assert.Equal(t, keySet.Active().ID, idToken.Header["kid"])
 
 		// assert claims on idToken
 		expectedClaims := []interface{}{
@@ -141,6 +154,9 @@ func TestNewPairFromUser(t *testing.T) {
 		// assert claims on refresh token
 		assert.NoError(t, err)
 		assert.Equal(t, u.UID, refreshTokenClaims.UID)
+		// new refresh token should join the family of the previous token
+		assert.Equal(t, prevFamilyID, refreshTokenClaims.FID.String())
+		assert.Equal(t, prevFamilyID, tokenPair.RefreshToken.FamilyID.String())
 
 		// for refreshToken
 		expiresAt = time.Unix(refreshTokenClaims.StandardClaims.ExpiresAt, 0)
@@ -155,8 +171,61 @@ func TestNewPairFromUser(t *testing.T) {
 
 		// SetRefreshToken should be called with setErrorArguments
 		mockTokenRepository.AssertCalled(t, "SetRefreshToken", setErrorArguments...)
-		// DeleteRefreshToken should not be since SetRefreshToken causes method to return
-		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshToken")
+		// RotateRefreshToken should not be called since prevID is ""
+		mockTokenRepository.AssertNotCalled(t, "RotateRefreshToken")
+	})
+
+	t.Run("Previous token no longer valid", func(t *testing.T) {
+		// use a separate repository mock so calls from other cases don't interfere
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository:       mockTokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         secret,
+			IDExpirationSecs:      idExp,
+			RefreshExpirationSecs: refreshExp,
+		})
+
+		mockTokenRepository.On("RotateRefreshToken", mock.Anything, u.UID.String(), "an_invalid_tokenID", mock.AnythingOfType("time.Duration")).Return(nil, apperrors.NewAuthorization("Invalid refresh token"))
+		mockTokenRepository.On("GetRotatedRefreshToken", mock.Anything, u.UID.String(), "an_invalid_tokenID").Return("", nil)
+
+		ctx := context.Background()
+		tokenPair, err := tokenService.NewPairFromUser(ctx, u, "an_invalid_tokenID")
+
+		assert.Nil(t, tokenPair)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+
+		// no new refresh token should be stored for a rejected prevID
+		// and no family revoked for a token which was never rotated
+		mockTokenRepository.AssertExpectations(t)
+		mockTokenRepository.AssertNotCalled(t, "SetRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshTokenFamily", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reused token revokes family", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository:       mockTokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         secret,
+			IDExpirationSecs:      idExp,
+			RefreshExpirationSecs: refreshExp,
+		})
+
+		reusedFamilyID := uuid.New().String()
+
+		mockTokenRepository.On("RotateRefreshToken", mock.Anything, u.UID.String(), "a_rotated_tokenID", mock.AnythingOfType("time.Duration")).Return(nil, apperrors.NewAuthorization("Invalid refresh token"))
+		mockTokenRepository.On("GetRotatedRefreshToken", mock.Anything, u.UID.String(), "a_rotated_tokenID").Return(reusedFamilyID, nil)
+		mockTokenRepository.On("DeleteRefreshTokenFamily", mock.Anything, u.UID.String(), reusedFamilyID).Return(nil)
+
+		ctx := context.Background()
+		tokenPair, err := tokenService.NewPairFromUser(ctx, u, "a_rotated_tokenID")
+
+		assert.Nil(t, tokenPair)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+
+		mockTokenRepository.AssertExpectations(t)
+		mockTokenRepository.AssertNotCalled(t, "SetRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	})
 
 	t.Run("Empty string provided for prevID", func(t *testing.T) {
@@ -166,7 +235,1048 @@ func TestNewPairFromUser(t *testing.T) {
 
 		// SetRefreshToken should be called with setSuccessArguments
 		mockTokenRepository.AssertCalled(t, "SetRefreshToken", setSuccessArguments...)
-		// DeleteRefreshToken should not be called since prevID is ""
-		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshToken")
+		// RotateRefreshToken should not be called since prevID is ""
+		mockTokenRepository.AssertNotCalled(t, "RotateRefreshToken")
+	})
+}
+
+func TestSignout(t *testing.T) {
+	mockTokenRepository := new(mocks.MockTokenRepository)
+	tokenService := NewTokenService(&TSConfig{
+		TokenRepository: mockTokenRepository,
+	})
+
+	t.Run("Single session", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+		tokenID := uuid.New().String()
+
+		mockTokenRepository.On("DeleteRefreshToken", mock.Anything, uid.String(), tokenID).Return(nil)
+
+		ctx := context.Background()
+		err := tokenService.Signout(ctx, uid, tokenID)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertCalled(t, "DeleteRefreshToken", mock.Anything, uid.String(), tokenID)
+	})
+
+	t.Run("All sessions", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockTokenRepository.On("DeleteUserRefreshTokens", mock.Anything, uid.String()).Return(nil)
+
+		ctx := context.Background()
+		err := tokenService.SignoutAll(ctx, uid)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertCalled(t, "DeleteUserRefreshTokens", mock.Anything, uid.String())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockError := apperrors.NewInternal()
+		mockTokenRepository.On("DeleteUserRefreshTokens", mock.Anything, uid.String()).Return(mockError)
+
+		ctx := context.Background()
+		err := tokenService.SignoutAll(ctx, uid)
+
+		assert.Equal(t, mockError, err)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	priv, _ := ioutil.ReadFile("../rsa_private_test.pem")
+	privKey, _ := jwt.ParseRSAPrivateKeyFromPEM(priv)
+	keySet, _ := security.NewKeySet(security.NewSigningKey(privKey))
+
+	u := &models.User{
+		UID:   uuid.New(),
+		Email: "bob@bob.com",
+	}
+
+	t.Run("New session records the client", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository:       mockTokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         "anotsorandomtestsecret",
+			IDExpirationSecs:      60,
+			RefreshExpirationSecs: 60,
+		})
+
+		var session *models.Session
+
+		mockTokenRepository.
+			On("SetRefreshToken", mock.Anything, u.UID.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), mock.Anything).
+			Run(func(args mock.Arguments) {
+				session = args.Get(3).(*models.Session)
+			}).
+			Return(nil)
+
+		ctx := models.WithClientInfo(context.Background(), models.ClientInfo{
+			UserAgent: "Mozilla/5.0",
+			IP:        "10.0.0.1",
+		})
+
+		tokenPair, err := tokenService.NewPairFromUser(ctx, u, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Mozilla/5.0", session.UserAgent)
+		assert.Equal(t, "10.0.0.1", session.IP)
+		assert.Equal(t, tokenPair.RefreshToken.FamilyID.String(), session.FamilyID)
+		assert.False(t, session.CreatedAt.IsZero())
+		assert.Equal(t, session.CreatedAt, session.LastRefreshedAt)
+	})
+
+	t.Run("Refresh keeps the creation time", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository:       mockTokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         "anotsorandomtestsecret",
+			IDExpirationSecs:      60,
+			RefreshExpirationSecs: 60,
+		})
+
+		createdAt := time.Now().Add(-time.Hour).UTC()
+		prevSession := &models.Session{
+			ID:              "a_previous_tokenID",
+			FamilyID:        uuid.New().String(),
+			UserAgent:       "Mozilla/4.0",
+			IP:              "10.0.0.2",
+			CreatedAt:       createdAt,
+			LastRefreshedAt: createdAt,
+		}
+
+		var session *models.Session
+
+		mockTokenRepository.On("RotateRefreshToken", mock.Anything, u.UID.String(), "a_previous_tokenID", time.Minute).Return(prevSession, nil)
+		mockTokenRepository.
+			On("SetRefreshToken", mock.Anything, u.UID.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), mock.Anything).
+			Run(func(args mock.Arguments) {
+				session = args.Get(3).(*models.Session)
+			}).
+			Return(nil)
+
+		ctx := models.WithClientInfo(context.Background(), models.ClientInfo{
+			UserAgent: "Mozilla/5.0",
+			IP:        "10.0.0.1",
+		})
+
+		_, err := tokenService.NewPairFromUser(ctx, u, "a_previous_tokenID")
+
+		assert.NoError(t, err)
+		assert.Equal(t, prevSession.FamilyID, session.FamilyID)
+		assert.Equal(t, createdAt, session.CreatedAt)
+		assert.True(t, session.LastRefreshedAt.After(createdAt))
+		assert.Equal(t, "Mozilla/5.0", session.UserAgent)
+		assert.Equal(t, "10.0.0.1", session.IP)
+	})
+
+	t.Run("Evicts the oldest session over the limit", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository:       mockTokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         "anotsorandomtestsecret",
+			IDExpirationSecs:      60,
+			RefreshExpirationSecs: 60,
+			MaxSessions:           3,
+		})
+
+		mockTokenRepository.
+			On("SetRefreshTokenWithinLimit", mock.Anything, u.UID.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), time.Minute, int64(3), true).
+			Return(nil)
+
+		_, err := tokenService.NewPairFromUser(context.Background(), u, "")
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertExpectations(t)
+		mockTokenRepository.AssertNotCalled(t, "SetRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Rejects sign in over the limit", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository:       mockTokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         "anotsorandomtestsecret",
+			IDExpirationSecs:      60,
+			RefreshExpirationSecs: 60,
+			MaxSessions:           3,
+			SessionLimitPolicy:    SessionLimitReject,
+		})
+
+		mockError := apperrors.NewForbidden("Maximum number of sessions reached, sign out of another session first")
+		mockTokenRepository.
+			On("SetRefreshTokenWithinLimit", mock.Anything, u.UID.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), time.Minute, int64(3), false).
+			Return(mockError)
+
+		tokenPair, err := tokenService.NewPairFromUser(context.Background(), u, "")
+
+		assert.Nil(t, tokenPair)
+		assert.Equal(t, mockError, err)
+		mockTokenRepository.AssertNotCalled(t, "SetRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Refresh is not limited", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository:       mockTokenRepository,
+			KeySet:                keySet,
+			RefreshSecret:         "anotsorandomtestsecret",
+			IDExpirationSecs:      60,
+			RefreshExpirationSecs: 60,
+			MaxSessions:           1,
+			SessionLimitPolicy:    SessionLimitReject,
+		})
+
+		mockTokenRepository.On("RotateRefreshToken", mock.Anything, u.UID.String(), "a_previous_tokenID", time.Minute).Return(&models.Session{FamilyID: uuid.New().String()}, nil)
+		mockTokenRepository.On("SetRefreshToken", mock.Anything, u.UID.String(), mock.AnythingOfType("string"), mock.AnythingOfType("*models.Session"), mock.Anything).Return(nil)
+
+		_, err := tokenService.NewPairFromUser(context.Background(), u, "a_previous_tokenID")
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertNotCalled(t, "SetRefreshTokenWithinLimit", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository: mockTokenRepository,
+		})
+
+		familyID := uuid.New().String()
+		legacyTokenID := uuid.New().String()
+
+		mockSessions := []*models.Session{
+			{ID: uuid.New().String(), FamilyID: familyID, UserAgent: "Mozilla/5.0"},
+			{ID: legacyTokenID},
+		}
+		mockTokenRepository.On("ListRefreshTokens", mock.Anything, u.UID.String()).Return(mockSessions, nil)
+
+		sessions, err := tokenService.ListSessions(context.Background(), u.UID)
+
+		assert.NoError(t, err)
+		assert.Len(t, sessions, 2)
+		assert.Equal(t, familyID, sessions[0].ID)
+		assert.Equal(t, legacyTokenID, sessions[1].ID)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository: mockTokenRepository,
+		})
+
+		// the token was rotated after the sessions were listed
+		familyID := uuid.New().String()
+		mockSessions := []*models.Session{{ID: uuid.New().String(), FamilyID: familyID}}
+
+		mockTokenRepository.On("ListRefreshTokens", mock.Anything, u.UID.String()).Return(mockSessions, nil)
+		mockTokenRepository.On("DeleteRefreshTokenFamily", mock.Anything, u.UID.String(), familyID).Return(nil)
+
+		err := tokenService.RevokeSession(context.Background(), u.UID, familyID)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertExpectations(t)
+	})
+
+	t.Run("Revoke session without family", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository: mockTokenRepository,
+		})
+
+		sessionID := uuid.New().String()
+		mockSessions := []*models.Session{{ID: sessionID}}
+
+		mockTokenRepository.On("ListRefreshTokens", mock.Anything, u.UID.String()).Return(mockSessions, nil)
+		mockTokenRepository.On("DeleteRefreshToken", mock.Anything, u.UID.String(), sessionID).Return(nil)
+
+		err := tokenService.RevokeSession(context.Background(), u.UID, sessionID)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertExpectations(t)
+	})
+
+	t.Run("Revoke unknown session", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository: mockTokenRepository,
+		})
+
+		// a token id is not accepted for a session with a family
+		tokenID := uuid.New().String()
+		mockSessions := []*models.Session{{ID: tokenID, FamilyID: uuid.New().String()}}
+
+		mockTokenRepository.On("ListRefreshTokens", mock.Anything, u.UID.String()).Return(mockSessions, nil)
+
+		err := tokenService.RevokeSession(context.Background(), u.UID, tokenID)
+
+		assert.Equal(t, apperrors.NotFound, err.(*apperrors.Error).Type)
+		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshTokenFamily", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestValidateIDToken(t *testing.T) {
+	var idExp int64 = 15 * 60
+
+	priv, _ := ioutil.ReadFile("../rsa_private_test.pem")
+	privKey, _ := jwt.ParseRSAPrivateKeyFromPEM(priv)
+	pub, _ := ioutil.ReadFile("../rsa_public_test.pem")
+
+	// a key which has since been rotated out, but is still used for verification
+	rotatedPrivKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	// a key which is not part of the key set
+	unknownPrivKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	keySet, _ := security.NewKeySet(
+		security.NewSigningKey(privKey),
+		security.NewVerifyingKey(&rotatedPrivKey.PublicKey),
+	)
+
+	opts := security.TokenOptions{
+		Issuer:   "account",
+		Audience: "account",
+	}
+
+	tokenService := NewTokenService(&TSConfig{
+		KeySet:           keySet,
+		IDExpirationSecs: idExp,
+		Issuer:           opts.Issuer,
+		Audience:         opts.Audience,
+	})
+
+	uid, _ := uuid.NewRandom()
+	u := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+	}
+
+	t.Run("Signed with active key", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(u, keySet.Active(), idExp, security.IDTokenClaims{}, opts)
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.NoError(t, err)
+		assert.Equal(t, u.UID, user.UID)
+	})
+
+	t.Run("Signed with rotated key", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(u, security.NewSigningKey(rotatedPrivKey), idExp, security.IDTokenClaims{}, opts)
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.NoError(t, err)
+		assert.Equal(t, u.UID, user.UID)
+	})
+
+	t.Run("Signed with unknown key", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(u, security.NewSigningKey(unknownPrivKey), idExp, security.IDTokenClaims{}, opts)
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.Nil(t, user)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Unknown key with known kid", func(t *testing.T) {
+		forged := security.NewSigningKey(unknownPrivKey)
+		forged.ID = keySet.Active().ID
+
+		ss, _ := security.GenerateIDToken(u, forged, idExp, security.IDTokenClaims{}, opts)
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.Nil(t, user)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Signed with HS256 using the public key", func(t *testing.T) {
+		claims := security.IDTokenCustomClaims{
+			User: u,
+			StandardClaims: jwt.StandardClaims{
+				ExpiresAt: time.Now().Add(time.Minute).Unix(),
+				Issuer:    opts.Issuer,
+				Audience:  opts.Audience,
+			},
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = keySet.Active().ID
+		ss, _ := token.SignedString(pub)
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.Nil(t, user)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Minted for another audience", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(u, keySet.Active(), idExp, security.IDTokenClaims{}, security.TokenOptions{
+			Issuer:   opts.Issuer,
+			Audience: "another-service",
+		})
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.Nil(t, user)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Minted by another issuer", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(u, keySet.Active(), idExp, security.IDTokenClaims{}, security.TokenOptions{
+			Issuer:   "another-service",
+			Audience: opts.Audience,
+		})
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.Nil(t, user)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("OIDC claims", func(t *testing.T) {
+		u := &models.User{
+			UID:      uid,
+			Email:    "bob@bob.com",
+			Name:     "Bobby Bobson",
+			ImageURL: "https://bob.com/bob.png",
+			Website:  "https://bob.com",
+		}
+
+		ss, err := security.GenerateIDToken(u, keySet.Active(), idExp, security.IDTokenClaims{
+			Mode:   security.ClaimsModeOIDC,
+			Claims: []string{security.ClaimEmail, security.ClaimName, security.ClaimPicture},
+		}, opts)
+		assert.NoError(t, err)
+
+		// decode the payload to check which claims were written
+		parts := strings.Split(ss, ".")
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		assert.NoError(t, err)
+
+		var claims map[string]interface{}
+		assert.NoError(t, json.Unmarshal(payload, &claims))
+
+		assert.Equal(t, uid.String(), claims["sub"])
+		assert.Equal(t, u.Email, claims["email"])
+		assert.Equal(t, false, claims["email_verified"])
+		assert.Equal(t, u.Name, claims["name"])
+		assert.Equal(t, u.ImageURL, claims["picture"])
+		assert.NotContains(t, claims, "user")
+		assert.NotContains(t, claims, "website")
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.User{
+			UID:      uid,
+			Email:    u.Email,
+			Name:     u.Name,
+			ImageURL: u.ImageURL,
+		}, user)
+	})
+
+	t.Run("Expired within leeway", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(u, keySet.Active(), -5, security.IDTokenClaims{}, opts)
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		// rejected without leeway
+		assert.Nil(t, user)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+
+		lenientTokenService := NewTokenService(&TSConfig{
+			KeySet:           keySet,
+			IDExpirationSecs: idExp,
+			IDTokenVerifier: &security.Verifier{
+				Algorithms: []string{"RS256"},
+				Issuer:     opts.Issuer,
+				Audiences:  []string{opts.Audience},
+				Leeway:     30 * time.Second,
+			},
+		})
+
+		user, err = lenientTokenService.ValidateIDToken(ss)
+
+		assert.NoError(t, err)
+		assert.Equal(t, u.UID, user.UID)
+	})
+}
+
+func TestValidateAccessToken(t *testing.T) {
+	var exp int64 = 15 * 60
+
+	priv, _ := ioutil.ReadFile("../rsa_private_test.pem")
+	privKey, _ := jwt.ParseRSAPrivateKeyFromPEM(priv)
+
+	keySet, _ := security.NewKeySet(security.NewSigningKey(privKey))
+
+	opts := security.TokenOptions{
+		Issuer:   "account",
+		Audience: "account",
+	}
+
+	tokenService := NewTokenService(&TSConfig{
+		KeySet:   keySet,
+		Issuer:   opts.Issuer,
+		Audience: opts.Audience,
+	})
+
+	uid, _ := uuid.NewRandom()
+
+	t.Run("Valid token", func(t *testing.T) {
+		ss, _ := security.GenerateAccessToken(uid.String(), "aclient", "openid email", keySet.Active(), exp, opts)
+
+		accessToken, err := tokenService.ValidateAccessToken(ss)
+
+		assert.NoError(t, err)
+		assert.Equal(t, uid, accessToken.UID)
+		assert.Equal(t, "aclient", accessToken.ClientID)
+		assert.Equal(t, []string{"openid", "email"}, accessToken.Scopes)
+	})
+
+	t.Run("ID token is not an access token", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(&models.User{UID: uid}, keySet.Active(), exp, security.IDTokenClaims{}, opts)
+
+		accessToken, err := tokenService.ValidateAccessToken(ss)
+
+		assert.Nil(t, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Access token is not an ID token", func(t *testing.T) {
+		ss, _ := security.GenerateAccessToken(uid.String(), "aclient", "openid", keySet.Active(), exp, opts)
+
+		user, err := tokenService.ValidateIDToken(ss)
+
+		assert.Nil(t, user)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Token of a service client", func(t *testing.T) {
+		ss, _ := security.GenerateAccessToken("aclient", "aclient", "openid", keySet.Active(), exp, opts)
+
+		accessToken, err := tokenService.ValidateAccessToken(ss)
+
+		assert.Nil(t, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Minted for another audience", func(t *testing.T) {
+		ss, _ := security.GenerateAccessToken(uid.String(), "aclient", "openid", keySet.Active(), exp, security.TokenOptions{
+			Issuer:   opts.Issuer,
+			Audience: "another-service",
+		})
+
+		accessToken, err := tokenService.ValidateAccessToken(ss)
+
+		assert.Nil(t, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+}
+
+func TestValidateServiceToken(t *testing.T) {
+	var exp int64 = 15 * 60
+
+	priv, _ := ioutil.ReadFile("../rsa_private_test.pem")
+	privKey, _ := jwt.ParseRSAPrivateKeyFromPEM(priv)
+
+	keySet, _ := security.NewKeySet(security.NewSigningKey(privKey))
+
+	opts := security.TokenOptions{
+		Issuer:   "account",
+		Audience: "account",
+	}
+
+	tokenService := NewTokenService(&TSConfig{
+		KeySet:   keySet,
+		Issuer:   opts.Issuer,
+		Audience: opts.Audience,
+	})
+
+	t.Run("Valid token", func(t *testing.T) {
+		ss, _ := security.GenerateAccessToken("billing", "billing", "users:read", keySet.Active(), exp, opts)
+
+		accessToken, err := tokenService.ValidateServiceToken(ss)
+
+		assert.NoError(t, err)
+		assert.Equal(t, uuid.Nil, accessToken.UID)
+		assert.Equal(t, "billing", accessToken.ClientID)
+		assert.Equal(t, []string{"users:read"}, accessToken.Scopes)
+	})
+
+	t.Run("Token acting for a user", func(t *testing.T) {
+		ss, _ := security.GenerateAccessToken(uuid.New().String(), "billing", "users:read", keySet.Active(), exp, opts)
+
+		accessToken, err := tokenService.ValidateServiceToken(ss)
+
+		assert.Nil(t, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("ID token", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(&models.User{UID: uuid.New()}, keySet.Active(), exp, security.IDTokenClaims{}, opts)
+
+		accessToken, err := tokenService.ValidateServiceToken(ss)
+
+		assert.Nil(t, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+}
+
+func TestValidateRefreshToken(t *testing.T) {
+	var refreshExp int64 = 3 * 24 * 2600
+	secret := "anotsorandomtestsecret"
+
+	opts := security.TokenOptions{
+		Issuer:   "account",
+		Audience: "account",
+	}
+
+	tokenService := NewTokenService(&TSConfig{
+		RefreshSecret:         secret,
+		RefreshExpirationSecs: refreshExp,
+		Issuer:                opts.Issuer,
+		Audience:              opts.Audience,
+	})
+
+	uid, _ := uuid.NewRandom()
+	fid, _ := uuid.NewRandom()
+
+	t.Run("Valid token", func(t *testing.T) {
+		refreshToken, _ := security.GenerateRefreshToken(uid, fid, secret, refreshExp, opts)
+
+		rt, err := tokenService.ValidateRefreshToken(refreshToken.SS)
+
+		assert.NoError(t, err)
+		assert.Equal(t, refreshToken.ID, rt.ID)
+		assert.Equal(t, uid, rt.UID)
+		assert.Equal(t, fid, rt.FamilyID)
+	})
+
+	t.Run("Minted for another audience", func(t *testing.T) {
+		refreshToken, _ := security.GenerateRefreshToken(uid, fid, secret, refreshExp, security.TokenOptions{
+			Issuer:   opts.Issuer,
+			Audience: "another-service",
+		})
+
+		rt, err := tokenService.ValidateRefreshToken(refreshToken.SS)
+
+		assert.Nil(t, rt)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Issued to an OAuth client", func(t *testing.T) {
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, fid, "aclient", secret, refreshExp, opts)
+
+		rt, err := tokenService.ValidateRefreshToken(refreshToken.SS)
+
+		assert.Nil(t, rt)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Unsigned token", func(t *testing.T) {
+		claims := security.RefreshTokenCustomClaims{
+			UID: uid,
+			FID: fid,
+			StandardClaims: jwt.StandardClaims{
+				ExpiresAt: time.Now().Add(time.Minute).Unix(),
+				Id:        uuid.New().String(),
+				Issuer:    opts.Issuer,
+				Audience:  opts.Audience,
+			},
+		}
+
+		ss, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+		rt, err := tokenService.ValidateRefreshToken(ss)
+
+		assert.Nil(t, rt)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	uid, _ := uuid.NewRandom()
+	u := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+	}
+
+	newService := func() (models.TokenService, *mocks.MockAPIKeyRepository, *mocks.MockUserRepository) {
+		mockAPIKeyRepository := new(mocks.MockAPIKeyRepository)
+		mockUserRepository := new(mocks.MockUserRepository)
+
+		tokenService := NewTokenService(&TSConfig{
+			APIKeyRepository: mockAPIKeyRepository,
+			UserRepository:   mockUserRepository,
+		})
+
+		return tokenService, mockAPIKeyRepository, mockUserRepository
+	}
+
+	t.Run("Created with a hashed key", func(t *testing.T) {
+		tokenService, mockAPIKeyRepository, _ := newService()
+
+		var stored *models.APIKey
+
+		mockAPIKeyRepository.
+			On("Create", mock.Anything, mock.AnythingOfType("*models.APIKey")).
+			Run(func(args mock.Arguments) {
+				stored = args.Get(1).(*models.APIKey)
+			}).
+			Return(nil)
+
+		key, err := tokenService.CreateAPIKey(context.TODO(), &models.APIKey{
+			UID:    uid,
+			Name:   "ci",
+			Scopes: []string{models.ScopeAccountRead},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(key, models.APIKeyPrefix))
+		assert.Equal(t, security.HashOpaqueToken(key), stored.KeyHash)
+		assert.Equal(t, uid, stored.UID)
+		mockAPIKeyRepository.AssertExpectations(t)
+	})
+
+	t.Run("Unknown scope", func(t *testing.T) {
+		tokenService, mockAPIKeyRepository, _ := newService()
+
+		key, err := tokenService.CreateAPIKey(context.TODO(), &models.APIKey{
+			UID:    uid,
+			Name:   "ci",
+			Scopes: []string{models.ScopeAccountRead, "admin"},
+		})
+
+		assert.Empty(t, key)
+		assert.Equal(t, http.StatusBadRequest, apperrors.Status(err))
+		mockAPIKeyRepository.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Expiry in the past", func(t *testing.T) {
+		tokenService, mockAPIKeyRepository, _ := newService()
+
+		expiresAt := time.Now().Add(-time.Minute)
+
+		key, err := tokenService.CreateAPIKey(context.TODO(), &models.APIKey{
+			UID:       uid,
+			Name:      "ci",
+			Scopes:    []string{models.ScopeAccountRead},
+			ExpiresAt: &expiresAt,
+		})
+
+		assert.Empty(t, key)
+		assert.Equal(t, http.StatusBadRequest, apperrors.Status(err))
+		mockAPIKeyRepository.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Valid key", func(t *testing.T) {
+		tokenService, mockAPIKeyRepository, mockUserRepository := newService()
+
+		key := models.APIKeyPrefix + "akey"
+		expiresAt := time.Now().Add(time.Hour)
+		apiKey := &models.APIKey{
+			ID:        uuid.New(),
+			UID:       uid,
+			Scopes:    []string{models.ScopeAccountRead},
+			ExpiresAt: &expiresAt,
+		}
+
+		mockAPIKeyRepository.On("FindByHash", mock.Anything, security.HashOpaqueToken(key)).Return(apiKey, nil)
+		mockAPIKeyRepository.On("UpdateLastUsed", mock.Anything, apiKey.ID).Return(nil)
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(u, nil)
+
+		user, k, err := tokenService.ValidateAPIKey(context.TODO(), key)
+
+		assert.NoError(t, err)
+		assert.Equal(t, u, user)
+		assert.Equal(t, apiKey, k)
+		mockAPIKeyRepository.AssertExpectations(t)
+		mockUserRepository.AssertExpectations(t)
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		tokenService, mockAPIKeyRepository, _ := newService()
+
+		key := models.APIKeyPrefix + "unknown"
+
+		mockAPIKeyRepository.On("FindByHash", mock.Anything, security.HashOpaqueToken(key)).Return(nil, apperrors.NewNotFound("api_key", ""))
+
+		user, k, err := tokenService.ValidateAPIKey(context.TODO(), key)
+
+		assert.Nil(t, user)
+		assert.Nil(t, k)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+	})
+
+	t.Run("Expired key", func(t *testing.T) {
+		tokenService, mockAPIKeyRepository, mockUserRepository := newService()
+
+		key := models.APIKeyPrefix + "expired"
+		expiresAt := time.Now().Add(-time.Minute)
+
+		mockAPIKeyRepository.On("FindByHash", mock.Anything, security.HashOpaqueToken(key)).Return(&models.APIKey{
+			ID:        uuid.New(),
+			UID:       uid,
+			ExpiresAt: &expiresAt,
+		}, nil)
+
+		user, k, err := tokenService.ValidateAPIKey(context.TODO(), key)
+
+		assert.Nil(t, user)
+		assert.Nil(t, k)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+		mockUserRepository.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not an api key", func(t *testing.T) {
+		tokenService, mockAPIKeyRepository, _ := newService()
+
+		user, k, err := tokenService.ValidateAPIKey(context.TODO(), "an.id.token")
+
+		assert.Nil(t, user)
+		assert.Nil(t, k)
+		assert.Equal(t, http.StatusUnauthorized, apperrors.Status(err))
+		mockAPIKeyRepository.AssertNotCalled(t, "FindByHash", mock.Anything, mock.Anything)
+	})
+}
+
+func TestIntrospectToken(t *testing.T) {
+	var exp int64 = 15 * 60
+	secret := "anotsorandomtestsecret"
+
+	priv, _ := ioutil.ReadFile("../rsa_private_test.pem")
+	privKey, _ := jwt.ParseRSAPrivateKeyFromPEM(priv)
+
+	keySet, _ := security.NewKeySet(security.NewSigningKey(privKey))
+
+	opts := security.TokenOptions{
+		Issuer:   "account",
+		Audience: "account",
+	}
+
+	mockTokenRepository := new(mocks.MockTokenRepository)
+
+	tokenService := NewTokenService(&TSConfig{
+		TokenRepository: mockTokenRepository,
+		KeySet:          keySet,
+		RefreshSecret:   secret,
+		Issuer:          opts.Issuer,
+		Audience:        opts.Audience,
+	})
+
+	uid, _ := uuid.NewRandom()
+	fid, _ := uuid.NewRandom()
+
+	t.Run("ID token", func(t *testing.T) {
+		ss, _ := security.GenerateIDToken(&models.User{UID: uid, Email: "bob@bob.com"}, keySet.Active(), exp, security.IDTokenClaims{}, opts)
+
+		i, err := tokenService.IntrospectToken(context.TODO(), ss)
+
+		assert.NoError(t, err)
+		assert.True(t, i.Active)
+		assert.Equal(t, models.TokenTypeIDToken, i.TokenType)
+		assert.Equal(t, uid.String(), i.Subject)
+		assert.Equal(t, "bob@bob.com", i.Username)
+		assert.Equal(t, "account", i.Issuer)
+		assert.NotZero(t, i.ExpiresAt)
+	})
+
+	t.Run("Access token", func(t *testing.T) {
+		ss, _ := security.GenerateAccessToken("billing", "billing", "users:read", keySet.Active(), exp, opts)
+
+		i, err := tokenService.IntrospectToken(context.TODO(), ss)
+
+		assert.NoError(t, err)
+		assert.True(t, i.Active)
+		assert.Equal(t, models.TokenTypeAccessToken, i.TokenType)
+		assert.Equal(t, "billing", i.Subject)
+		assert.Equal(t, "billing", i.ClientID)
+		assert.Equal(t, "users:read", i.Scope)
+		assert.NotEmpty(t, i.ID)
+	})
+
+	t.Run("Refresh token of an OAuth client", func(t *testing.T) {
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, fid, "aclient", secret, exp, opts)
+
+		mockTokenRepository.
+			On("GetRefreshToken", mock.Anything, uid.String(), refreshToken.ID.String()).
+			Return(&models.Session{ID: refreshToken.ID.String(), ClientID: "aclient", Scope: "read"}, nil)
+
+		i, err := tokenService.IntrospectToken(context.TODO(), refreshToken.SS)
+
+		assert.NoError(t, err)
+		assert.True(t, i.Active)
+		assert.Equal(t, models.TokenTypeRefreshToken, i.TokenType)
+		assert.Equal(t, uid.String(), i.Subject)
+		assert.Equal(t, "aclient", i.ClientID)
+		assert.Equal(t, "read", i.Scope)
+		assert.Equal(t, refreshToken.ID.String(), i.ID)
+	})
+
+	t.Run("Revoked refresh token", func(t *testing.T) {
+		refreshToken, _ := security.GenerateRefreshToken(uid, fid, secret, exp, opts)
+
+		mockTokenRepository.
+			On("GetRefreshToken", mock.Anything, uid.String(), refreshToken.ID.String()).
+			Return(nil, apperrors.NewAuthorization("Invalid refresh token"))
+
+		i, err := tokenService.IntrospectToken(context.TODO(), refreshToken.SS)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.TokenIntrospection{Active: false}, i)
+	})
+
+	t.Run("Error reading refresh token", func(t *testing.T) {
+		refreshToken, _ := security.GenerateRefreshToken(uid, fid, secret, exp, opts)
+
+		mockTokenRepository.
+			On("GetRefreshToken", mock.Anything, uid.String(), refreshToken.ID.String()).
+			Return(nil, apperrors.NewInternal())
+
+		i, err := tokenService.IntrospectToken(context.TODO(), refreshToken.SS)
+
+		assert.Nil(t, i)
+		assert.Equal(t, http.StatusInternalServerError, apperrors.Status(err))
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		i, err := tokenService.IntrospectToken(context.TODO(), "not.a.token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.TokenIntrospection{Active: false}, i)
+	})
+}
+
+func TestRevokeToken(t *testing.T) {
+	var exp int64 = 15 * 60
+	secret := "anotsorandomtestsecret"
+
+	priv, _ := ioutil.ReadFile("../rsa_private_test.pem")
+	privKey, _ := jwt.ParseRSAPrivateKeyFromPEM(priv)
+
+	keySet, _ := security.NewKeySet(security.NewSigningKey(privKey))
+
+	opts := security.TokenOptions{
+		Issuer:   "account",
+		Audience: "account",
+	}
+
+	uid, _ := uuid.NewRandom()
+	fid, _ := uuid.NewRandom()
+
+	newService := func() (models.TokenService, *mocks.MockTokenRepository) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+
+		tokenService := NewTokenService(&TSConfig{
+			TokenRepository: mockTokenRepository,
+			KeySet:          keySet,
+			RefreshSecret:   secret,
+			Issuer:          opts.Issuer,
+			Audience:        opts.Audience,
+		})
+
+		return tokenService, mockTokenRepository
+	}
+
+	t.Run("Revokes the token family", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, fid, "aclient", secret, exp, opts)
+
+		mockTokenRepository.On("DeleteRefreshTokenFamily", mock.Anything, uid.String(), fid.String()).Return(nil)
+
+		err := tokenService.RevokeToken(context.TODO(), "aclient", refreshToken.SS, false)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertExpectations(t)
+	})
+
+	t.Run("Token of another client", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, fid, "aclient", secret, exp, opts)
+
+		err := tokenService.RevokeToken(context.TODO(), "anotherclient", refreshToken.SS, false)
+
+		assert.Equal(t, apperrors.UnauthorizedClient, err.(*apperrors.OAuthError).Code)
+		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshTokenFamily", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("First-party token", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		refreshToken, _ := security.GenerateRefreshToken(uid, fid, secret, exp, opts)
+
+		err := tokenService.RevokeToken(context.TODO(), "aclient", refreshToken.SS, false)
+
+		assert.Equal(t, apperrors.UnauthorizedClient, err.(*apperrors.OAuthError).Code)
+		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshTokenFamily", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("First-party token revoked by any client", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		refreshToken, _ := security.GenerateRefreshToken(uid, fid, secret, exp, opts)
+
+		mockTokenRepository.On("DeleteRefreshTokenFamily", mock.Anything, uid.String(), fid.String()).Return(nil)
+
+		err := tokenService.RevokeToken(context.TODO(), "aservice", refreshToken.SS, true)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertExpectations(t)
+	})
+
+	t.Run("Token without family", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, uuid.Nil, "aclient", secret, exp, opts)
+
+		mockTokenRepository.
+			On("DeleteRefreshToken", mock.Anything, uid.String(), refreshToken.ID.String()).
+			Return(apperrors.NewAuthorization("Invalid refresh token"))
+
+		// tokens which were already revoked are ignored
+		err := tokenService.RevokeToken(context.TODO(), "aclient", refreshToken.SS, false)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertExpectations(t)
+	})
+
+	t.Run("Error revoking", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		refreshToken, _ := security.GenerateClientRefreshToken(uid, fid, "aclient", secret, exp, opts)
+
+		mockTokenRepository.On("DeleteRefreshTokenFamily", mock.Anything, uid.String(), fid.String()).Return(apperrors.NewInternal())
+
+		err := tokenService.RevokeToken(context.TODO(), "aclient", refreshToken.SS, false)
+
+		assert.Equal(t, http.StatusInternalServerError, apperrors.Status(err))
+	})
+
+	t.Run("ID token", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		ss, _ := security.GenerateIDToken(&models.User{UID: uid}, keySet.Active(), exp, security.IDTokenClaims{}, opts)
+
+		err := tokenService.RevokeToken(context.TODO(), "aclient", ss, false)
+
+		assert.Equal(t, apperrors.UnsupportedTokenType, err.(*apperrors.OAuthError).Code)
+		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		tokenService, mockTokenRepository := newService()
+
+		err := tokenService.RevokeToken(context.TODO(), "aclient", "not.a.token", false)
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertNotCalled(t, "DeleteRefreshTokenFamily", mock.Anything, mock.Anything, mock.Anything)
 	})
 }
@@ -1,8 +1,17 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net/url"
+	"path"
+	"sync"
+	"time"
 
 	"github.com/NetworkPy/muserv/muservice/account/models"
 	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
@@ -16,20 +25,90 @@ import (
 // userService acts as a struct for injecting an implementation of UserRepository
 // for use in service methods
 type userService struct {
-	UserRepository models.UserRepository
+	UserRepository            models.UserRepository
+	ImageRepository           models.ImageRepository
+	TokenRepository           models.TokenRepository
+	Mailer                    models.Mailer
+	EmailTokenSecret          string
+	VerifyEmailExpirationSecs int64
+	VerifyEmailURL            string
+	EmailTokenVerifier        *security.Verifier
+	PasswordResetExpiration   time.Duration
+	PasswordResetURL          string
+	MagicLinkExpirationSecs   int64
+	MagicLinkURL              string
+	MagicLinkSignup           bool
+
+	// background tracks emails which are still being sent
+	background sync.WaitGroup
 }
 
 // USConfig will hold repositories that will eventually be injected into this
 // this service layer
+// Verification and password reset emails link to VerifyEmailURL and
+// PasswordResetURL with the token appended as query parameter,
+// they are only sent if a Mailer is set. The same applies to magic
+// links and MagicLinkURL. MagicLinkSignup creates accounts for
+// unknown email addresses signing in with a magic link
 type USConfig struct {
-	UserRepository models.UserRepository
+	UserRepository              models.UserRepository
+	ImageRepository             models.ImageRepository
+	TokenRepository             models.TokenRepository
+	Mailer                      models.Mailer
+	EmailTokenSecret            string
+	VerifyEmailExpirationSecs   int64
+	VerifyEmailURL              string
+	PasswordResetExpirationSecs int64
+	PasswordResetURL            string
+	MagicLinkExpirationSecs     int64
+	MagicLinkURL                string
+	MagicLinkSignup             bool
 }
 
+// Expirations used when none are configured
+const (
+	DefaultVerifyEmailExpirationSecs   = 24 * 60 * 60
+	DefaultPasswordResetExpirationSecs = 15 * 60
+	DefaultMagicLinkExpirationSecs     = 15 * 60
+)
+
 // NewUserService is a factory function for
 // initializing a UserService with its repository layer dependencies
 func NewUserService(c *USConfig) models.UserService {
+	verifyEmailExp := c.VerifyEmailExpirationSecs
+
+	if verifyEmailExp <= 0 {
+		verifyEmailExp = DefaultVerifyEmailExpirationSecs
+	}
+
+	passwordResetExp := c.PasswordResetExpirationSecs
+
+	if passwordResetExp <= 0 {
+		passwordResetExp = DefaultPasswordResetExpirationSecs
+	}
+
+	magicLinkExp := c.MagicLinkExpirationSecs
+
+	if magicLinkExp <= 0 {
+		magicLinkExp = DefaultMagicLinkExpirationSecs
+	}
+
 	return &userService{
-		UserRepository: c.UserRepository,
+		UserRepository:            c.UserRepository,
+		ImageRepository:           c.ImageRepository,
+		Mailer:                    c.Mailer,
+		EmailTokenSecret:          c.EmailTokenSecret,
+		VerifyEmailExpirationSecs: verifyEmailExp,
+		VerifyEmailURL:            c.VerifyEmailURL,
+		EmailTokenVerifier: &security.Verifier{
+			Algorithms: []string{"HS256"},
+		},
+		TokenRepository:         c.TokenRepository,
+		PasswordResetExpiration: time.Duration(passwordResetExp) * time.Second,
+		PasswordResetURL:        c.PasswordResetURL,
+		MagicLinkExpirationSecs: magicLinkExp,
+		MagicLinkURL:            c.MagicLinkURL,
+		MagicLinkSignup:         c.MagicLinkSignup,
 	}
 }
 
@@ -56,7 +135,11 @@ func (s *userService) Signup(ctx context.Context, u *models.User) error {
 		return err
 	}
 
-	// ...
+	// the user is signed up even if the email can't be sent,
+	// they can request another one later
+	if err := s.sendVerificationEmail(ctx, u); err != nil {
+		log.Printf("Unable to send verification email to user: %v. Reason: %v\n", u.UID, err)
+	}
 
 	return nil
 }
@@ -69,7 +152,10 @@ func (s *userService) Signin(ctx context.Context, u *models.User) error {
 	uFetched, err := s.UserRepository.FindByEmail(ctx, u.Email)
 
 	// Will return NotAuthorized to client to omit details of why
+	// The password is still checked, so unknown emails take as
+	// long as known ones and can't be told apart by response time
 	if err != nil {
+		security.CompareDummyPassword(u.Passowrd)
 		return apperrors.NewAuthorization("Invalid email and password combination")
 	}
 
@@ -77,6 +163,12 @@ func (s *userService) Signin(ctx context.Context, u *models.User) error {
 	match, err := security.ComparePasswords(uFetched.Passowrd, u.Passowrd)
 
 	if err != nil {
+		if errors.Is(err, security.ErrMalformedHash) {
+			log.Printf("Stored password hash of uid: %v is malformed: %v\n", uFetched.UID, err)
+		} else {
+			log.Printf("Unable to verify password of uid: %v. Error: %v\n", uFetched.UID, err)
+		}
+
 		return apperrors.NewInternal()
 	}
 
@@ -84,7 +176,528 @@ func (s *userService) Signin(ctx context.Context, u *models.User) error {
 		return apperrors.NewAuthorization("Invalid email and password combination")
 	}
 
+	// the supplied password is only known on signin, so hashes of
+	// outdated algorithms or parameters are replaced here
+	if security.NeedsRehash(uFetched.Passowrd) {
+		s.rehashPassword(ctx, uFetched, u.Passowrd)
+	}
+
 	*u = *uFetched
 
 	return nil
 }
+
+// rehashPassword stores a hash of password created by the default hasher
+// Failing to do so doesn't fail the signin, the old hash stays valid
+func (s *userService) rehashPassword(ctx context.Context, u *models.User, password string) {
+	pw, err := security.HashPassword(password)
+
+	if err != nil {
+		log.Printf("Unable to rehash password for uid: %v. Error: %v\n", u.UID, err)
+		return
+	}
+
+	if err := s.UserRepository.UpdatePassword(ctx, u.UID, pw); err != nil {
+		log.Printf("Unable to store rehashed password for uid: %v. Error: %v\n", u.UID, err)
+		return
+	}
+
+	u.Passowrd = pw
+}
+
+// UpdateDetails updates the name, email and website of a user
+// u is updated with the user as stored after the update
+// A changed email address has to be verified again
+func (s *userService) UpdateDetails(ctx context.Context, u *models.User) error {
+	prev, err := s.UserRepository.FindByID(ctx, u.UID)
+
+	if err != nil {
+		return err
+	}
+
+	// Update user in UserRepository
+	err = s.UserRepository.Update(ctx, u)
+
+	if err != nil {
+		return err
+	}
+
+	if u.Email != prev.Email {
+		if err := s.sendVerificationEmail(ctx, u); err != nil {
+			log.Printf("Unable to send verification email to user: %v. Reason: %v\n", u.UID, err)
+		}
+	}
+
+	return nil
+}
+
+// SetProfileImage resizes img to a fixed avatar size, stores it and
+// updates the user's imageURL. A previous image of the user is removed
+func (s *userService) SetProfileImage(ctx context.Context, uid uuid.UUID, img io.Reader) (*models.User, error) {
+	u, err := s.UserRepository.FindByID(ctx, uid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(img)
+
+	if err != nil {
+		log.Printf("Unable to read image for uid: %v. Error: %v\n", uid, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	avatar, err := processProfileImage(data)
+
+	if err != nil {
+		log.Printf("Unable to process image for uid: %v. Error: %v\n", uid, err)
+		return nil, apperrors.NewBadRequest("Unable to process image")
+	}
+
+	// use a new object name for every upload, so clients
+	// never get a cached version of the previous image
+	objID, err := uuid.NewRandom()
+
+	if err != nil {
+		log.Printf("Unable to generate image object name for uid: %v. Error: %v\n", uid, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	imageURL, err := s.ImageRepository.UpdateProfile(ctx, objID.String()+".png", bytes.NewReader(avatar))
+
+	if err != nil {
+		return nil, err
+	}
+
+	updatedUser, err := s.UserRepository.UpdateImage(ctx, uid, imageURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the previous image is no longer referenced, failing
+	// to delete it only leaves an orphaned object behind
+	if u.ImageURL != "" {
+		if err := s.deleteImageObject(ctx, u.ImageURL); err != nil {
+			log.Printf("Unable to delete previous image: %v of uid: %v\n", u.ImageURL, uid)
+		}
+	}
+
+	return updatedUser, nil
+}
+
+// ClearProfileImage removes the stored image of a user and clears imageURL
+func (s *userService) ClearProfileImage(ctx context.Context, uid uuid.UUID) error {
+	u, err := s.UserRepository.FindByID(ctx, uid)
+
+	if err != nil {
+		return err
+	}
+
+	if u.ImageURL == "" {
+		return nil
+	}
+
+	if err := s.deleteImageObject(ctx, u.ImageURL); err != nil {
+		return err
+	}
+
+	_, err = s.UserRepository.UpdateImage(ctx, uid, "")
+
+	return err
+}
+
+// deleteImageObject deletes the image object imageURL points to
+// objects are named by the last segment of the url path
+func (s *userService) deleteImageObject(ctx context.Context, imageURL string) error {
+	urlPath, err := url.Parse(imageURL)
+
+	if err != nil {
+		log.Printf("Unable to parse image url: %v. Error: %v\n", imageURL, err)
+		return apperrors.NewInternal()
+	}
+
+	return s.ImageRepository.DeleteProfile(ctx, path.Base(urlPath.Path))
+}
+
+// SendVerificationEmail sends a new verification email
+// to the current email address of a user
+func (s *userService) SendVerificationEmail(ctx context.Context, uid uuid.UUID) error {
+	u, err := s.UserRepository.FindByID(ctx, uid)
+
+	if err != nil {
+		return err
+	}
+
+	if u.EmailVerified {
+		return apperrors.NewBadRequest("Email address is already verified")
+	}
+
+	return s.sendVerificationEmail(ctx, u)
+}
+
+// VerifyEmail marks the email address a verification token
+// was sent to as verified, and returns the updated user
+func (s *userService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	claims, err := security.ValidateEmailToken(token, security.PurposeVerifyEmail, s.EmailTokenSecret, s.EmailTokenVerifier)
+
+	if err != nil {
+		log.Printf("Unable to validate verification token - Error: %v\n", err)
+		return nil, apperrors.NewAuthorization("Invalid verification token")
+	}
+
+	uid, err := uuid.Parse(claims.Subject)
+
+	if err != nil {
+		log.Printf("Verification token subject is not a valid uid: %v\n", claims.Subject)
+		return nil, apperrors.NewAuthorization("Invalid verification token")
+	}
+
+	u, err := s.UserRepository.FindByID(ctx, uid)
+
+	if err != nil {
+		return nil, apperrors.NewAuthorization("Invalid verification token")
+	}
+
+	// tokens sent to a previous email address are no longer valid
+	if u.Email != claims.Email {
+		log.Printf("Verification token of user: %v was sent to a previous email address\n", uid)
+		return nil, apperrors.NewAuthorization("Invalid verification token")
+	}
+
+	if u.EmailVerified {
+		return u, nil
+	}
+
+	return s.UserRepository.SetEmailVerified(ctx, uid, claims.Email)
+}
+
+// sendVerificationEmail sends a verification token to the email address of u
+func (s *userService) sendVerificationEmail(ctx context.Context, u *models.User) error {
+	if s.Mailer == nil {
+		log.Printf("No mailer configured, skipping verification email to user: %v\n", u.UID)
+		return nil
+	}
+
+	token, err := security.GenerateEmailToken(u.UID, u.Email, security.PurposeVerifyEmail, s.EmailTokenSecret, s.VerifyEmailExpirationSecs)
+
+	if err != nil {
+		log.Printf("Error generating verification token for uid: %v. Error: %v\n", u.UID, err)
+		return apperrors.NewInternal()
+	}
+
+	return s.Mailer.Send(ctx, &models.Mail{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Please verify your email address by following this link:\n\n%s\n\nThe link expires in %v.\n",
+			tokenLink(s.VerifyEmailURL, token),
+			time.Duration(s.VerifyEmailExpirationSecs)*time.Second,
+		),
+	})
+}
+
+// emailTimeout limits the time an email sent in the background may take
+const emailTimeout = 30 * time.Second
+
+// sendInBackground calls send detached from the request. Sending an
+// email takes long enough that answering only once it was sent
+// would reveal which email addresses are registered. Errors are logged
+func (s *userService) sendInBackground(name string, send func(ctx context.Context) error) {
+	s.background.Add(1)
+
+	go func() {
+		defer s.background.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), emailTimeout)
+		defer cancel()
+
+		if err := send(ctx); err != nil {
+			log.Printf("Unable to send %s email: %v\n", name, err)
+		}
+	}()
+}
+
+// ForgotPassword sends a single use password reset token to email
+// Unknown email addresses are not reported and the email is sent in
+// the background, so callers can't find out which email addresses are
+// registered, not even from the time it takes to respond
+func (s *userService) ForgotPassword(ctx context.Context, email string) error {
+	u, err := s.UserRepository.FindByEmail(ctx, email)
+
+	if err != nil {
+		log.Printf("No password reset for unknown email: %v\n", email)
+		return nil
+	}
+
+	if s.Mailer == nil {
+		log.Printf("No mailer configured, skipping password reset email to user: %v\n", u.UID)
+		return nil
+	}
+
+	s.sendInBackground("password reset", func(ctx context.Context) error {
+		return s.sendPasswordResetEmail(ctx, u)
+	})
+
+	return nil
+}
+
+// sendPasswordResetEmail stores a new password reset token
+// and sends it to the email address of u
+func (s *userService) sendPasswordResetEmail(ctx context.Context, u *models.User) error {
+	token, err := security.GenerateOpaqueToken()
+
+	if err != nil {
+		log.Printf("Error generating password reset token for uid: %v. Error: %v\n", u.UID, err)
+		return apperrors.NewInternal()
+	}
+
+	// only the hash is stored, a leaked store can't be used to reset passwords
+	err = s.TokenRepository.SetPasswordResetToken(ctx, security.HashOpaqueToken(token), u.UID.String(), s.PasswordResetExpiration)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(ctx, &models.Mail{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"A password reset was requested for your account. Follow this link to choose a new password:\n\n%s\n\n"+
+				"The link expires in %v. If you did not request a password reset, you can ignore this email.\n",
+			tokenLink(s.PasswordResetURL, token),
+			s.PasswordResetExpiration,
+		),
+	})
+}
+
+// ResetPassword sets a new password for the user a password reset token
+// was issued to. All refresh tokens of the user are revoked, so
+// sessions which may have been started by an attacker end
+func (s *userService) ResetPassword(ctx context.Context, token string, password string) error {
+	userID, err := s.TokenRepository.ConsumePasswordResetToken(ctx, security.HashOpaqueToken(token))
+
+	if err != nil {
+		return err
+	}
+
+	uid, err := uuid.Parse(userID)
+
+	if err != nil {
+		log.Printf("Password reset token stored an invalid uid: %v\n", userID)
+		return apperrors.NewInternal()
+	}
+
+	pw, err := security.HashPassword(password)
+
+	if err != nil {
+		log.Printf("Unable to hash password for uid: %v\n", uid)
+		return apperrors.NewInternal()
+	}
+
+	if err := s.UserRepository.UpdatePassword(ctx, uid, pw); err != nil {
+		return err
+	}
+
+	return s.TokenRepository.DeleteUserRefreshTokens(ctx, userID)
+}
+
+// ChangePassword sets a new password for a user who knows their current
+// password. All refresh tokens of the user except keepTokenID, the token of
+// the session changing the password, are revoked
+func (s *userService) ChangePassword(ctx context.Context, uid uuid.UUID, currentPassword string, newPassword string, keepTokenID string) error {
+	u, err := s.UserRepository.FindByID(ctx, uid)
+
+	if err != nil {
+		return err
+	}
+
+	match, err := security.ComparePasswords(u.Passowrd, currentPassword)
+
+	if err != nil {
+		return apperrors.NewInternal()
+	}
+
+	if !match {
+		return apperrors.NewAuthorization("Invalid current password")
+	}
+
+	pw, err := security.HashPassword(newPassword)
+
+	if err != nil {
+		log.Printf("Unable to hash password for uid: %v\n", uid)
+		return apperrors.NewInternal()
+	}
+
+	if err := s.UserRepository.UpdatePassword(ctx, uid, pw); err != nil {
+		return err
+	}
+
+	return s.TokenRepository.DeleteUserRefreshTokensExcept(ctx, uid.String(), keepTokenID)
+}
+
+// SendMagicLink sends a single use sign in link to email
+// Links for unknown email addresses are only sent if MagicLinkSignup
+// is set, either way callers can't find out which are registered, as
+// the email is sent in the background
+func (s *userService) SendMagicLink(ctx context.Context, email string) error {
+	// the subject of links for new accounts is the nil uuid
+	uid := uuid.Nil
+
+	u, err := s.UserRepository.FindByEmail(ctx, email)
+
+	if err == nil {
+		uid = u.UID
+		email = u.Email
+	} else if !s.MagicLinkSignup {
+		log.Printf("No magic link for unknown email: %v\n", email)
+		return nil
+	}
+
+	if s.Mailer == nil {
+		log.Printf("No mailer configured, skipping magic link email to: %v\n", email)
+		return nil
+	}
+
+	s.sendInBackground("magic link", func(ctx context.Context) error {
+		return s.sendMagicLinkEmail(ctx, uid, email)
+	})
+
+	return nil
+}
+
+// sendMagicLinkEmail stores a new magic link token for the user
+// with uid, which is the nil uuid for new accounts, and sends it to email
+func (s *userService) sendMagicLinkEmail(ctx context.Context, uid uuid.UUID, email string) error {
+	token, err := security.GenerateEmailToken(uid, email, security.PurposeMagicLink, s.EmailTokenSecret, s.MagicLinkExpirationSecs)
+
+	if err != nil {
+		log.Printf("Error generating magic link token for email: %v. Error: %v\n", email, err)
+		return apperrors.NewInternal()
+	}
+
+	expiration := time.Duration(s.MagicLinkExpirationSecs) * time.Second
+
+	// the signed token is only accepted while its hash is stored
+	if err := s.TokenRepository.SetMagicLinkToken(ctx, security.HashOpaqueToken(token), email, expiration); err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(ctx, &models.Mail{
+		To:      email,
+		Subject: "Your sign in link",
+		Body: fmt.Sprintf(
+			"Follow this link to sign in:\n\n%s\n\n"+
+				"The link expires in %v and can only be used once. If you did not request it, you can ignore this email.\n",
+			tokenLink(s.MagicLinkURL, token),
+			expiration,
+		),
+	})
+}
+
+// SigninWithMagicLink consumes a magic link token and returns the user
+// it was sent to. The email address is marked verified, as the link
+// proves the user received it. New accounts are created if MagicLinkSignup is set
+func (s *userService) SigninWithMagicLink(ctx context.Context, token string) (*models.User, error) {
+	invalid := apperrors.NewAuthorization("Invalid magic link")
+
+	claims, err := security.ValidateEmailToken(token, security.PurposeMagicLink, s.EmailTokenSecret, s.EmailTokenVerifier)
+
+	if err != nil {
+		log.Printf("Unable to validate magic link token - Error: %v\n", err)
+		return nil, invalid
+	}
+
+	uid, err := uuid.Parse(claims.Subject)
+
+	if err != nil {
+		log.Printf("Magic link token subject is not a valid uid: %v\n", claims.Subject)
+		return nil, invalid
+	}
+
+	email, err := s.TokenRepository.ConsumeMagicLinkToken(ctx, security.HashOpaqueToken(token))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if email != claims.Email {
+		log.Printf("Magic link token was stored for: %v but issued to: %v\n", email, claims.Email)
+		return nil, invalid
+	}
+
+	var u *models.User
+
+	if uid == uuid.Nil {
+		u, err = s.magicLinkSignup(ctx, claims.Email)
+	} else {
+		u, err = s.UserRepository.FindByID(ctx, uid)
+
+		// links sent to a previous email address are no longer valid
+		if err == nil && u.Email != claims.Email {
+			log.Printf("Magic link of user: %v was sent to a previous email address\n", uid)
+			return nil, invalid
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if u.EmailVerified {
+		return u, nil
+	}
+
+	return s.UserRepository.SetEmailVerified(ctx, u.UID, u.Email)
+}
+
+// magicLinkSignup creates an account without a usable password for email
+// If the email address was registered since the link was sent, that
+// account is returned
+func (s *userService) magicLinkSignup(ctx context.Context, email string) (*models.User, error) {
+	if !s.MagicLinkSignup {
+		log.Printf("Magic link signup is disabled, rejecting link for: %v\n", email)
+		return nil, apperrors.NewAuthorization("Invalid magic link")
+	}
+
+	if u, err := s.UserRepository.FindByEmail(ctx, email); err == nil {
+		return u, nil
+	}
+
+	// the password is random and never shown, it can be
+	// set through the forgot password flow
+	password, err := security.GenerateOpaqueToken()
+
+	if err != nil {
+		log.Printf("Unable to generate password for magic link signup of: %v. Error: %v\n", email, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	pw, err := security.HashPassword(password)
+
+	if err != nil {
+		log.Printf("Unable to hash password for magic link signup of: %v. Error: %v\n", email, err)
+		return nil, apperrors.NewInternal()
+	}
+
+	u := &models.User{
+		Email:    email,
+		Passowrd: pw,
+	}
+
+	if err := s.UserRepository.Create(ctx, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// tokenLink appends token as query parameter to baseURL
+// Without baseURL only the token is returned
+func tokenLink(baseURL string, token string) string {
+	if baseURL == "" {
+		return token
+	}
+
+	return fmt.Sprintf("%s?token=%s", baseURL, url.QueryEscape(token))
+}
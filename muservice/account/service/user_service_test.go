@@ -1,10 +1,18 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/NetworkPy/muserv/muservice/account/mailer"
 	"github.com/NetworkPy/muserv/muservice/account/models"
 	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
 	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
@@ -78,7 +86,7 @@ func TestSignup(t *testing.T) {
 		// We can use Run method to modify the user when the Create method is called.
 		//  We can then chain on a Return method to return no error
 		mockUserRepository.
-			On("Create", mock.AnythingOfType("*context.emptyCtx"), mockUser).
+			On("Create", mock.Anything, mockUser).
 			Run(func(args mock.Arguments) {
 				userArg := args.Get(1).(*models.User) // arg 0 is context, arg 1 is *User
 				userArg.UID = uid
@@ -111,7 +119,7 @@ func TestSignup(t *testing.T) {
 		// We can use Run method to modify the user when the Create method is called.
 		//  We can then chain on a Return method to return no error
 		mockUserRepository.
-			On("Create", mock.AnythingOfType("*context.emptyCtx"), mockUser).
+			On("Create", mock.Anything, mockUser).
 			Return(mockErr)
 
 		ctx := context.TODO()
@@ -150,7 +158,7 @@ func TestSignin(t *testing.T) {
 		}
 
 		mockArgs := mock.Arguments{
-			mock.AnythingOfType("*context.emptyCtx"),
+			mock.Anything,
 			email,
 		}
 
@@ -181,7 +189,7 @@ func TestSignin(t *testing.T) {
 		}
 
 		mockArgs := mock.Arguments{
-			mock.AnythingOfType("*context.emptyCtx"),
+			mock.Anything,
 			email,
 		}
 
@@ -198,3 +206,1155 @@ func TestSignin(t *testing.T) {
 		mockUserRepository.AssertCalled(t, "FindByEmail", mockArgs...)
 	})
 }
+
+func TestSigninDefensive(t *testing.T) {
+	email := "bob@bob.com"
+	validPW := "howdyhoneighbor!"
+
+	t.Run("Unknown email", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, email).Return(nil, apperrors.NewNotFound("email", email))
+
+		err := us.Signin(context.TODO(), &models.User{
+			Email:    email,
+			Passowrd: validPW,
+		})
+
+		// same error as for a wrong password
+		assert.EqualError(t, err, "Invalid email and password combination")
+	})
+
+	malformed := []string{
+		"",
+		"nodot",
+		"too.many.dots",
+		"zz.00",
+		"00.zz",
+		"0011.0011",
+		"$scrypt$ln=15,r=8,p=1$c2FsdA",
+		"$scrypt$ln=99,r=8,p=1$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg",
+		"$scrypt$ln=15,r=8,p=1$c2FsdHNhbHQ$c2hvcnQ",
+		"$argon2id$v=16$m=65536,t=3,p=4$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg",
+		"$unknown$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg",
+	}
+
+	for _, stored := range malformed {
+		stored := stored
+
+		t.Run(fmt.Sprintf("Malformed hash %q", stored), func(t *testing.T) {
+			uid, _ := uuid.NewRandom()
+
+			mockUserRepository := new(mocks.MockUserRepository)
+			us := NewUserService(&USConfig{
+				UserRepository: mockUserRepository,
+			})
+
+			mockUserRepository.On("FindByEmail", mock.Anything, email).Return(&models.User{
+				UID:      uid,
+				Email:    email,
+				Passowrd: stored,
+			}, nil)
+
+			err := us.Signin(context.TODO(), &models.User{
+				Email:    email,
+				Passowrd: validPW,
+			})
+
+			assert.Equal(t, apperrors.Internal, err.(*apperrors.Error).Type)
+			mockUserRepository.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSigninRehash(t *testing.T) {
+	email := "bob@bob.com"
+	validPW := "howdyhoneighbor!"
+
+	// hex encoded hash.salt, as stored before hashes were in PHC format
+	legacyPW := "55c8260040c21e4d4579b8366cd21bac8f6b19b5447bc2646b10a5d1bfa07238." +
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+	t.Run("Legacy hash is rehashed", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, email).Return(&models.User{
+			UID:      uid,
+			Email:    email,
+			Passowrd: legacyPW,
+		}, nil)
+
+		var rehashedPW string
+
+		mockUserRepository.
+			On("UpdatePassword", mock.Anything, uid, mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) {
+				rehashedPW = args.Get(2).(string)
+			}).
+			Return(nil)
+
+		u := &models.User{
+			Email:    email,
+			Passowrd: validPW,
+		}
+
+		err := us.Signin(context.TODO(), u)
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rehashedPW, "$scrypt$ln=15,r=8,p=1$"))
+		assert.False(t, security.NeedsRehash(rehashedPW))
+
+		match, err := security.ComparePasswords(rehashedPW, validPW)
+		assert.NoError(t, err)
+		assert.True(t, match)
+	})
+
+	t.Run("Failure to store rehash", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, email).Return(&models.User{
+			UID:      uid,
+			Email:    email,
+			Passowrd: legacyPW,
+		}, nil)
+		mockUserRepository.On("UpdatePassword", mock.Anything, uid, mock.AnythingOfType("string")).Return(apperrors.NewInternal())
+
+		u := &models.User{
+			Email:    email,
+			Passowrd: validPW,
+		}
+
+		err := us.Signin(context.TODO(), u)
+
+		// signin succeeds with the legacy hash
+		assert.NoError(t, err)
+		assert.Equal(t, uid, u.UID)
+	})
+
+	t.Run("Outdated parameters are rehashed", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		weakHasher := &security.ScryptHasher{LogN: 10, R: 8, P: 1, SaltLen: 16, KeyLen: 32}
+		weakPW, _ := weakHasher.Hash(validPW)
+
+		mockUserRepository.On("FindByEmail", mock.Anything, email).Return(&models.User{
+			UID:      uid,
+			Email:    email,
+			Passowrd: weakPW,
+		}, nil)
+		mockUserRepository.On("UpdatePassword", mock.Anything, uid, mock.AnythingOfType("string")).Return(nil)
+
+		err := us.Signin(context.TODO(), &models.User{
+			Email:    email,
+			Passowrd: validPW,
+		})
+
+		assert.NoError(t, err)
+		mockUserRepository.AssertCalled(t, "UpdatePassword", mock.Anything, uid, mock.AnythingOfType("string"))
+	})
+
+	t.Run("Hashes of another algorithm are rehashed", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		scryptPW, _ := security.HashPassword(validPW)
+
+		assert.NoError(t, security.SetDefaultHasher("argon2id"))
+		defer security.SetDefaultHasher("scrypt")
+
+		var rehashedPW string
+
+		mockUserRepository.On("FindByEmail", mock.Anything, email).Return(&models.User{
+			UID:      uid,
+			Email:    email,
+			Passowrd: scryptPW,
+		}, nil)
+		mockUserRepository.
+			On("UpdatePassword", mock.Anything, uid, mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) {
+				rehashedPW = args.Get(2).(string)
+			}).
+			Return(nil)
+
+		err := us.Signin(context.TODO(), &models.User{
+			Email:    email,
+			Passowrd: validPW,
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rehashedPW, "$argon2id$v=19$m=65536,t=3,p=4$"))
+
+		match, err := security.ComparePasswords(rehashedPW, validPW)
+		assert.NoError(t, err)
+		assert.True(t, match)
+	})
+
+	t.Run("Current hash is not rehashed", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		currentPW, _ := security.HashPassword(validPW)
+
+		mockUserRepository.On("FindByEmail", mock.Anything, email).Return(&models.User{
+			UID:      uid,
+			Email:    email,
+			Passowrd: currentPW,
+		}, nil)
+
+		err := us.Signin(context.TODO(), &models.User{
+			Email:    email,
+			Passowrd: validPW,
+		})
+
+		assert.NoError(t, err)
+		mockUserRepository.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Wrong password is not rehashed", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, email).Return(&models.User{
+			UID:      uid,
+			Email:    email,
+			Passowrd: legacyPW,
+		}, nil)
+
+		err := us.Signin(context.TODO(), &models.User{
+			Email:    email,
+			Passowrd: "howdyhodufus!",
+		})
+
+		assert.Error(t, err)
+		mockUserRepository.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpdateDetails(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+			Mailer:         mockMailer,
+		})
+
+		mockUser := &models.User{
+			UID:     uid,
+			Email:   "bob@bob.com",
+			Website: "https://bob.com",
+			Name:    "A New Bob!",
+		}
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{
+			UID:   uid,
+			Email: "bob@bob.com",
+		}, nil)
+		mockUserRepository.On("Update", mock.Anything, mockUser).Return(nil)
+
+		ctx := context.TODO()
+		err := us.UpdateDetails(ctx, mockUser)
+
+		assert.NoError(t, err)
+		mockUserRepository.AssertCalled(t, "Update", mock.Anything, mockUser)
+
+		// email address is unchanged
+		assert.Empty(t, mockMailer.Sent())
+	})
+
+	t.Run("Email changed", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+			Mailer:         mockMailer,
+		})
+
+		mockUser := &models.User{
+			UID:   uid,
+			Email: "new@bob.com",
+		}
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{
+			UID:           uid,
+			Email:         "bob@bob.com",
+			EmailVerified: true,
+		}, nil)
+		mockUserRepository.On("Update", mock.Anything, mockUser).Return(nil)
+
+		ctx := context.TODO()
+		err := us.UpdateDetails(ctx, mockUser)
+
+		assert.NoError(t, err)
+
+		sent := mockMailer.Sent()
+		assert.Len(t, sent, 1)
+		assert.Equal(t, "new@bob.com", sent[0].To)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+		})
+
+		mockUser := &models.User{
+			UID:   uid,
+			Email: "taken@bob.com",
+		}
+
+		mockError := apperrors.NewConflict("email", mockUser.Email)
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid}, nil)
+		mockUserRepository.On("Update", mock.Anything, mockUser).Return(mockError)
+
+		ctx := context.TODO()
+		err := us.UpdateDetails(ctx, mockUser)
+
+		assert.Error(t, err)
+		assert.Equal(t, mockError, err)
+	})
+}
+
+func TestSetProfileImage(t *testing.T) {
+	// a non square image, which is cropped and resized
+	var imageBuf bytes.Buffer
+	png.Encode(&imageBuf, image.NewRGBA(image.Rect(0, 0, 300, 200)))
+
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockImageRepository := new(mocks.MockImageRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			ImageRepository: mockImageRepository,
+		})
+
+		imageURL := "http://localhost/images/new.png"
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{
+			UID:      uid,
+			ImageURL: "http://localhost/images/old.png",
+		}, nil)
+
+		mockImageRepository.
+			On("UpdateProfile", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+			Run(func(args mock.Arguments) {
+				objName := args.Get(1).(string)
+				assert.True(t, strings.HasSuffix(objName, ".png"))
+
+				data, _ := ioutil.ReadAll(args.Get(2).(*bytes.Reader))
+				cfg, err := png.DecodeConfig(bytes.NewReader(data))
+
+				assert.NoError(t, err)
+				assert.Equal(t, AvatarSize, cfg.Width)
+				assert.Equal(t, AvatarSize, cfg.Height)
+			}).
+			Return(imageURL, nil)
+
+		mockUserResp := &models.User{
+			UID:      uid,
+			ImageURL: imageURL,
+		}
+
+		mockUserRepository.On("UpdateImage", mock.Anything, uid, imageURL).Return(mockUserResp, nil)
+		mockImageRepository.On("DeleteProfile", mock.Anything, "old.png").Return(nil)
+
+		u, err := us.SetProfileImage(context.TODO(), uid, bytes.NewReader(imageBuf.Bytes()))
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockUserResp, u)
+		mockUserRepository.AssertExpectations(t)
+		mockImageRepository.AssertExpectations(t)
+	})
+
+	t.Run("Invalid image", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockImageRepository := new(mocks.MockImageRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			ImageRepository: mockImageRepository,
+		})
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid}, nil)
+
+		u, err := us.SetProfileImage(context.TODO(), uid, strings.NewReader("not an image"))
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+		mockImageRepository.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything, mock.Anything)
+		mockUserRepository.AssertNotCalled(t, "UpdateImage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Image too large", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockImageRepository := new(mocks.MockImageRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			ImageRepository: mockImageRepository,
+		})
+
+		// compresses to a few bytes, but is wider than allowed
+		var largeBuf bytes.Buffer
+		png.Encode(&largeBuf, image.NewGray(image.Rect(0, 0, maxImageSide+1, 1)))
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid}, nil)
+
+		u, err := us.SetProfileImage(context.TODO(), uid, bytes.NewReader(largeBuf.Bytes()))
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+		mockImageRepository.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Image repository error", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockImageRepository := new(mocks.MockImageRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			ImageRepository: mockImageRepository,
+		})
+
+		mockError := apperrors.NewInternal()
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid}, nil)
+		mockImageRepository.On("UpdateProfile", mock.Anything, mock.Anything, mock.Anything).Return("", mockError)
+
+		u, err := us.SetProfileImage(context.TODO(), uid, bytes.NewReader(imageBuf.Bytes()))
+
+		assert.Nil(t, u)
+		assert.Equal(t, mockError, err)
+		mockUserRepository.AssertNotCalled(t, "UpdateImage", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestClearProfileImage(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockImageRepository := new(mocks.MockImageRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			ImageRepository: mockImageRepository,
+		})
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{
+			UID:      uid,
+			ImageURL: "http://localhost/images/old.png",
+		}, nil)
+		mockImageRepository.On("DeleteProfile", mock.Anything, "old.png").Return(nil)
+		mockUserRepository.On("UpdateImage", mock.Anything, uid, "").Return(&models.User{UID: uid}, nil)
+
+		err := us.ClearProfileImage(context.TODO(), uid)
+
+		assert.NoError(t, err)
+		mockUserRepository.AssertExpectations(t)
+		mockImageRepository.AssertExpectations(t)
+	})
+
+	t.Run("No image", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockImageRepository := new(mocks.MockImageRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			ImageRepository: mockImageRepository,
+		})
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid}, nil)
+
+		err := us.ClearProfileImage(context.TODO(), uid)
+
+		assert.NoError(t, err)
+		mockImageRepository.AssertNotCalled(t, "DeleteProfile", mock.Anything, mock.Anything)
+		mockUserRepository.AssertNotCalled(t, "UpdateImage", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	secret := "emailsecret"
+	email := "bob@bob.com"
+
+	newService := func(mockUserRepository *mocks.MockUserRepository) models.UserService {
+		return NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			EmailTokenSecret: secret,
+		})
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+		token, _ := security.GenerateEmailToken(uid, email, security.PurposeVerifyEmail, secret, 60)
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := newService(mockUserRepository)
+
+		mockUserResp := &models.User{
+			UID:           uid,
+			Email:         email,
+			EmailVerified: true,
+		}
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, Email: email}, nil)
+		mockUserRepository.On("SetEmailVerified", mock.Anything, uid, email).Return(mockUserResp, nil)
+
+		u, err := us.VerifyEmail(context.TODO(), token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockUserResp, u)
+		mockUserRepository.AssertExpectations(t)
+	})
+
+	t.Run("Email changed since token was sent", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+		token, _ := security.GenerateEmailToken(uid, email, security.PurposeVerifyEmail, secret, 60)
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := newService(mockUserRepository)
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, Email: "new@bob.com"}, nil)
+
+		u, err := us.VerifyEmail(context.TODO(), token)
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockUserRepository.AssertNotCalled(t, "SetEmailVerified", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+		token, _ := security.GenerateEmailToken(uid, email, security.PurposeVerifyEmail, secret, -60)
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := newService(mockUserRepository)
+
+		u, err := us.VerifyEmail(context.TODO(), token)
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockUserRepository.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Token for another purpose", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+		token, _ := security.GenerateEmailToken(uid, email, "other", secret, 60)
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := newService(mockUserRepository)
+
+		u, err := us.VerifyEmail(context.TODO(), token)
+
+		assert.Nil(t, u)
+		assert.Error(t, err)
+		mockUserRepository.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+		token, _ := security.GenerateEmailToken(uid, email, security.PurposeVerifyEmail, "notthesecret", 60)
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		us := newService(mockUserRepository)
+
+		u, err := us.VerifyEmail(context.TODO(), token)
+
+		assert.Nil(t, u)
+		assert.Error(t, err)
+	})
+}
+
+func TestSendVerificationEmail(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			Mailer:           mockMailer,
+			EmailTokenSecret: "emailsecret",
+			VerifyEmailURL:   "https://example.com/verify",
+		})
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+
+		err := us.SendVerificationEmail(context.TODO(), uid)
+
+		assert.NoError(t, err)
+
+		sent := mockMailer.Sent()
+		assert.Len(t, sent, 1)
+		assert.Equal(t, "bob@bob.com", sent[0].To)
+		assert.Contains(t, sent[0].Body, "https://example.com/verify?token=")
+	})
+
+	t.Run("Already verified", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockMailer := new(mocks.MockMailer)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+			Mailer:         mockMailer,
+		})
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, EmailVerified: true}, nil)
+
+		err := us.SendVerificationEmail(context.TODO(), uid)
+
+		assert.Error(t, err)
+		mockMailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Mailer error", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockMailer := new(mocks.MockMailer)
+		us := NewUserService(&USConfig{
+			UserRepository: mockUserRepository,
+			Mailer:         mockMailer,
+		})
+
+		mockError := apperrors.NewInternal()
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+		mockMailer.On("Send", mock.Anything, mock.AnythingOfType("*models.Mail")).Return(mockError)
+
+		err := us.SendVerificationEmail(context.TODO(), uid)
+
+		assert.Equal(t, mockError, err)
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			Mailer:           mockMailer,
+			PasswordResetURL: "https://example.com/reset",
+		})
+
+		var storedHash string
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "bob@bob.com").Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+		mockTokenRepository.
+			On("SetPasswordResetToken", mock.Anything, mock.AnythingOfType("string"), uid.String(), 15*time.Minute).
+			Run(func(args mock.Arguments) {
+				storedHash = args.Get(1).(string)
+			}).
+			Return(nil)
+
+		err := us.ForgotPassword(context.TODO(), "bob@bob.com")
+		us.(*userService).background.Wait()
+
+		assert.NoError(t, err)
+		mockTokenRepository.AssertExpectations(t)
+
+		sent := mockMailer.Sent()
+		assert.Len(t, sent, 1)
+		assert.Equal(t, "bob@bob.com", sent[0].To)
+
+		// the email holds the token, only its hash is stored
+		link := regexp.MustCompile(`https://example.com/reset\?token=(\S+)`).FindStringSubmatch(sent[0].Body)
+		assert.Len(t, link, 2)
+		assert.Equal(t, security.HashOpaqueToken(link[1]), storedHash)
+		assert.NotContains(t, sent[0].Body, storedHash)
+	})
+
+	t.Run("Unknown email", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			TokenRepository: mockTokenRepository,
+			Mailer:          mockMailer,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "nobody@bob.com").Return(nil, apperrors.NewNotFound("email", "nobody@bob.com"))
+
+		err := us.ForgotPassword(context.TODO(), "nobody@bob.com")
+		us.(*userService).background.Wait()
+
+		assert.NoError(t, err)
+		assert.Empty(t, mockMailer.Sent())
+		mockTokenRepository.AssertNotCalled(t, "SetPasswordResetToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Responds before the email is sent", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			TokenRepository: mockTokenRepository,
+			Mailer:          mockMailer,
+		})
+
+		// storing the token blocks until the response was returned
+		sending := make(chan time.Time)
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "bob@bob.com").Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+		mockTokenRepository.
+			On("SetPasswordResetToken", mock.Anything, mock.AnythingOfType("string"), uid.String(), 15*time.Minute).
+			WaitUntil(sending).
+			Return(nil)
+
+		err := us.ForgotPassword(context.TODO(), "bob@bob.com")
+
+		assert.NoError(t, err)
+		assert.Empty(t, mockMailer.Sent())
+
+		close(sending)
+		us.(*userService).background.Wait()
+
+		assert.Len(t, mockMailer.Sent(), 1)
+	})
+
+	t.Run("Error sending the email", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			TokenRepository: mockTokenRepository,
+			Mailer:          mockMailer,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "bob@bob.com").Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+		mockTokenRepository.
+			On("SetPasswordResetToken", mock.Anything, mock.AnythingOfType("string"), uid.String(), 15*time.Minute).
+			Return(apperrors.NewInternal())
+
+		// the error is only logged, as for unknown emails nothing is reported
+		err := us.ForgotPassword(context.TODO(), "bob@bob.com")
+		us.(*userService).background.Wait()
+
+		assert.NoError(t, err)
+		assert.Empty(t, mockMailer.Sent())
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	token := "resettoken"
+	tokenHash := security.HashOpaqueToken(token)
+
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			TokenRepository: mockTokenRepository,
+		})
+
+		mockTokenRepository.On("ConsumePasswordResetToken", mock.Anything, tokenHash).Return(uid.String(), nil)
+		mockUserRepository.
+			On("UpdatePassword", mock.Anything, uid, mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) {
+				match, err := security.ComparePasswords(args.Get(2).(string), "anewpassword")
+				assert.NoError(t, err)
+				assert.True(t, match)
+			}).
+			Return(nil)
+		mockTokenRepository.On("DeleteUserRefreshTokens", mock.Anything, uid.String()).Return(nil)
+
+		err := us.ResetPassword(context.TODO(), token, "anewpassword")
+
+		assert.NoError(t, err)
+		mockUserRepository.AssertExpectations(t)
+		mockTokenRepository.AssertExpectations(t)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			TokenRepository: mockTokenRepository,
+		})
+
+		mockError := apperrors.NewAuthorization("Invalid password reset token")
+		mockTokenRepository.On("ConsumePasswordResetToken", mock.Anything, tokenHash).Return("", mockError)
+
+		err := us.ResetPassword(context.TODO(), token, "anewpassword")
+
+		assert.Equal(t, mockError, err)
+		mockUserRepository.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+		mockTokenRepository.AssertNotCalled(t, "DeleteUserRefreshTokens", mock.Anything, mock.Anything)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	currentPW := "howdyhoneighbor!"
+	hashedCurrentPW, _ := security.HashPassword(currentPW)
+
+	t.Run("Success", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+		keepTokenID := uuid.New().String()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			TokenRepository: mockTokenRepository,
+		})
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, Passowrd: hashedCurrentPW}, nil)
+		mockUserRepository.
+			On("UpdatePassword", mock.Anything, uid, mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) {
+				match, err := security.ComparePasswords(args.Get(2).(string), "anewpassword")
+				assert.NoError(t, err)
+				assert.True(t, match)
+			}).
+			Return(nil)
+		mockTokenRepository.On("DeleteUserRefreshTokensExcept", mock.Anything, uid.String(), keepTokenID).Return(nil)
+
+		err := us.ChangePassword(context.TODO(), uid, currentPW, "anewpassword", keepTokenID)
+
+		assert.NoError(t, err)
+		mockUserRepository.AssertExpectations(t)
+		mockTokenRepository.AssertExpectations(t)
+	})
+
+	t.Run("Wrong current password", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:  mockUserRepository,
+			TokenRepository: mockTokenRepository,
+		})
+
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, Passowrd: hashedCurrentPW}, nil)
+
+		err := us.ChangePassword(context.TODO(), uid, "howdyhodufus!", "anewpassword", uuid.New().String())
+
+		assert.EqualError(t, err, "Invalid current password")
+		mockUserRepository.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+		mockTokenRepository.AssertNotCalled(t, "DeleteUserRefreshTokensExcept", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSendMagicLink(t *testing.T) {
+	secret := "anemailsecret"
+
+	t.Run("Registered email", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			Mailer:           mockMailer,
+			EmailTokenSecret: secret,
+			MagicLinkURL:     "https://example.com/magic",
+		})
+
+		var storedHash string
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "bob@bob.com").Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+		mockTokenRepository.
+			On("SetMagicLinkToken", mock.Anything, mock.AnythingOfType("string"), "bob@bob.com", 15*time.Minute).
+			Run(func(args mock.Arguments) {
+				storedHash = args.Get(1).(string)
+			}).
+			Return(nil)
+
+		err := us.SendMagicLink(context.TODO(), "bob@bob.com")
+		us.(*userService).background.Wait()
+
+		assert.NoError(t, err)
+
+		sent := mockMailer.Sent()
+		assert.Len(t, sent, 1)
+		assert.Equal(t, "bob@bob.com", sent[0].To)
+
+		link := regexp.MustCompile(`https://example.com/magic\?token=(\S+)`).FindStringSubmatch(sent[0].Body)
+		assert.Len(t, link, 2)
+		assert.Equal(t, security.HashOpaqueToken(link[1]), storedHash)
+
+		claims, err := security.ValidateEmailToken(link[1], security.PurposeMagicLink, secret, &security.Verifier{Algorithms: []string{"HS256"}})
+		assert.NoError(t, err)
+		assert.Equal(t, uid.String(), claims.Subject)
+	})
+
+	t.Run("Unknown email without signup", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			Mailer:           mockMailer,
+			EmailTokenSecret: secret,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "nobody@bob.com").Return(nil, apperrors.NewNotFound("email", "nobody@bob.com"))
+
+		err := us.SendMagicLink(context.TODO(), "nobody@bob.com")
+		us.(*userService).background.Wait()
+
+		assert.NoError(t, err)
+		assert.Empty(t, mockMailer.Sent())
+		mockTokenRepository.AssertNotCalled(t, "SetMagicLinkToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown email with signup", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			Mailer:           mockMailer,
+			EmailTokenSecret: secret,
+			MagicLinkSignup:  true,
+		})
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "new@bob.com").Return(nil, apperrors.NewNotFound("email", "new@bob.com"))
+		mockTokenRepository.On("SetMagicLinkToken", mock.Anything, mock.AnythingOfType("string"), "new@bob.com", 15*time.Minute).Return(nil)
+
+		err := us.SendMagicLink(context.TODO(), "new@bob.com")
+		us.(*userService).background.Wait()
+
+		assert.NoError(t, err)
+
+		sent := mockMailer.Sent()
+		assert.Len(t, sent, 1)
+
+		token := strings.Fields(strings.SplitN(sent[0].Body, "\n\n", 3)[1])[0]
+		claims, err := security.ValidateEmailToken(token, security.PurposeMagicLink, secret, &security.Verifier{Algorithms: []string{"HS256"}})
+		assert.NoError(t, err)
+		assert.Equal(t, uuid.Nil.String(), claims.Subject)
+	})
+
+	t.Run("Responds before the email is sent", func(t *testing.T) {
+		uid, _ := uuid.NewRandom()
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		mockMailer := mailer.NewMemoryMailer()
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			Mailer:           mockMailer,
+			EmailTokenSecret: secret,
+		})
+
+		// storing the token blocks until the response was returned
+		sending := make(chan time.Time)
+
+		mockUserRepository.On("FindByEmail", mock.Anything, "bob@bob.com").Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+		mockTokenRepository.
+			On("SetMagicLinkToken", mock.Anything, mock.AnythingOfType("string"), "bob@bob.com", 15*time.Minute).
+			WaitUntil(sending).
+			Return(nil)
+
+		err := us.SendMagicLink(context.TODO(), "bob@bob.com")
+
+		assert.NoError(t, err)
+		assert.Empty(t, mockMailer.Sent())
+
+		close(sending)
+		us.(*userService).background.Wait()
+
+		assert.Len(t, mockMailer.Sent(), 1)
+	})
+}
+
+func TestSigninWithMagicLink(t *testing.T) {
+	secret := "anemailsecret"
+	uid, _ := uuid.NewRandom()
+
+	t.Run("Registered user", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			EmailTokenSecret: secret,
+		})
+
+		token, _ := security.GenerateEmailToken(uid, "bob@bob.com", security.PurposeMagicLink, secret, 60)
+		mockUser := &models.User{UID: uid, Email: "bob@bob.com", EmailVerified: true}
+
+		mockTokenRepository.On("ConsumeMagicLinkToken", mock.Anything, security.HashOpaqueToken(token)).Return("bob@bob.com", nil)
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(mockUser, nil)
+
+		u, err := us.SigninWithMagicLink(context.TODO(), token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockUser, u)
+		mockUserRepository.AssertNotCalled(t, "SetEmailVerified", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unverified email is verified", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			EmailTokenSecret: secret,
+		})
+
+		token, _ := security.GenerateEmailToken(uid, "bob@bob.com", security.PurposeMagicLink, secret, 60)
+		verified := &models.User{UID: uid, Email: "bob@bob.com", EmailVerified: true}
+
+		mockTokenRepository.On("ConsumeMagicLinkToken", mock.Anything, security.HashOpaqueToken(token)).Return("bob@bob.com", nil)
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(&models.User{UID: uid, Email: "bob@bob.com"}, nil)
+		mockUserRepository.On("SetEmailVerified", mock.Anything, uid, "bob@bob.com").Return(verified, nil)
+
+		u, err := us.SigninWithMagicLink(context.TODO(), token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, verified, u)
+	})
+
+	t.Run("Used link", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			EmailTokenSecret: secret,
+		})
+
+		token, _ := security.GenerateEmailToken(uid, "bob@bob.com", security.PurposeMagicLink, secret, 60)
+
+		mockTokenRepository.On("ConsumeMagicLinkToken", mock.Anything, security.HashOpaqueToken(token)).Return("", apperrors.NewAuthorization("Invalid magic link"))
+
+		u, err := us.SigninWithMagicLink(context.TODO(), token)
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockUserRepository.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Verification token is rejected", func(t *testing.T) {
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			TokenRepository:  mockTokenRepository,
+			EmailTokenSecret: secret,
+		})
+
+		token, _ := security.GenerateEmailToken(uid, "bob@bob.com", security.PurposeVerifyEmail, secret, 60)
+
+		u, err := us.SigninWithMagicLink(context.TODO(), token)
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockTokenRepository.AssertNotCalled(t, "ConsumeMagicLinkToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("New account", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			EmailTokenSecret: secret,
+			MagicLinkSignup:  true,
+		})
+
+		token, _ := security.GenerateEmailToken(uuid.Nil, "new@bob.com", security.PurposeMagicLink, secret, 60)
+		verified := &models.User{UID: uid, Email: "new@bob.com", EmailVerified: true}
+
+		mockTokenRepository.On("ConsumeMagicLinkToken", mock.Anything, security.HashOpaqueToken(token)).Return("new@bob.com", nil)
+		mockUserRepository.On("FindByEmail", mock.Anything, "new@bob.com").Return(nil, apperrors.NewNotFound("email", "new@bob.com"))
+		mockUserRepository.
+			On("Create", mock.Anything, mock.AnythingOfType("*models.User")).
+			Run(func(args mock.Arguments) {
+				u := args.Get(1).(*models.User)
+				assert.NotEmpty(t, u.Passowrd)
+				u.UID = uid
+			}).
+			Return(nil)
+		mockUserRepository.On("SetEmailVerified", mock.Anything, uid, "new@bob.com").Return(verified, nil)
+
+		u, err := us.SigninWithMagicLink(context.TODO(), token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, verified, u)
+	})
+
+	t.Run("New account without signup", func(t *testing.T) {
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		us := NewUserService(&USConfig{
+			UserRepository:   mockUserRepository,
+			TokenRepository:  mockTokenRepository,
+			EmailTokenSecret: secret,
+		})
+
+		token, _ := security.GenerateEmailToken(uuid.Nil, "new@bob.com", security.PurposeMagicLink, secret, 60)
+
+		mockTokenRepository.On("ConsumeMagicLinkToken", mock.Anything, security.HashOpaqueToken(token)).Return("new@bob.com", nil)
+
+		u, err := us.SigninWithMagicLink(context.TODO(), token)
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockUserRepository.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/security"
+	"github.com/google/uuid"
+)
+
+// DefaultWebAuthnChallengeExpirationSecs is used when WebAuthnConfig leaves it unset
+const DefaultWebAuthnChallengeExpirationSecs = 5 * 60
+
+// Values of stored challenges, which bind a challenge to its ceremony
+// Registration challenges are also bound to the user
+const (
+	challengeRegister = "register:"
+	challengeLogin    = "login:"
+)
+
+// webAuthnService registers passkeys and signs users in with them
+type webAuthnService struct {
+	UserRepository       models.UserRepository
+	CredentialRepository models.CredentialRepository
+	TokenRepository      models.TokenRepository
+	RPID                 string
+	RPName               string
+	Origins              []string
+	ChallengeExpiration  time.Duration
+}
+
+// WebAuthnConfig will hold repositories that will eventually be injected into
+// this service layer. RPID is the domain credentials are scoped to and Origins
+// are the origins of pages allowed to use them. Challenges are stored with
+// the TokenRepository
+type WebAuthnConfig struct {
+	UserRepository          models.UserRepository
+	CredentialRepository    models.CredentialRepository
+	TokenRepository         models.TokenRepository
+	RPID                    string
+	RPName                  string
+	Origins                 []string
+	ChallengeExpirationSecs int64
+}
+
+// NewWebAuthnService is a factory function for
+// initializing a WebAuthnService with its repository layer dependencies
+func NewWebAuthnService(c *WebAuthnConfig) models.WebAuthnService {
+	challengeExp := c.ChallengeExpirationSecs
+	if challengeExp <= 0 {
+		challengeExp = DefaultWebAuthnChallengeExpirationSecs
+	}
+
+	rpName := c.RPName
+	if rpName == "" {
+		rpName = c.RPID
+	}
+
+	return &webAuthnService{
+		UserRepository:       c.UserRepository,
+		CredentialRepository: c.CredentialRepository,
+		TokenRepository:      c.TokenRepository,
+		RPID:                 c.RPID,
+		RPName:               rpName,
+		Origins:              c.Origins,
+		ChallengeExpiration:  time.Duration(challengeExp) * time.Second,
+	}
+}
+
+// BeginRegistration creates the options for a new passkey of a user
+func (s *webAuthnService) BeginRegistration(ctx context.Context, u *models.User) (*models.PublicKeyCredentialCreationOptions, error) {
+	credentials, err := s.CredentialRepository.FindByUID(ctx, u.UID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.newChallenge(ctx, challengeRegister+u.UID.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	// existing credentials are excluded, so an
+	// authenticator doesn't register twice
+	exclude := make([]models.CredentialDescriptor, len(credentials))
+	for i, c := range credentials {
+		exclude[i] = models.CredentialDescriptor{Type: "public-key", ID: c.ID}
+	}
+
+	displayName := u.Name
+	if displayName == "" {
+		displayName = u.Email
+	}
+
+	return &models.PublicKeyCredentialCreationOptions{
+		Challenge: challenge,
+		RP: models.RelyingParty{
+			ID:   s.RPID,
+			Name: s.RPName,
+		},
+		User: models.WebAuthnUser{
+			ID:          base64.RawURLEncoding.EncodeToString(u.UID[:]),
+			Name:        u.Email,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams: []models.CredentialParameter{
+			{Type: "public-key", Alg: security.COSEAlgES256},
+			{Type: "public-key", Alg: security.COSEAlgEdDSA},
+			{Type: "public-key", Alg: security.COSEAlgRS256},
+		},
+		Timeout:            s.ChallengeExpiration.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: models.AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration verifies a credential created with the options
+// of BeginRegistration and stores it for the user
+func (s *webAuthnService) FinishRegistration(ctx context.Context, uid uuid.UUID, name string, c *models.CredentialCreationResponse) (*models.Credential, error) {
+	invalid := apperrors.NewBadRequest("Invalid credential")
+
+	clientDataJSON, err := security.DecodeBase64URL(c.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, invalid
+	}
+
+	clientData, err := security.ParseClientData(clientDataJSON, security.ClientDataTypeCreate, s.Origins)
+
+	if err != nil {
+		log.Printf("Rejected credential registration of uid: %v: %v\n", uid, err)
+		return nil, invalid
+	}
+
+	if err := s.consumeChallenge(ctx, clientData.Challenge, challengeRegister+uid.String()); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := security.DecodeBase64URL(c.Response.AttestationObject)
+
+	if err != nil {
+		return nil, invalid
+	}
+
+	authData, err := security.ParseAttestationObject(attestationObject)
+
+	if err != nil {
+		log.Printf("Rejected credential registration of uid: %v: %v\n", uid, err)
+		return nil, invalid
+	}
+
+	ad, err := security.ParseAuthenticatorData(authData, s.RPID)
+
+	if err != nil {
+		log.Printf("Rejected credential registration of uid: %v: %v\n", uid, err)
+		return nil, invalid
+	}
+
+	// passkeys sign users in without a second factor,
+	// so they must verify the user, not just their presence
+	if ad.Flags&security.AuthenticatorFlagUserVerified == 0 {
+		log.Printf("Rejected credential registration of uid: %v: user was not verified\n", uid)
+		return nil, invalid
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(ad.CredentialID)
+
+	if ad.CredentialID == nil || credentialID != normalizeCredentialID(c.ID) {
+		return nil, invalid
+	}
+
+	if _, _, err := security.ParseCOSEKey(ad.CredentialPublicKey); err != nil {
+		log.Printf("Rejected credential registration of uid: %v: %v\n", uid, err)
+		return nil, apperrors.NewBadRequest("Unsupported credential algorithm")
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+
+	credential := &models.Credential{
+		ID:        credentialID,
+		UID:       uid,
+		Name:      name,
+		PublicKey: ad.CredentialPublicKey,
+		SignCount: int64(ad.SignCount),
+	}
+
+	if err := s.CredentialRepository.Create(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// BeginLogin creates the options to sign in with a passkey
+// No credentials are listed, the authenticator lets the user choose one
+func (s *webAuthnService) BeginLogin(ctx context.Context) (*models.PublicKeyCredentialRequestOptions, error) {
+	challenge, err := s.newChallenge(ctx, challengeLogin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PublicKeyCredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.ChallengeExpiration.Milliseconds(),
+		RPID:             s.RPID,
+		AllowCredentials: []models.CredentialDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishLogin verifies an assertion created with the options
+// of BeginLogin and returns the user the credential belongs to
+func (s *webAuthnService) FinishLogin(ctx context.Context, c *models.CredentialAssertionResponse) (*models.User, error) {
+	invalid := apperrors.NewAuthorization("Invalid credential")
+
+	clientDataJSON, err := security.DecodeBase64URL(c.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, invalid
+	}
+
+	clientData, err := security.ParseClientData(clientDataJSON, security.ClientDataTypeGet, s.Origins)
+
+	if err != nil {
+		log.Printf("Rejected passkey sign in: %v\n", err)
+		return nil, invalid
+	}
+
+	if err := s.consumeChallenge(ctx, clientData.Challenge, challengeLogin); err != nil {
+		return nil, err
+	}
+
+	credential, err := s.CredentialRepository.FindByID(ctx, normalizeCredentialID(c.ID))
+
+	if err != nil {
+		log.Printf("Passkey sign in with unknown credential: %v\n", c.ID)
+		return nil, invalid
+	}
+
+	if c.Response.UserHandle != "" {
+		userHandle, err := security.DecodeBase64URL(c.Response.UserHandle)
+
+		if err != nil || string(userHandle) != string(credential.UID[:]) {
+			log.Printf("Passkey sign in with credential: %v has wrong user handle\n", credential.ID)
+			return nil, invalid
+		}
+	}
+
+	authData, err := security.DecodeBase64URL(c.Response.AuthenticatorData)
+
+	if err != nil {
+		return nil, invalid
+	}
+
+	signature, err := security.DecodeBase64URL(c.Response.Signature)
+
+	if err != nil {
+		return nil, invalid
+	}
+
+	ad, err := security.ParseAuthenticatorData(authData, s.RPID)
+
+	if err != nil {
+		log.Printf("Rejected passkey sign in with credential: %v: %v\n", credential.ID, err)
+		return nil, invalid
+	}
+
+	if err := security.VerifyAssertionSignature(credential.PublicKey, authData, clientDataJSON, signature); err != nil {
+		log.Printf("Rejected passkey sign in with credential: %v: %v\n", credential.ID, err)
+		return nil, invalid
+	}
+
+	// a passkey replaces both the password and the second factor,
+	// which only holds if the authenticator verified the user
+	if ad.Flags&security.AuthenticatorFlagUserVerified == 0 {
+		log.Printf("Rejected passkey sign in with credential: %v: user was not verified\n", credential.ID)
+		return nil, invalid
+	}
+
+	// authenticators which count signatures must always increase the
+	// count, otherwise the credential may have been cloned
+	signCount := int64(ad.SignCount)
+
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		log.Printf("Sign count of credential: %v did not increase, it may be cloned\n", credential.ID)
+		return nil, invalid
+	}
+
+	if err := s.CredentialRepository.UpdateSignCount(ctx, credential.ID, signCount); err != nil {
+		return nil, err
+	}
+
+	return s.UserRepository.FindByID(ctx, credential.UID)
+}
+
+// ListCredentials returns the passkeys of a user
+func (s *webAuthnService) ListCredentials(ctx context.Context, uid uuid.UUID) ([]*models.Credential, error) {
+	return s.CredentialRepository.FindByUID(ctx, uid)
+}
+
+// DeleteCredential removes a passkey of a user
+func (s *webAuthnService) DeleteCredential(ctx context.Context, uid uuid.UUID, id string) error {
+	return s.CredentialRepository.Delete(ctx, uid, normalizeCredentialID(id))
+}
+
+// newChallenge creates and stores a challenge for a ceremony
+func (s *webAuthnService) newChallenge(ctx context.Context, value string) (string, error) {
+	challenge, err := security.GenerateOpaqueToken()
+
+	if err != nil {
+		log.Printf("Unable to generate webauthn challenge: %v\n", err)
+		return "", apperrors.NewInternal()
+	}
+
+	if err := s.TokenRepository.SetWebAuthnChallenge(ctx, challenge, value, s.ChallengeExpiration); err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// consumeChallenge consumes a challenge and checks
+// it was created for the expected ceremony
+func (s *webAuthnService) consumeChallenge(ctx context.Context, challenge string, expected string) error {
+	value, err := s.TokenRepository.ConsumeWebAuthnChallenge(ctx, challenge)
+
+	if err != nil {
+		return err
+	}
+
+	if value != expected {
+		log.Printf("Challenge was created for: %v but used for: %v\n", value, expected)
+		return apperrors.NewAuthorization("Invalid or expired challenge")
+	}
+
+	return nil
+}
+
+// normalizeCredentialID strips padding clients may add to credential ids
+func normalizeCredentialID(id string) string {
+	b, err := security.DecodeBase64URL(id)
+
+	if err != nil {
+		return id
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/NetworkPy/muserv/muservice/account/models"
+	"github.com/NetworkPy/muserv/muservice/account/models/apperrors"
+	"github.com/NetworkPy/muserv/muservice/account/models/mocks"
+	"github.com/NetworkPy/muserv/muservice/account/security"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// cborPair is an entry of a cborMap, maps are ordered
+// so encodings are deterministic
+type cborPair struct {
+	key   interface{}
+	value interface{}
+}
+
+type cborMap []cborPair
+
+// cborEncode encodes the subset of CBOR the software authenticator uses
+func cborEncode(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 256:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			b := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(b[1:], uint16(n))
+			return b
+		}
+	}
+
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case cborMap:
+		b := head(5, uint64(len(v)))
+		for _, p := range v {
+			b = append(b, cborEncode(p.key)...)
+			b = append(b, cborEncode(p.value)...)
+		}
+		return b
+	}
+
+	panic("unsupported cbor type")
+}
+
+// softAuthenticator is a software WebAuthn authenticator holding one
+// ES256 credential, so the ceremonies can be tested without hardware
+type softAuthenticator struct {
+	rpID         string
+	origin       string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   string
+	signCount    uint32
+	// presenceOnly authenticators don't verify the user
+	presenceOnly bool
+}
+
+func newSoftAuthenticator(t *testing.T, rpID string, origin string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	assert.NoError(t, err)
+
+	return &softAuthenticator{
+		rpID:         rpID,
+		origin:       origin,
+		key:          key,
+		credentialID: credentialID,
+	}
+}
+
+func (a *softAuthenticator) clientData(typ string, challenge string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+
+	return b
+}
+
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	if a.presenceOnly {
+		flags &^= security.AuthenticatorFlagUserVerified
+	}
+
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	b := append([]byte{}, rpIDHash[:]...)
+	b = append(b, flags)
+
+	count := make([]byte, 4)
+	binary.BigEndian.PutUint32(count, a.signCount)
+	b = append(b, count...)
+
+	return append(b, attested...)
+}
+
+func (a *softAuthenticator) id() string {
+	return base64.RawURLEncoding.EncodeToString(a.credentialID)
+}
+
+// create answers navigator.credentials.create
+func (a *softAuthenticator) create(options *models.PublicKeyCredentialCreationOptions) *models.CredentialCreationResponse {
+	a.userHandle = options.User.ID
+
+	coseKey := cborEncode(cborMap{
+		{1, 2},
+		{3, -7},
+		{-1, 1},
+		{-2, a.key.X.FillBytes(make([]byte, 32))},
+		{-3, a.key.Y.FillBytes(make([]byte, 32))},
+	})
+
+	attested := make([]byte, 16) // aaguid
+	attested = append(attested, byte(len(a.credentialID)>>8), byte(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, coseKey...)
+
+	attestationObject := cborEncode(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", a.authData(0x45, attested)},
+	})
+
+	return &models.CredentialCreationResponse{
+		ID:    a.id(),
+		RawID: a.id(),
+		Type:  "public-key",
+		Response: models.AttestationResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", options.Challenge)),
+			AttestationObject: base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	}
+}
+
+// get answers navigator.credentials.get
+func (a *softAuthenticator) get(t *testing.T, options *models.PublicKeyCredentialRequestOptions) *models.CredentialAssertionResponse {
+	a.signCount++
+
+	clientDataJSON := a.clientData("webauthn.get", options.Challenge)
+	authData := a.authData(0x05, nil)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	assert.NoError(t, err)
+
+	return &models.CredentialAssertionResponse{
+		ID:    a.id(),
+		RawID: a.id(),
+		Type:  "public-key",
+		Response: models.AssertionResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+			AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+			Signature:         base64.RawURLEncoding.EncodeToString(signature),
+			UserHandle:        a.userHandle,
+		},
+	}
+}
+
+func TestWebAuthn(t *testing.T) {
+	rpID := "example.com"
+	origin := "https://example.com"
+	challengeExp := time.Duration(DefaultWebAuthnChallengeExpirationSecs) * time.Second
+
+	uid, _ := uuid.NewRandom()
+	mockUser := &models.User{
+		UID:   uid,
+		Email: "bob@bob.com",
+		Name:  "Bob",
+	}
+
+	newService := func(mockUserRepository *mocks.MockUserRepository, mockCredentialRepository *mocks.MockCredentialRepository, mockTokenRepository *mocks.MockTokenRepository) models.WebAuthnService {
+		return NewWebAuthnService(&WebAuthnConfig{
+			UserRepository:       mockUserRepository,
+			CredentialRepository: mockCredentialRepository,
+			TokenRepository:      mockTokenRepository,
+			RPID:                 rpID,
+			Origins:              []string{origin},
+		})
+	}
+
+	// register creates a credential with authenticator and returns it as stored
+	register := func(t *testing.T, authenticator *softAuthenticator) *models.Credential {
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		mockCredentialRepository.On("FindByUID", mock.Anything, uid).Return([]*models.Credential{}, nil)
+		mockCredentialRepository.On("Create", mock.Anything, mock.AnythingOfType("*models.Credential")).Return(nil)
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), "register:"+uid.String(), challengeExp).Return(nil)
+
+		options, err := ws.BeginRegistration(context.TODO(), mockUser)
+		assert.NoError(t, err)
+		assert.Equal(t, rpID, options.RP.ID)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(uid[:]), options.User.ID)
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("register:"+uid.String(), nil)
+
+		credential, err := ws.FinishRegistration(context.TODO(), uid, "Laptop", authenticator.create(options))
+		assert.NoError(t, err)
+
+		return credential
+	}
+
+	t.Run("Register and sign in", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, origin)
+		credential := register(t, authenticator)
+
+		assert.Equal(t, authenticator.id(), credential.ID)
+		assert.Equal(t, uid, credential.UID)
+		assert.Equal(t, "Laptop", credential.Name)
+		assert.Equal(t, int64(0), credential.SignCount)
+
+		mockUserRepository := new(mocks.MockUserRepository)
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(mockUserRepository, mockCredentialRepository, mockTokenRepository)
+
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), "login:", challengeExp).Return(nil)
+
+		options, err := ws.BeginLogin(context.TODO())
+		assert.NoError(t, err)
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("login:", nil)
+		mockCredentialRepository.On("FindByID", mock.Anything, credential.ID).Return(credential, nil)
+		mockCredentialRepository.On("UpdateSignCount", mock.Anything, credential.ID, int64(1)).Return(nil)
+		mockUserRepository.On("FindByID", mock.Anything, uid).Return(mockUser, nil)
+
+		u, err := ws.FinishLogin(context.TODO(), authenticator.get(t, options))
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockUser, u)
+		mockCredentialRepository.AssertExpectations(t)
+	})
+
+	t.Run("Wrong origin", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, "https://evil.com")
+
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		mockCredentialRepository.On("FindByUID", mock.Anything, uid).Return([]*models.Credential{}, nil)
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), "register:"+uid.String(), challengeExp).Return(nil)
+
+		options, err := ws.BeginRegistration(context.TODO(), mockUser)
+		assert.NoError(t, err)
+
+		credential, err := ws.FinishRegistration(context.TODO(), uid, "", authenticator.create(options))
+
+		assert.Nil(t, credential)
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+		mockTokenRepository.AssertNotCalled(t, "ConsumeWebAuthnChallenge", mock.Anything, mock.Anything)
+		mockCredentialRepository.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Registration challenge of another user", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, origin)
+		otherUID, _ := uuid.NewRandom()
+
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		mockCredentialRepository.On("FindByUID", mock.Anything, uid).Return([]*models.Credential{}, nil)
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string"), challengeExp).Return(nil)
+
+		options, err := ws.BeginRegistration(context.TODO(), mockUser)
+		assert.NoError(t, err)
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("register:"+uid.String(), nil)
+
+		credential, err := ws.FinishRegistration(context.TODO(), otherUID, "", authenticator.create(options))
+
+		assert.Nil(t, credential)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockCredentialRepository.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid signature", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, origin)
+		credential := register(t, authenticator)
+
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), "login:", challengeExp).Return(nil)
+
+		options, err := ws.BeginLogin(context.TODO())
+		assert.NoError(t, err)
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("login:", nil)
+		mockCredentialRepository.On("FindByID", mock.Anything, credential.ID).Return(credential, nil)
+
+		// sign with a different key
+		assertion := authenticator.get(t, options)
+		other := newSoftAuthenticator(t, rpID, origin)
+		other.credentialID = authenticator.credentialID
+		other.userHandle = authenticator.userHandle
+		assertion.Response.Signature = other.get(t, options).Response.Signature
+
+		u, err := ws.FinishLogin(context.TODO(), assertion)
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockCredentialRepository.AssertNotCalled(t, "UpdateSignCount", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Sign count did not increase", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, origin)
+		credential := register(t, authenticator)
+		credential.SignCount = 5
+
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), "login:", challengeExp).Return(nil)
+
+		options, err := ws.BeginLogin(context.TODO())
+		assert.NoError(t, err)
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("login:", nil)
+		mockCredentialRepository.On("FindByID", mock.Anything, credential.ID).Return(credential, nil)
+
+		u, err := ws.FinishLogin(context.TODO(), authenticator.get(t, options))
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockCredentialRepository.AssertNotCalled(t, "UpdateSignCount", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("User not verified", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, origin)
+		credential := register(t, authenticator)
+		authenticator.presenceOnly = true
+
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), "login:", challengeExp).Return(nil)
+
+		options, err := ws.BeginLogin(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, "required", options.UserVerification)
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("login:", nil)
+		mockCredentialRepository.On("FindByID", mock.Anything, credential.ID).Return(credential, nil)
+
+		u, err := ws.FinishLogin(context.TODO(), authenticator.get(t, options))
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockCredentialRepository.AssertNotCalled(t, "UpdateSignCount", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Registration without user verification", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, origin)
+		authenticator.presenceOnly = true
+
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		mockCredentialRepository.On("FindByUID", mock.Anything, uid).Return([]*models.Credential{}, nil)
+		mockTokenRepository.On("SetWebAuthnChallenge", mock.Anything, mock.AnythingOfType("string"), "register:"+uid.String(), challengeExp).Return(nil)
+
+		options, err := ws.BeginRegistration(context.TODO(), mockUser)
+		assert.NoError(t, err)
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("register:"+uid.String(), nil)
+
+		credential, err := ws.FinishRegistration(context.TODO(), uid, "", authenticator.create(options))
+
+		assert.Nil(t, credential)
+		assert.Equal(t, apperrors.BadRequest, err.(*apperrors.Error).Type)
+		mockCredentialRepository.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Login with registration challenge", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, rpID, origin)
+		credential := register(t, authenticator)
+
+		mockCredentialRepository := new(mocks.MockCredentialRepository)
+		mockTokenRepository := new(mocks.MockTokenRepository)
+		ws := newService(nil, mockCredentialRepository, mockTokenRepository)
+
+		options := &models.PublicKeyCredentialRequestOptions{Challenge: "aregistrationchallenge"}
+
+		mockTokenRepository.On("ConsumeWebAuthnChallenge", mock.Anything, options.Challenge).Return("register:"+uid.String(), nil)
+		mockCredentialRepository.On("FindByID", mock.Anything, credential.ID).Return(credential, nil)
+
+		u, err := ws.FinishLogin(context.TODO(), authenticator.get(t, options))
+
+		assert.Nil(t, u)
+		assert.Equal(t, apperrors.Authorization, err.(*apperrors.Error).Type)
+		mockCredentialRepository.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+	})
+}